@@ -0,0 +1,76 @@
+import socket
+import time
+import random
+import os
+
+# Simulates a weighbridge indicator streaming Mettler Toledo style continuous
+# output over raw TCP, for the ingestor's WEIGHBRIDGE_LISTENERS.
+#
+# MODE=connect (default): dial the ingestor listener at TARGET.
+# MODE=serve: listen on TARGET like a serial-to-Ethernet converter in server
+# mode, for listeners configured with "connect".
+#
+# Matching listener frame config:
+# {"start":"\u0002","end":"\r","weight_offset":3,"weight_length":6,
+#  "status_offset":1,"motion_mask":8}
+
+MODE = os.getenv("MODE", "connect")
+TARGET = os.getenv("TARGET", "localhost:4001")
+FRAMES_PER_SECOND = int(os.getenv("FRAMES_PER_SECOND", "10"))
+
+STX = b"\x02"
+CR = b"\r"
+
+def frame(weight, motion):
+    swa = b"1"                      # no decimals
+    swb = b"8" if motion else b"0"  # bit 3 set while in motion
+    swc = b"0"
+    return STX + swa + swb + swc + f"{int(weight):06d}".encode() + b"000000" + CR
+
+def truck_cycle():
+    """Yields (weight, motion) for one vehicle: drive on, settle, drive off, empty."""
+    target = random.randint(8000, 42000)
+    for i in range(1, 21):
+        yield target * i / 20 + random.randint(-150, 150), True
+    for _ in range(random.randint(30, 60)):
+        yield target, False
+    for i in range(19, -1, -1):
+        yield target * i / 20, True
+    for _ in range(random.randint(20, 50)):
+        yield 0, False
+
+def stream(conn):
+    delay = 1 / FRAMES_PER_SECOND
+    while True:
+        print("🚛 Truck on the platform...")
+        for weight, motion in truck_cycle():
+            conn.sendall(frame(max(weight, 0), motion))
+            time.sleep(delay)
+
+def main():
+    host, port = TARGET.rsplit(":", 1)
+    print(f"🚀 Starting Indicator Simulator ({MODE} {TARGET})...")
+    try:
+        if MODE == "serve":
+            with socket.create_server((host, int(port))) as server:
+                while True:
+                    conn, addr = server.accept()
+                    print(f"🔌 Ingestor connected from {addr}")
+                    try:
+                        stream(conn)
+                    except OSError as e:
+                        print(f"  🚨 Connection lost: {e}")
+        else:
+            while True:
+                try:
+                    with socket.create_connection((host, int(port)), timeout=5) as conn:
+                        print(f"🔌 Connected to {TARGET}")
+                        stream(conn)
+                except OSError as e:
+                    print(f"  🚨 Connection error: {e}; retrying")
+                    time.sleep(2)
+    except KeyboardInterrupt:
+        print("\n🛑 Simulator stopped.")
+
+if __name__ == "__main__":
+    main()
@@ -44,6 +44,11 @@ func main() {
 		admin.PUT("/keys/:id/permissions", middleware.RequirePermission("update:keys"), handlers.HandleAssignPermissionsToKey)
 		admin.PUT("/keys/:id", middleware.RequirePermission("update:keys"), handlers.HandleUpdateKey)
 
+		// Джерела: усі ключі одного пристрою
+		admin.GET("/sources/:source_id/keys", middleware.RequirePermission("read:keys"), handlers.HandleListSourceKeys)
+		admin.POST("/sources/:source_id/rotate", middleware.RequirePermission("create:keys"), handlers.HandleRotateSourceKey)
+		admin.DELETE("/sources/:source_id/keys", middleware.RequirePermission("delete:keys"), handlers.HandleDeleteSourceKeys)
+
 		admin.GET("/permissions", middleware.RequirePermission("read:roles"), handlers.HandleListPermissions)
 	}
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/truckguard/auth/src/models"
@@ -28,11 +29,13 @@ func seedData() {
 		{ID: "delete:keys", Name: "Delete API Keys", Module: "auth"},
 
 		{ID: "create:ingest", Name: "Create Ingestion Data", Module: "ingestor"},
+		{ID: "read:streams", Name: "Read Stream Stats", Module: "ingestor"},
 
 		{ID: "read:trips", Name: "Read Trips", Module: "core"},
 		{ID: "create:events", Name: "Create Events", Module: "core"},
 		{ID: "update:events", Name: "Update Events", Module: "core"},
 		{ID: "read:events", Name: "Read Events", Module: "core"},
+		{ID: "read:images", Name: "Read Event Images", Module: "core"},
 		{ID: "read:cameras", Name: "Read Cameras", Module: "core"},
 		{ID: "create:cameras", Name: "Create Cameras", Module: "core"},
 		{ID: "update:cameras", Name: "Update Cameras", Module: "core"},
@@ -56,10 +59,27 @@ func seedData() {
 		{ID: "create:excluded_plates", Name: "Create Excluded Plates", Module: "core"},
 		{ID: "delete:excluded_plates", Name: "Delete Excluded Plates", Module: "core"},
 		{ID: "read:permits", Name: "Read Permits", Module: "core"},
+		{ID: "update:permits", Name: "Update Permits", Module: "core"},
 		{ID: "read:flows", Name: "Read Flows", Module: "core"},
 		{ID: "create:flows", Name: "Create Flows", Module: "core"},
 		{ID: "update:flows", Name: "Update Flows", Module: "core"},
 		{ID: "delete:flows", Name: "Delete Flows", Module: "core"},
+		{ID: "read:carriers", Name: "Read Carriers", Module: "core"},
+		{ID: "create:carriers", Name: "Create Carriers", Module: "core"},
+		{ID: "update:carriers", Name: "Update Carriers", Module: "core"},
+		{ID: "delete:carriers", Name: "Delete Carriers", Module: "core"},
+		{ID: "read:bookings", Name: "Read Bookings", Module: "core"},
+		{ID: "create:bookings", Name: "Create Bookings", Module: "core"},
+		{ID: "update:bookings", Name: "Update Bookings", Module: "core"},
+		{ID: "delete:bookings", Name: "Delete Bookings", Module: "core"},
+		{ID: "read:webhooks", Name: "Read Webhooks", Module: "core"},
+		{ID: "create:webhooks", Name: "Create Webhooks", Module: "core"},
+		{ID: "update:webhooks", Name: "Update Webhooks", Module: "core"},
+		{ID: "delete:webhooks", Name: "Delete Webhooks", Module: "core"},
+		{ID: "read:devices", Name: "Read Device Status", Module: "core"},
+		{ID: "update:devices", Name: "Run Device Reconciliation", Module: "core"},
+		{ID: "export:configs", Name: "Export Site Configuration", Module: "core"},
+		{ID: "import:configs", Name: "Import Site Configuration", Module: "core"},
 	}
 
 	for _, p := range perms {
@@ -114,4 +134,26 @@ func seedData() {
 			println("Successfully seeded System Worker API Key")
 		}
 	}
+
+	// Core reconciles device keys in the background; it may only list them.
+	coreKey := os.Getenv("CORE_SYSTEM_KEY")
+	if coreKey != "" {
+		h := repository.HashKey(coreKey)
+		var existingKey models.APIKey
+		err := repository.DB.Where("key_hash = ?", h).First(&existingKey).Error
+		if err != nil {
+			corePerms := []models.Permission{}
+			repository.DB.Where("id IN ?", []string{"manage:settings", "read:keys"}).Find(&corePerms)
+
+			newKey := models.APIKey{
+				KeyHash:     h,
+				OwnerName:   "Core System",
+				IsActive:    true,
+				Permissions: corePerms,
+			}
+			repository.DB.Create(&newKey)
+			repository.DB.Model(&newKey).Update("source_id", fmt.Sprintf("%d", newKey.ID))
+			println("Successfully seeded Core System API Key")
+		}
+	}
 }
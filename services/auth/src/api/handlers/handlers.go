@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -74,6 +75,13 @@ func HandleLogin(c *gin.Context) {
 
 func HandleValidate(c *gin.Context) {
 	k := c.GetHeader("X-API-Key")
+	if k == "" {
+		// Vendor cameras can only push with HTTP basic auth; the password
+		// carries the API key and the username is ignored.
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			k = password
+		}
+	}
 	if k != "" {
 		if meta, valid := repository.ValidateKeyAndGetMetadata(k); valid {
 			c.Header("X-Source-ID", meta.ID)
@@ -184,17 +192,20 @@ func HandleListKeys(c *gin.Context) {
 
 func HandleCreateKeyWithPerms(c *gin.Context) {
 	var b struct {
-		Name          string   `json:"name"`
-		PermissionIDs []string `json:"permission_ids"`
+		Name          string     `json:"name"`
+		PermissionIDs []string   `json:"permission_ids"`
+		ExpiresAt     *time.Time `json:"expires_at"`
 	}
 	if err := c.BindJSON(&b); err != nil {
 		c.Status(400)
 		return
 	}
+	if b.ExpiresAt != nil && !b.ExpiresAt.After(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
 
-	rb := make([]byte, 16)
-	rand.Read(rb)
-	rk := hex.EncodeToString(rb)
+	rk := generateKey()
 
 	var perms []models.Permission
 	repository.DB.Where("id IN ?", b.PermissionIDs).Find(&perms)
@@ -202,6 +213,7 @@ func HandleCreateKeyWithPerms(c *gin.Context) {
 	key := models.APIKey{
 		KeyHash:     repository.HashKey(rk),
 		OwnerName:   b.Name,
+		ExpiresAt:   b.ExpiresAt,
 		Permissions: perms,
 	}
 
@@ -209,7 +221,19 @@ func HandleCreateKeyWithPerms(c *gin.Context) {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(201, gin.H{"api_key": rk, "id": key.ID})
+	// A new key starts a new source; rotated keys inherit theirs instead.
+	key.SourceID = fmt.Sprintf("%d", key.ID)
+	if err := repository.DB.Model(&key).Update("source_id", key.SourceID).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(201, gin.H{"api_key": rk, "id": key.ID, "source_id": key.SourceID, "expires_at": key.ExpiresAt})
+}
+
+func generateKey() string {
+	rb := make([]byte, 16)
+	rand.Read(rb)
+	return hex.EncodeToString(rb)
 }
 
 func HandleUpdateKeyStatus(c *gin.Context) {
@@ -264,13 +288,18 @@ func HandleAssignPermissionsToKey(c *gin.Context) {
 func HandleUpdateKey(c *gin.Context) {
 	id := c.Param("id")
 	var b struct {
-		OwnerName string `json:"owner_name"`
-		IsActive  bool   `json:"is_active"`
+		OwnerName string       `json:"owner_name"`
+		IsActive  bool         `json:"is_active"`
+		ExpiresAt optionalTime `json:"expires_at"`
 	}
 	if err := c.BindJSON(&b); err != nil {
 		c.Status(400)
 		return
 	}
+	if b.ExpiresAt.Value != nil && !b.ExpiresAt.Value.After(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
 
 	var key models.APIKey
 	if err := repository.DB.First(&key, id).Error; err != nil {
@@ -278,16 +307,44 @@ func HandleUpdateKey(c *gin.Context) {
 		return
 	}
 
-	repository.DB.Model(&key).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"owner_name": b.OwnerName,
 		"is_active":  b.IsActive,
-	})
+	}
+	if b.ExpiresAt.Set {
+		updates["expires_at"] = b.ExpiresAt.Value
+	}
+	if err := repository.DB.Model(&key).Updates(updates).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
 	repository.RDB.Del(context.Background(), "auth:"+key.KeyHash)
 
 	c.JSON(200, key)
 }
 
+// optionalTime tells an omitted JSON time from an explicit null, so an update
+// only touches the fields it was sent.
+type optionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (o *optionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var t time.Time
+	if err := t.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	o.Value = &t
+	return nil
+}
+
 func HandleDeleteKey(c *gin.Context) {
 	id := c.Param("id")
 	var key models.APIKey
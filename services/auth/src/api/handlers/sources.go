@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/auth/src/models"
+	"github.com/truckguard/auth/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNoActiveKey = errors.New("no active key for source")
+
+func HandleListSourceKeys(c *gin.Context) {
+	var keys []models.APIKey
+	repository.DB.Preload("Permissions").Where("source_id = ?", c.Param("source_id")).Order("id").Find(&keys)
+	if len(keys) == 0 {
+		c.JSON(404, gin.H{"error": "Source not found"})
+		return
+	}
+	c.JSON(200, keys)
+}
+
+// HandleRotateSourceKey issues a new key for a source with the same owner
+// and permissions. The source's current keys keep working for the grace
+// period so the device can be reconfigured without dropping events; with no
+// grace period they are revoked at once.
+func HandleRotateSourceKey(c *gin.Context) {
+	sourceID := c.Param("source_id")
+	var b struct {
+		GracePeriodSeconds int `json:"grace_period_seconds"`
+	}
+	if err := c.ShouldBindJSON(&b); err != nil && !errors.Is(err, io.EOF) {
+		c.Status(400)
+		return
+	}
+	if b.GracePeriodSeconds < 0 {
+		c.JSON(400, gin.H{"error": "grace_period_seconds must not be negative"})
+		return
+	}
+
+	now := time.Now()
+	rk := generateKey()
+	var key models.APIKey
+	var previous []models.APIKey
+	var previousExpireAt *time.Time
+	if b.GracePeriodSeconds > 0 {
+		t := now.Add(time.Duration(b.GracePeriodSeconds) * time.Second)
+		previousExpireAt = &t
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Permissions").
+			Where("source_id = ? AND is_active = ? AND (expires_at IS NULL OR expires_at > ?)", sourceID, true, now).
+			Order("id DESC").Find(&previous).Error; err != nil {
+			return err
+		}
+		if len(previous) == 0 {
+			return errNoActiveKey
+		}
+
+		key = models.APIKey{
+			KeyHash:     repository.HashKey(rk),
+			OwnerName:   previous[0].OwnerName,
+			SourceID:    sourceID,
+			Permissions: previous[0].Permissions,
+		}
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+
+		for _, old := range previous {
+			var err error
+			switch {
+			case previousExpireAt == nil:
+				err = tx.Model(&old).Update("is_active", false).Error
+			case old.ExpiresAt == nil || old.ExpiresAt.After(*previousExpireAt):
+				err = tx.Model(&old).Update("expires_at", previousExpireAt).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNoActiveKey) {
+		c.JSON(404, gin.H{"error": "No active key for source"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousIDs := make([]uint, 0, len(previous))
+	for _, old := range previous {
+		repository.RDB.Del(context.Background(), "auth:"+old.KeyHash)
+		previousIDs = append(previousIDs, old.ID)
+	}
+
+	c.JSON(201, gin.H{
+		"api_key":                 rk,
+		"id":                      key.ID,
+		"source_id":               sourceID,
+		"previous_key_ids":        previousIDs,
+		"previous_keys_expire_at": previousExpireAt,
+	})
+}
+
+// HandleDeleteSourceKeys revokes every key of a source, including keys still
+// in a rotation grace period.
+func HandleDeleteSourceKeys(c *gin.Context) {
+	var keys []models.APIKey
+	repository.DB.Where("source_id = ?", c.Param("source_id")).Find(&keys)
+	if len(keys) == 0 {
+		c.JSON(404, gin.H{"error": "Source not found"})
+		return
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range keys {
+			if err := tx.Model(&keys[i]).Association("Permissions").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Delete(&keys[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete keys: " + err.Error()})
+		return
+	}
+
+	for _, key := range keys {
+		repository.RDB.Del(context.Background(), "auth:"+key.KeyHash)
+	}
+	c.Status(204)
+}
@@ -26,16 +26,24 @@ type User struct {
 }
 
 type APIKey struct {
-	ID          uint         `gorm:"primaryKey" json:"id"`
-	KeyHash     string       `gorm:"unique;index;not null" json:"-"`
-	OwnerName   string       `json:"owner_name"`
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	KeyHash   string `gorm:"unique;index;not null" json:"-"`
+	OwnerName string `json:"owner_name"`
+	// SourceID is the device identity sent as X-Source-ID. All keys of a
+	// device share it, so rotating a key keeps the device's identity; it
+	// defaults to the ID of the device's first key.
+	SourceID    string       `gorm:"index" json:"source_id"`
 	IsActive    bool         `gorm:"default:true" json:"is_active"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	LastUsedAt  *time.Time   `json:"last_used_at"`
 	Permissions []Permission `gorm:"many2many:apikey_permissions;" json:"permissions"`
 	CreatedAt   time.Time    `json:"created_at"`
 }
 
 type SourceMetadata struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Permissions []string `json:"permissions"`
+	ID          string     `json:"id"`
+	KeyID       uint       `json:"key_id"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 }
@@ -29,6 +29,8 @@ func InitDB(dsn string) {
 	}
 	DB = db
 	DB.AutoMigrate(&models.Permission{}, &models.Role{}, &models.User{}, &models.APIKey{})
+	// Keys created before rotation existed are their device's first key.
+	DB.Exec("UPDATE api_keys SET source_id = id::text WHERE source_id IS NULL OR source_id = ''")
 }
 
 func InitRedis(addr string) {
@@ -53,34 +55,65 @@ func GenerateToken(user models.User) (string, error) {
 
 func ValidateKeyAndGetMetadata(key string) (models.SourceMetadata, bool) {
 	h := HashKey(key)
+	now := time.Now()
 
 	if v, _ := RDB.Get(ctx, "auth:"+h).Result(); v != "" {
 		var meta models.SourceMetadata
 		json.Unmarshal([]byte(v), &meta)
+		if meta.ExpiresAt != nil && !now.Before(*meta.ExpiresAt) {
+			RDB.Del(ctx, "auth:"+h)
+			return models.SourceMetadata{}, false
+		}
+		recordKeyUse(meta.KeyID, now)
 		return meta, true
 	}
 
 	var ak models.APIKey
-	if err := DB.Preload("Permissions").Where("key_hash = ? AND is_active = ?", h, true).First(&ak).Error; err == nil {
+	if err := DB.Preload("Permissions").
+		Where("key_hash = ? AND is_active = ? AND (expires_at IS NULL OR expires_at > ?)", h, true, now).
+		First(&ak).Error; err == nil {
 		var perms []string
 		for _, p := range ak.Permissions {
 			perms = append(perms, p.ID)
 		}
 
+		sourceID := ak.SourceID
+		if sourceID == "" {
+			sourceID = fmt.Sprintf("%d", ak.ID)
+		}
 		meta := models.SourceMetadata{
-			ID:          fmt.Sprintf("%d", ak.ID),
+			ID:          sourceID,
+			KeyID:       ak.ID,
 			Name:        ak.OwnerName,
 			Permissions: perms,
+			ExpiresAt:   ak.ExpiresAt,
 		}
 
+		ttl := 15 * time.Minute
+		if ak.ExpiresAt != nil && ak.ExpiresAt.Sub(now) < ttl {
+			ttl = ak.ExpiresAt.Sub(now)
+		}
 		val, _ := json.Marshal(meta)
-		RDB.Set(ctx, "auth:"+h, val, 15*time.Minute)
+		RDB.Set(ctx, "auth:"+h, val, ttl)
+		recordKeyUse(ak.ID, now)
 		return meta, true
 	}
 
 	return models.SourceMetadata{}, false
 }
 
+// recordKeyUse stores when a key was last used. Devices validate on every
+// request, so the write happens at most once a minute per key.
+func recordKeyUse(keyID uint, at time.Time) {
+	if keyID == 0 {
+		return
+	}
+	if ok, _ := RDB.SetNX(ctx, fmt.Sprintf("auth:used:%d", keyID), 1, time.Minute).Result(); !ok {
+		return
+	}
+	DB.Model(&models.APIKey{}).Where("id = ?", keyID).UpdateColumn("last_used_at", at)
+}
+
 func GetUserPermissions(userID uint) []string {
 	key := fmt.Sprintf("user_perms:%d", userID)
 
@@ -1,3 +1,5 @@
+import mimetypes
+import os
 import requests
 from typing import List, Dict, Any
 from src.config import cfg
@@ -9,10 +11,12 @@ class ANPRClient:
         self.url = cfg.ANPR_URL
 
     @retry(stop=stop_after_attempt(3), wait=wait_exponential(multiplier=1, min=1, max=5))
-    def recognize(self, image_bytes: bytes) -> List[Dict[str, Any]]:
+    def recognize(self, image_bytes: bytes, image_key: str = "image.jpg") -> List[Dict[str, Any]]:
         """Повертає список варіантів номерів із впевненістю"""
         try:
-            files = {"file": ("image.jpg", image_bytes, "image/jpeg")}
+            filename = os.path.basename(image_key)
+            content_type = mimetypes.guess_type(filename)[0] or "application/octet-stream"
+            files = {"file": (filename, image_bytes, content_type)}
             resp = requests.post(self.url, files=files, timeout=15)
             
             if resp.status_code == 200:
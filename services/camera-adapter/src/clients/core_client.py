@@ -1,5 +1,6 @@
 import requests
 from src.config import cfg
+from src.logic.config_cache import NOT_MODIFIED
 from src.utils.logging_utils import logger
 from tenacity import retry, stop_after_attempt, wait_exponential
 
@@ -10,18 +11,26 @@ class CoreClient:
             "Content-Type": "application/json"
         }
 
-    def get_camera_config(self, source_id: str) -> dict | None:
-        url = f"{cfg.CORE_URL}/cameras/by-id/{source_id}" 
-        try:
-            resp = requests.get(url, headers=self.headers, timeout=5)
-            if resp.status_code == 200:
-                return resp.json()
-        except Exception as e:
-            logger.error(f"Error fetching config for {source_id}: {e}")
-        return None
+    def get_camera_config(self, source_id: str, etag: str | None = None) -> tuple:
+        """Returns (config, etag), (NOT_MODIFIED, etag) when etag still
+        matches, or (None, None) when core does not know the camera."""
+        url = f"{cfg.CORE_URL}/cameras/by-id/{source_id}"
+        headers = dict(self.headers)
+        if etag:
+            headers["If-None-Match"] = etag
+        resp = requests.get(url, headers=headers, timeout=5)
+        if resp.status_code == 304:
+            return NOT_MODIFIED, etag
+        if resp.status_code == 404:
+            return None, None
+        resp.raise_for_status()
+        return resp.json(), resp.headers.get("ETag")
 
     @retry(stop=stop_after_attempt(5), wait=wait_exponential(multiplier=1, min=2, max=10))
-    def send_event(self, event_data: dict):
+    def send_event(self, event_data: dict, idempotency_key: str | None = None):
         url = f"{cfg.CORE_URL}/events/plate" 
-        resp = requests.post(url, json=event_data, headers=self.headers, timeout=5) 
+        headers = dict(self.headers)
+        if idempotency_key:
+            headers["Idempotency-Key"] = idempotency_key
+        resp = requests.post(url, json=event_data, headers=headers, timeout=5)
         resp.raise_for_status()
\ No newline at end of file
@@ -1,23 +1,116 @@
 import json
+import re
 import xmltodict
-from typing import Optional
+from typing import Any, List, Optional, Tuple
 from src.utils.logging_utils import logger
 
+TRANSFORMS = {
+    "trim": lambda v: v.strip(),
+    "upper": lambda v: v.upper(),
+    "lower": lambda v: v.lower(),
+    "remove_spaces": lambda v: "".join(v.split()),
+    "alphanumeric": lambda v: re.sub(r"[^0-9A-Za-z]", "", v),
+    "numeric": lambda v: re.sub(r"[^0-9.\-]", "", v),
+}
+
+UNITS = {"kg": 1.0, "t": 1000.0, "g": 0.001, "lb": 0.45359237}
+
+# A path step: (key, index, text). key None means index only, text marks text().
+Step = Tuple[Optional[str], Optional[int], bool]
+
+
 class PayloadParser:
+    # Mirrors core's payloadparser package, which validates mappings and
+    # previews extraction; keep the two in step.
     @staticmethod
-    def extract_plate(payload: str, format_type: str, mapping: dict) -> Optional[str]:
-        try:
-            data = xmltodict.parse(payload) if format_type == "xml" else json.loads(payload)
-            
-            path = mapping.get("plate")
-            if not path: return None
+    def extractor(mapping: dict, field: str, format_type: str) -> Optional[dict]:
+        ex = mapping.get(field)
+        if not ex:
+            return None
+        if isinstance(ex, str):
+            # Older shorthand: the path, or the pattern for the regex format.
+            ex = {"regex": ex} if format_type == "regex" else {"path": ex}
+        elif format_type == "regex" and not ex.get("regex"):
+            ex = dict(ex, regex=ex.get("path"), path=None)
+        return ex
+
+    @staticmethod
+    def compile_path(path: str, format_type: str) -> List[Step]:
+        if format_type == "json" and path.startswith("$"):
+            steps = []
+            for name, quoted, index in re.findall(r"\.([^.\[]+)|\[['\"](.*?)['\"]\]|\[(\d+)\]", path[1:]):
+                if index:
+                    steps.append((None, int(index), False))
+                else:
+                    steps.append((name or quoted, None, False))
+            return steps
 
-            keys = path.split('/')
-            for k in keys:
+        steps = []
+        for seg in path.lstrip("/").split("/"):
+            if format_type != "xml":
+                steps.append((seg, None, False))
+            elif seg == "text()":
+                steps.append((None, None, True))
+            else:
+                m = re.fullmatch(r"(.+)\[(\d+)\]", seg)
+                steps.append((m.group(1), int(m.group(2)) - 1, False) if m else (seg, None, False))
+        return steps
+
+    @staticmethod
+    def resolve(data: Any, steps: List[Step], xml: bool) -> Any:
+        for key, index, text in steps:
+            if text:
                 if isinstance(data, dict):
-                    data = data.get(k)
-            
-            return str(data) if data else None
+                    data = data.get("#text")
+                continue
+            if key is not None:
+                if not isinstance(data, dict) or key not in data:
+                    return None
+                data = data[key]
+            if index is not None:
+                if isinstance(data, list):
+                    if index >= len(data):
+                        return None
+                    data = data[index]
+                elif not (xml and index == 0):
+                    return None
+            elif xml and isinstance(data, list) and data:
+                data = data[0]
+        if xml and isinstance(data, dict):
+            data = data.get("#text")
+        return data
+
+    @staticmethod
+    def extract_field(payload: str, format_type: str, mapping: dict, field: str) -> Optional[str]:
+        format_type = (format_type or "json").lower()
+        ex = PayloadParser.extractor(mapping, field, format_type)
+        if not ex:
+            return None
+
+        value = payload
+        if format_type != "regex":
+            if not ex.get("path"):
+                return None
+            # Numbers keep their JSON text ("1234.50"), as core's parser does.
+            data = xmltodict.parse(payload) if format_type == "xml" else json.loads(payload, parse_float=str)
+            data = PayloadParser.resolve(data, PayloadParser.compile_path(ex["path"], format_type), format_type == "xml")
+            if data is None or isinstance(data, (dict, list)):
+                return None
+            value = str(data).lower() if isinstance(data, bool) else str(data)
+
+        if ex.get("regex"):
+            match = re.search(ex["regex"], value)
+            if not match: return None
+            value = match.group(1) if match.re.groups else match.group(0)
+
+        for name in ex.get("transforms") or []:
+            value = TRANSFORMS[name.strip().lower()](value)
+        return value or None
+
+    @staticmethod
+    def extract_plate(payload: str, format_type: str, mapping: dict) -> Optional[str]:
+        try:
+            return PayloadParser.extract_field(payload, format_type, mapping, "plate")
         except Exception as e:
             logger.warning(f"Failed to parse payload: {e}")
-            return None
\ No newline at end of file
+            return None
@@ -1,4 +1,6 @@
 import json
+from src.config import cfg
+from src.logic.config_cache import ConfigCache
 from src.utils.logging_utils import logger
 
 
@@ -8,18 +10,15 @@ class EventProcessor:
         self.parser = parser
         self.minio = minio_client
         self.anpr = anpr_client
-        self.config_cache = {}
+        self.config_cache = ConfigCache(core_client.get_camera_config, cfg.CACHE_TTL)
 
     def _get_cached_config(self, source_id: str):
-        if source_id in self.config_cache:
-            return self.config_cache[source_id]
-        config = self.core.get_camera_config(source_id)
-        if config:
-            self.config_cache[source_id] = config
-        return config
-
-    def process(self, raw_data_str: str):
+        return self.config_cache.get(source_id)
+
+    def process(self, raw_data_str: str, msg_id: str | None = None):
         data = json.loads(raw_data_str)
+        # The ingestor's event_id survives DLQ replays; the stream ID does not.
+        idempotency_key = data.get("event_id") or msg_id
         source_id = data.get("source_id")
         image_key = data.get("image_key")
 
@@ -39,7 +38,7 @@ class EventProcessor:
         if not plate or config.get("run_anpr"):
             try:
                 img_bytes = self.minio.get_image(image_key)
-                suggestions = self.anpr.recognize(img_bytes)
+                suggestions = self.anpr.recognize(img_bytes, image_key)
                 if suggestions and not plate:
                     plate = suggestions[0]["plate"]
             except Exception as e:
@@ -53,8 +52,12 @@ class EventProcessor:
                 "plate": plate.upper().replace(" ", ""),
                 "suggestions": json.dumps(suggestions), 
                 "image_key": image_key,
-                "timestamp": data.get("at"),
+                "images": data.get("attachments") or [],
+                "timestamp": data.get("captured_at") or data.get("at"),
+                "captured_at": data.get("captured_at"),
+                "received_at": data.get("at"),
+                "buffered": bool(data.get("device_event_id")),
                 "raw_payload": data.get("payload"),
             }
-            self.core.send_event(final_event)
+            self.core.send_event(final_event, idempotency_key)
             logger.info(f"Successfully processed plate: {final_event}")
@@ -7,7 +7,7 @@ class CameraConfig:
     name: str
     format: str
     run_anpr: bool
-    field_mapping: Dict[str, str] = field(default_factory=dict)
+    field_mapping: Dict[str, Any] = field(default_factory=dict)
 
 @dataclass
 class IncomingEvent:
@@ -6,13 +6,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/truckguard/core/src/api/handlers"
 	"github.com/truckguard/core/src/api/middleware"
-	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/repository"
 )
 
 func main() {
 	repository.InitDB(os.Getenv("DATABASE_URL"))
 	repository.InitRedis(os.Getenv("REDIS_ADDR"))
+	repository.InitMinio(os.Getenv("MINIO_ENDPOINT"), os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY"))
 
 	r := gin.Default()
 
@@ -32,24 +33,44 @@ func main() {
 			configs.PUT("/presets/:id", middleware.RequireCorePermission("update:presets"), handlers.HandleUpdatePreset)
 			configs.DELETE("/presets/:id", middleware.RequireCorePermission("delete:presets"), handlers.HandleDeletePreset)
 
+			configs.GET("/scale-presets", middleware.RequireCorePermission("read:presets"), handlers.HandleListScalePresets)
+			configs.GET("/scale-presets/:id", middleware.RequireCorePermission("read:presets"), handlers.HandleGetScalePreset)
+			configs.POST("/scale-presets", middleware.RequireCorePermission("create:presets"), handlers.HandleCreateScalePreset)
+			configs.PUT("/scale-presets/:id", middleware.RequireCorePermission("update:presets"), handlers.HandleUpdateScalePreset)
+			configs.DELETE("/scale-presets/:id", middleware.RequireCorePermission("delete:presets"), handlers.HandleDeleteScalePreset)
+
 			configs.GET("/cameras", middleware.RequireCorePermission("read:cameras"), handlers.HandleGetCameras)
 			configs.GET("/cameras/:id", middleware.RequireCorePermission("read:cameras"), handlers.HandleGetConfigByID)
+			configs.GET("/cameras/:id/effective", middleware.RequireCorePermission("read:cameras"), handlers.HandleGetEffectiveCamera)
 			configs.POST("/cameras",
 				middleware.RequireCorePermission("create:cameras"),
 				middleware.RequireCorePermission("create:keys"),
 				handlers.HandleCreateCamera,
 			)
 			configs.PUT("/cameras/:id", middleware.RequireCorePermission("update:cameras"), handlers.HandleUpdateCamera)
+			configs.POST("/cameras/:id/test-parse", middleware.RequireCorePermission("read:cameras"), handlers.HandleTestParseCamera)
 			configs.DELETE("/cameras/:id", middleware.RequireCorePermission("delete:cameras"), handlers.HandleDeleteCamera)
+			configs.POST("/cameras/:id/rotate-key",
+				middleware.RequireCorePermission("update:cameras"),
+				middleware.RequireCorePermission("create:keys"),
+				handlers.HandleRotateCameraKey,
+			)
 
 			configs.GET("/scales", middleware.RequireCorePermission("read:scales"), handlers.HandleGetScales)
+			configs.GET("/scales/:id/effective", middleware.RequireCorePermission("read:scales"), handlers.HandleGetEffectiveScale)
 			configs.POST("/scales",
 				middleware.RequireCorePermission("create:scales"),
 				middleware.RequireCorePermission("create:keys"),
 				handlers.HandleCreateScale,
 			)
 			configs.PUT("/scales/:id", middleware.RequireCorePermission("update:scales"), handlers.HandleUpdateScale)
+			configs.POST("/scales/:id/test-parse", middleware.RequireCorePermission("read:scales"), handlers.HandleTestParseScale)
 			configs.DELETE("/scales/:id", middleware.RequireCorePermission("delete:scales"), handlers.HandleDeleteScale)
+			configs.POST("/scales/:id/rotate-key",
+				middleware.RequireCorePermission("update:scales"),
+				middleware.RequireCorePermission("create:keys"),
+				handlers.HandleRotateScaleKey,
+			)
 
 			configs.GET("/gates", middleware.RequireCorePermission("read:gates"), handlers.HandleGetGates)
 			configs.GET("/gates/:id", middleware.RequireCorePermission("read:gates"), handlers.HandleGetGateByID)
@@ -57,6 +78,14 @@ func main() {
 			configs.PUT("/gates/:id", middleware.RequireCorePermission("update:gates"), handlers.HandleUpdateGate)
 			configs.DELETE("/gates/:id", middleware.RequireCorePermission("delete:gates"), handlers.HandleDeleteGate)
 
+			configs.GET("/validate", middleware.RequireCorePermission("read:gates"), handlers.HandleValidateTopology)
+			configs.GET("/export", middleware.RequireCorePermission("export:configs"), handlers.HandleExportConfig)
+			configs.POST("/import",
+				middleware.RequireCorePermission("import:configs"),
+				middleware.RequireCorePermission("create:keys"),
+				handlers.HandleImportConfig,
+			)
+
 			configs.GET("/settings", middleware.RequireCorePermission("read:settings"), handlers.HandleListSettings)
 			configs.POST("/settings", middleware.RequireCorePermission("update:settings"), handlers.HandleUpdateSetting)
 
@@ -70,12 +99,29 @@ func main() {
 			configs.PUT("/flows/:id", middleware.RequireCorePermission("update:flows"), handlers.HandleUpdateFlow)
 			configs.DELETE("/flows/:id", middleware.RequireCorePermission("delete:flows"), handlers.HandleDeleteFlow)
 
+			configs.GET("/carriers", middleware.RequireCorePermission("read:carriers"), handlers.HandleListCarriers)
+			configs.GET("/carriers/:id", middleware.RequireCorePermission("read:carriers"), handlers.HandleGetCarrier)
+			configs.POST("/carriers", middleware.RequireCorePermission("create:carriers"), handlers.HandleCreateCarrier)
+			configs.PUT("/carriers/:id", middleware.RequireCorePermission("update:carriers"), handlers.HandleUpdateCarrier)
+			configs.DELETE("/carriers/:id", middleware.RequireCorePermission("delete:carriers"), handlers.HandleDeleteCarrier)
+
+			configs.GET("/webhooks", middleware.RequireCorePermission("read:webhooks"), handlers.HandleListWebhooks)
+			configs.GET("/webhooks/:id", middleware.RequireCorePermission("read:webhooks"), handlers.HandleGetWebhook)
+			configs.POST("/webhooks", middleware.RequireCorePermission("create:webhooks"), handlers.HandleCreateWebhook)
+			configs.PUT("/webhooks/:id", middleware.RequireCorePermission("update:webhooks"), handlers.HandleUpdateWebhook)
+			configs.DELETE("/webhooks/:id", middleware.RequireCorePermission("delete:webhooks"), handlers.HandleDeleteWebhook)
+			configs.GET("/webhooks/:id/deliveries", middleware.RequireCorePermission("read:webhooks"), handlers.HandleListWebhookDeliveries)
+			configs.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", middleware.RequireCorePermission("update:webhooks"), handlers.HandleRedeliverWebhook)
+
 		}
 
 		events := api.Group("/events")
 		{
+			events.GET("/stream", middleware.RequireCorePermission("read:events"), handlers.HandleEventStream)
+
 			events.GET("/plate", middleware.RequireCorePermission("read:events"), handlers.HandleGetPlateEvents)
 			events.GET("/plate/:id", middleware.RequireCorePermission("read:events"), handlers.HandleGetPlateEventByID)
+			events.GET("/plate/:id/image", middleware.RequireCorePermission("read:images"), handlers.HandleGetPlateEventImage)
 			events.POST("/plate",
 				middleware.RequireCorePermission("create:events"),
 				middleware.SystemEventLogger("plate"),
@@ -100,14 +146,42 @@ func main() {
 
 			events.GET("/gate", middleware.RequireCorePermission("read:events"), handlers.HandleGetGateEvents)
 			events.GET("/gate/:id", middleware.RequireCorePermission("read:events"), handlers.HandleGetGateEventByID)
+
+			events.GET("/clocks", middleware.RequireCorePermission("read:events"), handlers.HandleGetDeviceClocks)
+
+			events.GET("/unmatched", middleware.RequireCorePermission("read:events"), handlers.HandleGetUnmatchedEvents)
+			events.GET("/unmatched/:id", middleware.RequireCorePermission("read:events"), handlers.HandleGetUnmatchedEventByID)
+			events.POST("/unmatched/:id/attach", middleware.RequireCorePermission("update:events"), handlers.HandleAttachUnmatchedEvent)
+			events.POST("/unmatched/:id/assign-plate", middleware.RequireCorePermission("update:events"), handlers.HandleAssignPlateUnmatchedEvent)
+			events.POST("/unmatched/:id/dismiss", middleware.RequireCorePermission("update:events"), handlers.HandleDismissUnmatchedEvent)
 		}
 
+		api.GET("/devices/status", middleware.RequireCorePermission("read:devices"), handlers.HandleGetDeviceStatus)
+		api.GET("/devices/reconciliation", middleware.RequireCorePermission("read:devices"), handlers.HandleGetReconciliation)
+		api.POST("/devices/reconciliation", middleware.RequireCorePermission("update:devices"), handlers.HandleRunReconciliation)
+
 		permits := api.Group("/permits")
 		{
 			permits.GET("/", middleware.RequireCorePermission("read:permits"), handlers.HandleGetPermits)
 			permits.GET("/:id", middleware.RequireCorePermission("read:permits"), handlers.HandleGetPermitByID)
+			permits.PUT("/:id/legal-hold", middleware.RequireCorePermission("update:permits"), handlers.HandleSetPermitLegalHold)
+			permits.PUT("/:id/flow", middleware.RequireCorePermission("update:permits"), handlers.HandleSetPermitFlow)
+			permits.GET("/:id/images",
+				middleware.RequireCorePermission("read:permits"),
+				middleware.RequireCorePermission("read:images"),
+				handlers.HandleGetPermitImages,
+			)
 		}
 	
+		bookings := api.Group("/bookings")
+		{
+			bookings.GET("/", middleware.RequireCorePermission("read:bookings"), handlers.HandleGetBookings)
+			bookings.GET("/:id", middleware.RequireCorePermission("read:bookings"), handlers.HandleGetBooking)
+			bookings.POST("/", middleware.RequireCorePermission("create:bookings"), handlers.HandleCreateBooking)
+			bookings.PUT("/:id", middleware.RequireCorePermission("update:bookings"), handlers.HandleUpdateBooking)
+			bookings.DELETE("/:id", middleware.RequireCorePermission("delete:bookings"), handlers.HandleDeleteBooking)
+		}
+
 		users := api.Group("/users")
 		{
 			users.GET("/", middleware.RequireCorePermission("read:users"), handlers.HandleListUsers)
@@ -118,7 +192,13 @@ func main() {
 		}
 	}
 
-	repository.DB.FirstOrCreate(&models.SystemSetting{Key: "match_window_seconds", Value: "120"})
+	logic.SeedSettings()
+
+	go logic.RunWebhookDispatcher()
+	go logic.RunOutboxRelay()
+	go logic.RunRetentionPurger()
+	go logic.RunDeviceMonitor()
+	go logic.RunReconciliation()
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"time"
 )
@@ -13,6 +15,9 @@ import (
 type AuthClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// SystemKey is core's own API key, used by background jobs that have no
+	// caller whose credentials they could forward.
+	SystemKey string
 }
 
 func NewAuthClient() *AuthClient {
@@ -25,6 +30,7 @@ func NewAuthClient() *AuthClient {
 		HTTPClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		SystemKey: os.Getenv("AUTH_SYSTEM_KEY"),
 	}
 }
 
@@ -34,8 +40,9 @@ type CreateKeyRequest struct {
 }
 
 type CreateKeyResponse struct {
-	ID     interface{} `json:"id"`
-	APIKey string      `json:"api_key"`
+	ID       interface{} `json:"id"`
+	APIKey   string      `json:"api_key"`
+	SourceID string      `json:"source_id"`
 }
 
 type RegisterUserRequest struct {
@@ -121,6 +128,156 @@ func (c *AuthClient) DeleteApiKey(ctx context.Context, keyID string, authHeader,
 	return nil
 }
 
+type RotateKeyRequest struct {
+	GracePeriodSeconds int `json:"grace_period_seconds"`
+}
+
+type RotateKeyResponse struct {
+	ID                   interface{} `json:"id"`
+	APIKey               string      `json:"api_key"`
+	SourceID             string      `json:"source_id"`
+	PreviousKeyIDs       []uint      `json:"previous_key_ids"`
+	PreviousKeysExpireAt *time.Time  `json:"previous_keys_expire_at"`
+}
+
+// ErrSourceNotFound is returned when auth has no active key for a source.
+var ErrSourceNotFound = errors.New("auth service has no key for source")
+
+// RotateSourceKey issues a new key for the device and lets its current keys
+// work for gracePeriod more.
+func (c *AuthClient) RotateSourceKey(ctx context.Context, sourceID string, gracePeriod time.Duration, authHeader, apiKeyHeader string) (*RotateKeyResponse, error) {
+	url := fmt.Sprintf("%s/admin/sources/%s/rotate", c.BaseURL, neturl.PathEscape(sourceID))
+
+	jsonData, err := json.Marshal(RotateKeyRequest{GracePeriodSeconds: int(gracePeriod / time.Second)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	if apiKeyHeader != "" {
+		req.Header.Set("X-Api-Key", apiKeyHeader)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSourceNotFound
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth service returned status: %d", resp.StatusCode)
+	}
+
+	var result RotateKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// DeleteSourceKeys revokes every key of the device, including rotated keys
+// still in their grace period. A source without keys is not an error.
+func (c *AuthClient) DeleteSourceKeys(ctx context.Context, sourceID string, authHeader, apiKeyHeader string) error {
+	url := fmt.Sprintf("%s/admin/sources/%s/keys", c.BaseURL, neturl.PathEscape(sourceID))
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	if apiKeyHeader != "" {
+		req.Header.Set("X-Api-Key", apiKeyHeader)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("auth service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("auth service returned status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type APIKeyInfo struct {
+	ID          uint       `json:"id"`
+	OwnerName   string     `json:"owner_name"`
+	SourceID    string     `json:"source_id"`
+	IsActive    bool       `json:"is_active"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Permissions []struct {
+		ID string `json:"id"`
+	} `json:"permissions"`
+}
+
+// HasPermission reports whether the key grants perm.
+func (k APIKeyInfo) HasPermission(perm string) bool {
+	for _, p := range k.Permissions {
+		if p.ID == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key is active and not expired at t.
+func (k APIKeyInfo) Usable(t time.Time) bool {
+	return k.IsActive && (k.ExpiresAt == nil || k.ExpiresAt.After(t))
+}
+
+func (c *AuthClient) ListApiKeys(ctx context.Context, authHeader, apiKeyHeader string) ([]APIKeyInfo, error) {
+	url := fmt.Sprintf("%s/admin/keys", c.BaseURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	if apiKeyHeader != "" {
+		req.Header.Set("X-Api-Key", apiKeyHeader)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth service returned status: %d", resp.StatusCode)
+	}
+
+	var result []APIKeyInfo
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result, nil
+}
+
 func (c *AuthClient) RegisterUser(ctx context.Context, username, password, role string, authHeader, apiKeyHeader string) (*RegisterUserResponse, error) {
 	url := fmt.Sprintf("%s/register", c.BaseURL)
 
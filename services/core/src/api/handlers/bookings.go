@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"github.com/truckguard/core/src/repository"
+	"github.com/truckguard/core/src/utils"
+	"gorm.io/gorm"
+)
+
+func HandleGetBookings(c *gin.Context) {
+	var bookings []models.Booking
+	var total int64
+	limit, offset, page := utils.GetPagination(c)
+
+	query := repository.DB.Model(&models.Booking{})
+	if plate := c.Query("plate"); plate != "" {
+		query = query.Where("plate = ?", plate)
+	}
+	if c.Query("used") == "false" {
+		query = query.Where("permit_id IS NULL")
+	}
+
+	query.Count(&total)
+	query.Limit(limit).Offset(offset).Order("created_at desc").Find(&bookings)
+
+	utils.SendPaginatedResponse(c, bookings, total, page, limit)
+}
+
+func HandleGetBooking(c *gin.Context) {
+	var booking models.Booking
+	if err := repository.DB.Preload("Carrier").Preload("Flow").First(&booking, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+func HandleCreateBooking(c *gin.Context) {
+	var input models.Booking
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	booking := bookingFields(models.Booking{}, input)
+	if !validBooking(c, booking) {
+		return
+	}
+
+	if err := repository.DB.Create(&booking).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+	c.JSON(http.StatusCreated, booking)
+}
+
+func HandleUpdateBooking(c *gin.Context) {
+	var booking models.Booking
+	if err := repository.DB.First(&booking, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	var input models.Booking
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	booking = bookingFields(booking, input)
+	if !validBooking(c, booking) {
+		return
+	}
+
+	if err := repository.DB.Save(&booking).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+func HandleDeleteBooking(c *gin.Context) {
+	if err := repository.DB.Delete(&models.Booking{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete booking"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// bookingFields copies the client-settable fields of input onto booking. The
+// permit is only ever set when a permit uses the booking.
+func bookingFields(booking, input models.Booking) models.Booking {
+	booking.Reference = strings.TrimSpace(input.Reference)
+	booking.Plate = payloadparser.NormalizePlate(input.Plate)
+	booking.CarrierID, booking.FlowID = input.CarrierID, input.FlowID
+	booking.ValidFrom, booking.ValidUntil = input.ValidFrom, input.ValidUntil
+	booking.Carrier, booking.Flow = nil, nil
+	return booking
+}
+
+// validBooking responds 400 for a missing reference or plate, an empty
+// validity window or an unknown carrier or flow, and 409 for a taken
+// reference.
+func validBooking(c *gin.Context, booking models.Booking) bool {
+	switch {
+	case booking.Reference == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reference is required"})
+		return false
+	case booking.Plate == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "plate is required"})
+		return false
+	case booking.ValidFrom != nil && booking.ValidUntil != nil && booking.ValidUntil.Before(*booking.ValidFrom):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid_until must not be before valid_from"})
+		return false
+	}
+
+	var count int64
+	if err := repository.DB.Model(&models.Booking{}).Where("reference = ? AND id <> ?", booking.Reference, booking.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate booking"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("booking %q already exists", booking.Reference)})
+		return false
+	}
+
+	if booking.CarrierID != nil {
+		if err := repository.DB.First(&models.Carrier{}, *booking.CarrierID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("carrier %d not found", *booking.CarrierID)})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carrier"})
+			}
+			return false
+		}
+	}
+	return flowExists(c, booking.FlowID)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+)
+
+func HandleListCarriers(c *gin.Context) {
+	var carriers []models.Carrier
+	if err := repository.DB.Preload("Vehicles").Order("name").Find(&carriers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch carriers"})
+		return
+	}
+	c.JSON(http.StatusOK, carriers)
+}
+
+func HandleGetCarrier(c *gin.Context) {
+	var carrier models.Carrier
+	if err := repository.DB.Preload("Vehicles").Preload("Flow").First(&carrier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Carrier not found"})
+		return
+	}
+	c.JSON(http.StatusOK, carrier)
+}
+
+func HandleCreateCarrier(c *gin.Context) {
+	var input models.Carrier
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	carrier := models.Carrier{Name: input.Name, Code: strings.TrimSpace(input.Code), FlowID: input.FlowID}
+	if !validCarrier(c, 0, carrier, input.Vehicles) {
+		return
+	}
+	carrier.Vehicles = carrierVehicles(input.Vehicles)
+
+	if err := repository.DB.Create(&carrier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create carrier"})
+		return
+	}
+	c.JSON(http.StatusCreated, carrier)
+}
+
+// HandleUpdateCarrier replaces the carrier's fields and, when vehicles are
+// given, its vehicles.
+func HandleUpdateCarrier(c *gin.Context) {
+	var carrier models.Carrier
+	if err := repository.DB.First(&carrier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Carrier not found"})
+		return
+	}
+	var input models.Carrier
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	carrier.Name, carrier.Code, carrier.FlowID = input.Name, strings.TrimSpace(input.Code), input.FlowID
+	carrier.Flow = nil
+	if !validCarrier(c, carrier.ID, carrier, input.Vehicles) {
+		return
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&carrier).Error; err != nil {
+			return err
+		}
+		if input.Vehicles == nil {
+			return nil
+		}
+		if err := tx.Where("carrier_id = ?", carrier.ID).Delete(&models.CarrierVehicle{}).Error; err != nil {
+			return err
+		}
+		carrier.Vehicles = carrierVehicles(input.Vehicles)
+		for i := range carrier.Vehicles {
+			carrier.Vehicles[i].CarrierID = carrier.ID
+		}
+		if len(carrier.Vehicles) == 0 {
+			return nil
+		}
+		return tx.Create(&carrier.Vehicles).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update carrier"})
+		return
+	}
+	c.JSON(http.StatusOK, carrier)
+}
+
+func HandleDeleteCarrier(c *gin.Context) {
+	id := c.Param("id")
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("carrier_id = ?", id).Delete(&models.CarrierVehicle{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Carrier{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete carrier"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// validCarrier responds 400 when the code or a plate is empty or the flow does
+// not exist, and 409 when the code or a plate belongs to another carrier.
+func validCarrier(c *gin.Context, id uint, carrier models.Carrier, vehicles []models.CarrierVehicle) bool {
+	if carrier.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return false
+	}
+	var count int64
+	if err := repository.DB.Model(&models.Carrier{}).Where("code = ? AND id <> ?", carrier.Code, id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate carrier"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("carrier code %q already exists", carrier.Code)})
+		return false
+	}
+	if !flowExists(c, carrier.FlowID) {
+		return false
+	}
+
+	var plates []string
+	for _, v := range vehicles {
+		plate := payloadparser.NormalizePlate(v.Plate)
+		if plate == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle plate must not be empty"})
+			return false
+		}
+		plates = append(plates, plate)
+	}
+	if len(plates) == 0 {
+		return true
+	}
+	var taken []models.CarrierVehicle
+	if err := repository.DB.Where("plate IN ? AND carrier_id <> ?", plates, id).Find(&taken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate carrier"})
+		return false
+	}
+	if len(taken) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("plate %q belongs to carrier %d", taken[0].Plate, taken[0].CarrierID)})
+		return false
+	}
+	return true
+}
+
+func carrierVehicles(input []models.CarrierVehicle) []models.CarrierVehicle {
+	vehicles := []models.CarrierVehicle{}
+	seen := map[string]bool{}
+	for _, v := range input {
+		plate := payloadparser.NormalizePlate(v.Plate)
+		if !seen[plate] {
+			seen[plate] = true
+			vehicles = append(vehicles, models.CarrierVehicle{Plate: plate})
+		}
+	}
+	return vehicles
+}
+
+// flowExists responds 400 when flowID is set but names no flow.
+func flowExists(c *gin.Context, flowID *uint) bool {
+	if flowID == nil {
+		return true
+	}
+	if err := repository.DB.First(&models.Flow{}, *flowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("flow %d not found", *flowID)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flow"})
+		}
+		return false
+	}
+	return true
+}
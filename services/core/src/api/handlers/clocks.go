@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// HandleGetDeviceClocks lists the clock skew observed per device. Optional
+// filters: ?drifting=true, ?device_type=camera|scale, ?gate_id=
+func HandleGetDeviceClocks(c *gin.Context) {
+	query := repository.DB.Model(&models.DeviceClock{})
+	if c.Query("drifting") == "true" {
+		query = query.Where("drifting = ?", true)
+	}
+	if deviceType := c.Query("device_type"); deviceType != "" {
+		query = query.Where("device_type = ?", deviceType)
+	}
+	if gateID := c.Query("gate_id"); gateID != "" {
+		query = query.Where("gate_id = ?", gateID)
+	}
+
+	var clocks []models.DeviceClock
+	if err := query.Order("device_type, source_id").Find(&clocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device clocks"})
+		return
+	}
+	c.JSON(http.StatusOK, clocks)
+}
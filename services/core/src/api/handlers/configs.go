@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/truckguard/core/src/api/clients"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
 	"github.com/truckguard/core/src/repository"
 	"github.com/truckguard/core/src/utils"
 )
@@ -17,31 +19,21 @@ func HandleCreateCamera(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	authClient := clients.NewAuthClient()
-	authResp, err := authClient.CreateApiKey(
-		c.Request.Context(),
-		config.Name+"_key",
-		[]string{"create:ingest"},
-		c.GetHeader("Authorization"),
-		c.GetHeader("X-Api-Key"),
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create authentication key: " + err.Error()})
+	if !validateCameraConfig(c, &config) {
 		return
 	}
 
-	config.SourceID = fmt.Sprintf("%v", authResp.ID)
-
-	if err := repository.DB.Create(&config).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save camera configuration"})
+	apiKey, ok := createDeviceWithKey(c, "camera", config.Name, "Failed to save camera configuration", func(sourceID string) error {
+		config.SourceID = sourceID
+		return repository.DB.Create(&config).Error
+	})
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"camera":  config,
-		"api_key": authResp.APIKey,
+		"api_key": apiKey,
 	})
 }
 
@@ -66,6 +58,8 @@ func HandleGetConfigByID(c *gin.Context) {
 	c.JSON(http.StatusOK, config)
 }
 
+// HandleGetConfigByCameraID serves the camera adapter the effective config:
+// the camera's settings merged over its preset.
 func HandleGetConfigByCameraID(c *gin.Context) {
 	sourceID := c.Param("camera_id")
 	var config models.CameraConfig
@@ -73,7 +67,73 @@ func HandleGetConfigByCameraID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Camera config not found"})
 		return
 	}
-	c.JSON(http.StatusOK, config)
+	respondEffectiveCamera(c, config)
+}
+
+func HandleGetEffectiveCamera(c *gin.Context) {
+	var config models.CameraConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Camera config not found"})
+		return
+	}
+	respondEffectiveCamera(c, config)
+}
+
+func respondEffectiveCamera(c *gin.Context, config models.CameraConfig) {
+	eff, err := logic.ResolveCameraConfig(config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve camera config: " + err.Error()})
+		return
+	}
+	if notModified(c, eff.Version) {
+		return
+	}
+	c.JSON(http.StatusOK, eff)
+}
+
+// validateCameraConfig checks the camera's mapping against the format it
+// will run with, which may come from its preset, and then the merged
+// mapping. It normalizes config.FieldMapping and reports failures itself.
+func validateCameraConfig(c *gin.Context, config *models.CameraConfig) bool {
+	eff, err := logic.ResolveCameraConfig(*config)
+	if err != nil {
+		respondResolveError(c, err)
+		return false
+	}
+	mapping, err := validateMapping(eff.Format, config.FieldMapping, payloadparser.FieldPlate)
+	if err == nil {
+		_, err = validateMapping(eff.Format, eff.FieldMapping, payloadparser.FieldPlate)
+	}
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return false
+	}
+	config.FieldMapping = mapping
+	return true
+}
+
+// notModified sets the version headers and answers a conditional GET whose
+// If-None-Match still matches with 304.
+func notModified(c *gin.Context, version string) bool {
+	etag := `"` + version + `"`
+	c.Header("ETag", etag)
+	c.Header("X-Config-Version", version)
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func respondResolveError(c *gin.Context, err error) {
+	if errors.Is(err, logic.ErrPresetNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
 
 func HandleUpdateCamera(c *gin.Context) {
@@ -89,12 +149,16 @@ func HandleUpdateCamera(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateCameraConfig(c, &config) {
+		return
+	}
 
 	if err := repository.DB.Save(&config).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update configuration"})
 		return
 	}
 
+	logic.PublishCameraConfigChange(config)
 	c.JSON(http.StatusOK, config)
 }
 
@@ -106,21 +170,10 @@ func HandleDeleteCamera(c *gin.Context) {
 		return
 	}
 
-	if config.SourceID != "" {
-		authClient := clients.NewAuthClient()
-		err := authClient.DeleteApiKey(
-			c.Request.Context(),
-			config.SourceID,
-			c.GetHeader("Authorization"),
-			c.GetHeader("X-Api-Key"),
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated API key: " + err.Error()})
-			return
-		}
+	if !deleteDeviceWithKeys(c, "camera", &config, config.SourceID, "Failed to delete camera configuration") {
+		return
 	}
-
-	repository.DB.Delete(&config)
+	logic.PublishConfigDeleted(models.DeviceTypeCamera, config.SourceID)
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
 
@@ -130,31 +183,21 @@ func HandleCreateScale(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	authClient := clients.NewAuthClient()
-	authResp, err := authClient.CreateApiKey(
-		c.Request.Context(),
-		config.Name+"_key",
-		[]string{"create:ingest"},
-		c.GetHeader("Authorization"),
-		c.GetHeader("X-Api-Key"),
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create authentication key: " + err.Error()})
+	if !validateScaleConfig(c, &config) {
 		return
 	}
 
-	config.SourceID = fmt.Sprintf("%v", authResp.ID)
-
-	if err := repository.DB.Create(&config).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scale configuration"})
+	apiKey, ok := createDeviceWithKey(c, "scale", config.Name, "Failed to save scale configuration", func(sourceID string) error {
+		config.SourceID = sourceID
+		return repository.DB.Create(&config).Error
+	})
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"scale":   config,
-		"api_key": authResp.APIKey,
+		"api_key": apiKey,
 	})
 }
 
@@ -169,6 +212,7 @@ func HandleGetScales(c *gin.Context) {
 	utils.SendPaginatedResponse(c, configs, total, page, limit)
 }
 
+// HandleGetConfigByScaleID serves the weight adapter the effective config.
 func HandleGetConfigByScaleID(c *gin.Context) {
 	scaleID := c.Param("scale_id")
 	var config models.ScaleConfig
@@ -176,7 +220,46 @@ func HandleGetConfigByScaleID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "scale config not found"})
 		return
 	}
-	c.JSON(http.StatusOK, config)
+	respondEffectiveScale(c, config)
+}
+
+func HandleGetEffectiveScale(c *gin.Context) {
+	var config models.ScaleConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scale config not found"})
+		return
+	}
+	respondEffectiveScale(c, config)
+}
+
+func respondEffectiveScale(c *gin.Context, config models.ScaleConfig) {
+	eff, err := logic.ResolveScaleConfig(config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve scale config: " + err.Error()})
+		return
+	}
+	if notModified(c, eff.Version) {
+		return
+	}
+	c.JSON(http.StatusOK, eff)
+}
+
+func validateScaleConfig(c *gin.Context, config *models.ScaleConfig) bool {
+	eff, err := logic.ResolveScaleConfig(*config)
+	if err != nil {
+		respondResolveError(c, err)
+		return false
+	}
+	mapping, err := validateMapping(eff.Format, config.FieldMapping, payloadparser.FieldWeight)
+	if err == nil {
+		_, err = validateMapping(eff.Format, eff.FieldMapping, payloadparser.FieldWeight)
+	}
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return false
+	}
+	config.FieldMapping = mapping
+	return true
 }
 
 func HandleUpdateScale(c *gin.Context) {
@@ -192,12 +275,16 @@ func HandleUpdateScale(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validateScaleConfig(c, &config) {
+		return
+	}
 
 	if err := repository.DB.Save(&config).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scale configuration"})
 		return
 	}
 
+	logic.PublishScaleConfigChange(config)
 	c.JSON(http.StatusOK, config)
 }
 
@@ -210,24 +297,9 @@ func HandleDeleteScale(c *gin.Context) {
 		return
 	}
 
-	if config.SourceID != "" {
-		authClient := clients.NewAuthClient()
-		err := authClient.DeleteApiKey(
-			c.Request.Context(),
-			config.SourceID,
-			c.GetHeader("Authorization"),
-			c.GetHeader("X-Api-Key"),
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated API key: " + err.Error()})
-			return
-		}
-	}
-
-	if err := repository.DB.Delete(&config).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scale configuration"})
+	if !deleteDeviceWithKeys(c, "scale", &config, config.SourceID, "Failed to delete scale configuration") {
 		return
 	}
-
+	logic.PublishConfigDeleted(models.DeviceTypeScale, config.SourceID)
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// HandleGetDeviceStatus lists every camera and scale with when it was last
+// heard from and its online/degraded/offline status, refreshed by the device
+// monitor. Optional filters: ?status=, ?device_type=camera|scale, ?gate_id=
+func HandleGetDeviceStatus(c *gin.Context) {
+	query := repository.DB.Model(&models.DeviceHealth{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if deviceType := c.Query("device_type"); deviceType != "" {
+		query = query.Where("device_type = ?", deviceType)
+	}
+	if gateID := c.Query("gate_id"); gateID != "" {
+		query = query.Where("gate_id = ?", gateID)
+	}
+
+	var devices []models.DeviceHealth
+	if err := query.Order("device_type, source_id").Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device status"})
+		return
+	}
+
+	summary := map[string]int{
+		models.DeviceStatusOnline:   0,
+		models.DeviceStatusDegraded: 0,
+		models.DeviceStatusOffline:  0,
+		models.DeviceStatusUnknown:  0,
+	}
+	for _, d := range devices {
+		summary[d.Status]++
+	}
+	c.JSON(http.StatusOK, gin.H{"devices": devices, "summary": summary})
+}
+
+// HandleGetReconciliation returns the latest comparison of auth's device keys
+// with core's cameras and scales.
+func HandleGetReconciliation(c *gin.Context) {
+	report, err := logic.LastReconciliation()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read reconciliation report"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation has not run yet"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleRunReconciliation reconciles now instead of waiting for the hourly run.
+func HandleRunReconciliation(c *gin.Context) {
+	report, err := logic.Reconcile(c.Request.Context())
+	if errors.Is(err, logic.ErrReconciliationDisabled) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Reconciliation failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
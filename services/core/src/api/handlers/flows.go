@@ -4,8 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
 	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
 )
 
 func HandleListFlows(c *gin.Context) {
@@ -23,6 +25,9 @@ func HandleCreateFlow(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !validFlowSteps(c, flow.Steps) {
+		return
+	}
 
 	if err := repository.DB.Create(&flow).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create flow"})
@@ -60,20 +65,43 @@ func HandleUpdateFlow(c *gin.Context) {
 	flow.Description = input.Description
 
 	// Steps strategy: Replace all steps
-	if len(input.Steps) > 0 {
-		// remove old steps
-		repository.DB.Where("flow_id = ?", flow.ID).Delete(&models.FlowStep{})
-		// add new ones
-		flow.Steps = input.Steps
+	if len(input.Steps) > 0 && !validFlowSteps(c, input.Steps) {
+		return
 	}
 
-	if err := repository.DB.Save(&flow).Error; err != nil {
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if len(input.Steps) > 0 {
+			// remove old steps
+			if err := tx.Where("flow_id = ?", flow.ID).Delete(&models.FlowStep{}).Error; err != nil {
+				return err
+			}
+			// add new ones
+			flow.Steps = input.Steps
+		}
+		return tx.Save(&flow).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flow"})
 		return
 	}
 	c.JSON(http.StatusOK, flow)
 }
 
+// validFlowSteps responds 400 with every problem when the steps do not form a
+// valid flow.
+func validFlowSteps(c *gin.Context, steps []models.FlowStep) bool {
+	errs, err := logic.ValidateFlow(repository.DB, steps)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate flow"})
+		return false
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flow", "details": errs})
+		return false
+	}
+	return true
+}
+
 func HandleDeleteFlow(c *gin.Context) {
 	id := c.Param("id")
 	// Clean up steps first (optional if cascade is set, but safter here)
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
 	"github.com/truckguard/core/src/repository"
 	"github.com/truckguard/core/src/utils"
@@ -15,6 +16,10 @@ func HandleCreateGate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := logic.ValidateGateThresholds(gate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := repository.DB.Create(&gate).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create gate"})
@@ -57,6 +62,14 @@ func HandleUpdateGate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := logic.ValidateGateThresholds(gate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := logic.ValidateGateFlows(repository.DB, gate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := repository.DB.Save(&gate).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update gate"})
@@ -71,3 +84,14 @@ func HandleDeleteGate(c *gin.Context) {
 	repository.DB.Delete(&models.Gate{}, id)
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
+
+// HandleValidateTopology reports every problem in how the site's gates, flows,
+// cameras and scales fit together.
+func HandleValidateTopology(c *gin.Context) {
+	report, err := logic.ValidateTopology(repository.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate topology"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
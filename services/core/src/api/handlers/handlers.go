@@ -8,8 +8,10 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
 	"github.com/truckguard/core/src/repository"
 	"github.com/truckguard/core/src/utils"
+	"gorm.io/gorm"
 )
 
 func HandlePlateEvent(c *gin.Context) {
@@ -24,8 +26,24 @@ func HandlePlateEvent(c *gin.Context) {
 	if sysEventID, exists := c.Get("system_event_id"); exists {
 		event.SystemEventID = sysEventID.(uint)
 	}
+	normalizePlateEventImages(&event)
+
+	event.IdempotencyKey = idempotencyKey(c, event.IdempotencyKey)
+	if event.IdempotencyKey != nil {
+		var existing models.RawPlateEvent
+		if findByIdempotencyKey(&existing, *event.IdempotencyKey) {
+			respondDuplicateEvent(c, existing.ID, existing)
+			return
+		}
+	}
 
 	if err := repository.DB.Create(&event).Error; err != nil {
+		// A concurrent retry with the same key may have won the insert.
+		var existing models.RawPlateEvent
+		if event.IdempotencyKey != nil && findByIdempotencyKey(&existing, *event.IdempotencyKey) {
+			respondDuplicateEvent(c, existing.ID, existing)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save event"})
 		return
 	}
@@ -35,6 +53,28 @@ func HandlePlateEvent(c *gin.Context) {
 	c.JSON(http.StatusAccepted, gin.H{"status": "processing", "id": event.ID})
 }
 
+// normalizePlateEventImages keeps ImageKey and Images consistent for adapters
+// that send only one of them.
+func normalizePlateEventImages(event *models.RawPlateEvent) {
+	if len(event.Images) == 0 {
+		if event.ImageKey != "" {
+			event.Images = []models.PlateEventImage{{Role: models.ImageRoleImage, Key: event.ImageKey}}
+		}
+		return
+	}
+	if event.ImageKey != "" {
+		return
+	}
+	for _, role := range []string{models.ImageRolePlate, models.ImageRoleImage, models.ImageRoleOverview} {
+		for _, img := range event.Images {
+			if img.Role == role {
+				event.ImageKey = img.Key
+				return
+			}
+		}
+	}
+}
+
 func HandlePatchPlateEvent(c *gin.Context) {
 	id := c.Param("id")
 	var input struct {
@@ -44,6 +84,7 @@ func HandlePatchPlateEvent(c *gin.Context) {
 		c.Status(400)
 		return
 	}
+	input.PlateCorrected = payloadparser.NormalizePlate(input.PlateCorrected)
 	var event models.RawPlateEvent
 	if err := repository.DB.First(&event, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
@@ -55,22 +96,25 @@ func HandlePatchPlateEvent(c *gin.Context) {
 		oldEffectivePlate = event.PlateCorrected
 	}
 
-	userID := c.GetHeader("X-User-ID")
-	if err := repository.DB.Model(&models.RawPlateEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"plate_corrected": input.PlateCorrected,
-		"corrected_by":    userID,
-		"is_manual":       true,
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+	var permits []models.Permit
+	if err := repository.DB.Joins("JOIN permit_plate_events ON permit_plate_events.permit_id = permits.id").
+		Where("permit_plate_events.raw_plate_event_id = ?", id).
+		Find(&permits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permits of event"})
 		return
 	}
 
-	var permits []models.Permit
-	err := repository.DB.Joins("JOIN permit_plate_events ON permit_plate_events.permit_id = permits.id").
-		Where("permit_plate_events.raw_plate_event_id = ?", id).
-		Find(&permits).Error
+	userID := c.GetHeader("X-User-ID")
+	var updatedPermits []models.Permit
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RawPlateEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"plate_corrected": input.PlateCorrected,
+			"corrected_by":    userID,
+			"is_manual":       true,
+		}).Error; err != nil {
+			return err
+		}
 
-	if err == nil {
 		for _, permit := range permits {
 			updated := false
 			if permit.PlateFront == oldEffectivePlate {
@@ -82,10 +126,35 @@ func HandlePatchPlateEvent(c *gin.Context) {
 				updated = true
 			}
 			if updated {
-				repository.DB.Save(&permit)
+				if err := tx.Save(&permit).Error; err != nil {
+					return err
+				}
+				updatedPermits = append(updatedPermits, permit)
 			}
 		}
+
+		return logic.AppendOutbox(tx, logic.EventPlateCorrected, "plate_event", event.ID, gin.H{
+			"plate_event_id":  event.ID,
+			"plate":           event.Plate,
+			"plate_corrected": input.PlateCorrected,
+			"corrected_by":    userID,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	logic.WakeOutboxRelay()
+
+	for _, permit := range updatedPermits {
+		logic.PublishLive(logic.LivePermit, "updated", nil, permit)
 	}
+	logic.PublishLive(logic.LivePlate, "corrected", logic.PlateEventGateID(&event), gin.H{
+		"id":              event.ID,
+		"plate":           event.Plate,
+		"plate_corrected": input.PlateCorrected,
+		"corrected_by":    userID,
+	})
 	c.Status(200)
 }
 
@@ -96,7 +165,7 @@ func HandleGetPlateEvents(c *gin.Context) {
 
 	repository.DB.Model(&models.RawPlateEvent{}).Count(&total)
 
-	if err := repository.DB.Limit(limit).Offset(offset).Order("created_at desc").Find(&events).Error; err != nil {
+	if err := repository.DB.Preload("Images").Limit(limit).Offset(offset).Order("created_at desc").Find(&events).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
 		return
 	}
@@ -106,7 +175,7 @@ func HandleGetPlateEvents(c *gin.Context) {
 func HandleGetPlateEventByID(c *gin.Context) {
 	id := c.Param("id")
 	var event models.RawPlateEvent
-	if err := repository.DB.First(&event, id).Error; err != nil {
+	if err := repository.DB.Preload("Images").First(&event, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
@@ -126,7 +195,22 @@ func HandleWeightEvent(c *gin.Context) {
 		event.SystemEventID = sysEventID.(uint)
 	}
 
+	event.IdempotencyKey = idempotencyKey(c, event.IdempotencyKey)
+	if event.IdempotencyKey != nil {
+		var existing models.RawWeightEvent
+		if findByIdempotencyKey(&existing, *event.IdempotencyKey) {
+			respondDuplicateEvent(c, existing.ID, existing)
+			return
+		}
+	}
+
 	if err := repository.DB.Create(&event).Error; err != nil {
+		// A concurrent retry with the same key may have won the insert.
+		var existing models.RawWeightEvent
+		if event.IdempotencyKey != nil && findByIdempotencyKey(&existing, *event.IdempotencyKey) {
+			respondDuplicateEvent(c, existing.ID, existing)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record weight"})
 		return
 	}
@@ -193,7 +277,7 @@ func HandleGetGateEvents(c *gin.Context) {
 
 	if err := repository.DB.Limit(limit).Offset(offset).Order("created_at desc").
 		Preload("Gate").
-		Preload("WeightEvents").Preload("PlateEvents").
+		Preload("WeightEvents").Preload("PlateEvents.Images").
 		Find(&events).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch gate events"})
 		return
@@ -204,7 +288,7 @@ func HandleGetGateEvents(c *gin.Context) {
 func HandleGetGateEventByID(c *gin.Context) {
 	id := c.Param("id")
 	var event models.GateEvent
-	if err := repository.DB.Preload("Gate").Preload("WeightEvents").Preload("PlateEvents").First(&event, id).Error; err != nil {
+	if err := repository.DB.Preload("Gate").Preload("WeightEvents").Preload("PlateEvents.Images").First(&event, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Gate event not found"})
 		return
 	}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// idempotencyKey prefers the Idempotency-Key header over the idempotency_key
+// body field. Adapters send the ingestor's event ID so retries and DLQ
+// replays of the same reading carry the same key.
+func idempotencyKey(c *gin.Context, bodyKey *string) *string {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" && bodyKey != nil {
+		key = strings.TrimSpace(*bodyKey)
+	}
+	if key == "" {
+		return nil
+	}
+	return &key
+}
+
+// findByIdempotencyKey loads the event previously stored under key, including
+// soft-deleted rows since the unique index still covers them.
+func findByIdempotencyKey(dest interface{}, key string) bool {
+	return repository.DB.Unscoped().Where("idempotency_key = ?", key).First(dest).Error == nil
+}
+
+// respondDuplicateEvent returns the original record and drops the system event
+// the logging middleware wrote for the repeated request.
+func respondDuplicateEvent(c *gin.Context, id uint, original interface{}) {
+	if sysEventID, exists := c.Get("system_event_id"); exists {
+		repository.DB.Unscoped().Delete(&models.SystemEvent{}, sysEventID.(uint))
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "duplicate", "id": id, "event": original})
+}
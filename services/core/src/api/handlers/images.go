@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// imageURLTTL is how long presigned evidence URLs stay valid (IMAGE_URL_TTL).
+var imageURLTTL = func() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IMAGE_URL_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 5 * time.Minute
+}()
+
+type evidenceImage struct {
+	models.PlateEventImage
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// plateEventImages falls back to ImageKey for events stored before
+// attachments were tracked individually.
+func plateEventImages(event *models.RawPlateEvent) []models.PlateEventImage {
+	if len(event.Images) > 0 || event.ImageKey == "" {
+		return event.Images
+	}
+	return []models.PlateEventImage{{RawPlateEventID: event.ID, Role: models.ImageRoleImage, Key: event.ImageKey}}
+}
+
+func presignImages(c *gin.Context, images []models.PlateEventImage) ([]evidenceImage, error) {
+	result := make([]evidenceImage, 0, len(images))
+	expires := time.Now().Add(imageURLTTL)
+	for _, img := range images {
+		u, err := repository.MinioPresignClient.PresignedGetObject(c.Request.Context(), repository.BucketName, img.Key, imageURLTTL, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, evidenceImage{PlateEventImage: img, URL: u.String(), ExpiresAt: expires})
+	}
+	return result, nil
+}
+
+// HandleGetPlateEventImage returns presigned URLs for a plate event's images.
+// ?role= narrows to one attachment; ?download=true streams it through core
+// instead, for clients that cannot reach MinIO.
+func HandleGetPlateEventImage(c *gin.Context) {
+	var event models.RawPlateEvent
+	if err := repository.DB.Preload("Images").First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	images := plateEventImages(&event)
+	if role := c.Query("role"); role != "" {
+		var filtered []models.PlateEventImage
+		for _, img := range images {
+			if img.Role == role {
+				filtered = append(filtered, img)
+			}
+		}
+		images = filtered
+	}
+	if len(images) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event has no image"})
+		return
+	}
+
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		streamImage(c, images[0])
+		return
+	}
+
+	result, err := presignImages(c, images)
+	if err != nil {
+		log.Printf("Failed to presign images for plate event %d: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign image URLs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plate_event_id": event.ID, "images": result})
+}
+
+// HandleGetPermitImages returns presigned URLs for every image captured at the
+// permit's gate events.
+func HandleGetPermitImages(c *gin.Context) {
+	var permit models.Permit
+	if err := repository.DB.Preload("GateEvents.PlateEvents.Images").First(&permit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permit not found"})
+		return
+	}
+
+	var images []models.PlateEventImage
+	for _, ge := range permit.GateEvents {
+		for i := range ge.PlateEvents {
+			images = append(images, plateEventImages(&ge.PlateEvents[i])...)
+		}
+	}
+
+	result, err := presignImages(c, images)
+	if err != nil {
+		log.Printf("Failed to presign images for permit %d: %v", permit.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign image URLs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"permit_id": permit.ID, "images": result})
+}
+
+func streamImage(c *gin.Context, img models.PlateEventImage) {
+	obj, err := repository.MinioClient.GetObject(c.Request.Context(), repository.BucketName, img.Key, minio.GetObjectOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		return
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image no longer stored"})
+			return
+		}
+		log.Printf("Failed to fetch image %s: %v", img.Key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, obj, nil)
+}
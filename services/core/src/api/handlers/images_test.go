@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// fakeMinio serves objects of one bucket the way MinIO does. Keys under
+// "private/" answer AccessDenied.
+func fakeMinio(t *testing.T, bucket string, objects map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")
+		if r.Header.Get("Authorization") == "" && r.URL.Query().Get("X-Amz-Signature") == "" {
+			s3Error(w, http.StatusForbidden, "AccessDenied", bucket, key)
+			return
+		}
+		if strings.HasPrefix(key, "private/") {
+			s3Error(w, http.StatusForbidden, "AccessDenied", bucket, key)
+			return
+		}
+		body, ok := objects[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method != http.MethodHead {
+			io.WriteString(w, body)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func s3Error(w http.ResponseWriter, status int, code, bucket, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message><BucketName>%s</BucketName><Key>%s</Key><RequestId>1</RequestId></Error>`, code, code, bucket, key)
+}
+
+// useMinio points both repository clients at srv for the test.
+func useMinio(t *testing.T, srv *httptest.Server, bucket string) {
+	t.Helper()
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("access", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldClient, oldPresign, oldBucket := repository.MinioClient, repository.MinioPresignClient, repository.BucketName
+	repository.MinioClient, repository.MinioPresignClient, repository.BucketName = client, client, bucket
+	t.Cleanup(func() {
+		repository.MinioClient, repository.MinioPresignClient, repository.BucketName = oldClient, oldPresign, oldBucket
+	})
+}
+
+func testContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	return c, w
+}
+
+func TestStreamImage(t *testing.T) {
+	srv := fakeMinio(t, "evidence", map[string]string{"2024/plate.jpg": "jpeg bytes"})
+	useMinio(t, srv, "evidence")
+
+	cases := []struct {
+		key    string
+		status int
+		body   string
+	}{
+		{"2024/plate.jpg", http.StatusOK, "jpeg bytes"},
+		{"2024/gone.jpg", http.StatusNotFound, "Image no longer stored"},
+		{"private/plate.jpg", http.StatusInternalServerError, "Failed to fetch image"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			c, w := testContext()
+			streamImage(c, models.PlateEventImage{Key: tc.key})
+			if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.body) {
+				t.Fatalf("got %d %q, want %d containing %q", w.Code, w.Body.String(), tc.status, tc.body)
+			}
+			if tc.status == http.StatusOK {
+				if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+					t.Errorf("Content-Type = %q", ct)
+				}
+				if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "private") {
+					t.Errorf("Cache-Control = %q", cc)
+				}
+			}
+		})
+	}
+}
+
+func TestPresignImages(t *testing.T) {
+	srv := fakeMinio(t, "evidence", map[string]string{"2024/plate.jpg": "jpeg bytes"})
+	useMinio(t, srv, "evidence")
+
+	c, _ := testContext()
+	images := []models.PlateEventImage{
+		{Role: models.ImageRolePlate, Key: "2024/plate.jpg"},
+		{Role: models.ImageRoleImage, Key: "2024/gone.jpg"},
+	}
+	before := time.Now()
+	result, err := presignImages(c, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("got %d URLs, want 2", len(result))
+	}
+	for i, img := range result {
+		if img.Role != images[i].Role || img.Key != images[i].Key {
+			t.Errorf("result %d is %s %s, want %s %s", i, img.Role, img.Key, images[i].Role, images[i].Key)
+		}
+		if img.ExpiresAt.Before(before.Add(imageURLTTL)) || img.ExpiresAt.After(time.Now().Add(imageURLTTL)) {
+			t.Errorf("expires_at %s is not %s from now", img.ExpiresAt, imageURLTTL)
+		}
+		u, err := url.Parse(img.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if u.Path != "/evidence/"+images[i].Key || q.Get("X-Amz-Expires") != fmt.Sprint(int(imageURLTTL.Seconds())) ||
+			!strings.HasPrefix(q.Get("X-Amz-Credential"), "access/") || q.Get("X-Amz-Signature") == "" {
+			t.Errorf("URL %s is not a presigned GET of %s", img.URL, images[i].Key)
+		}
+	}
+
+	// Presigning never looks at the object; only fetching the URL does.
+	resp, err := http.Get(result[0].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "jpeg bytes" {
+		t.Errorf("fetching the presigned URL gave %d %q", resp.StatusCode, body)
+	}
+	resp, err = http.Get(result[1].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("fetching a presigned URL of a missing object gave %d", resp.StatusCode)
+	}
+}
+
+func TestPlateEventImagesFallsBackToImageKey(t *testing.T) {
+	legacy := &models.RawPlateEvent{ImageKey: "old.jpg"}
+	legacy.ID = 7
+	got := plateEventImages(legacy)
+	if len(got) != 1 || got[0].Key != "old.jpg" || got[0].Role != models.ImageRoleImage || got[0].RawPlateEventID != 7 {
+		t.Errorf("legacy event images = %+v", got)
+	}
+
+	tracked := &models.RawPlateEvent{ImageKey: "old.jpg", Images: []models.PlateEventImage{{Role: models.ImageRolePlate, Key: "crop.jpg"}}}
+	if got := plateEventImages(tracked); len(got) != 1 || got[0].Key != "crop.jpg" {
+		t.Errorf("tracked event images = %+v", got)
+	}
+	if got := plateEventImages(&models.RawPlateEvent{}); len(got) != 0 {
+		t.Errorf("event without images = %+v", got)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/api/clients"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// createDeviceWithKey provisions the device's API key in auth and then runs
+// save with the key's source ID. If save fails the key is deleted again, so
+// auth is not left with a key for a device core does not know.
+func createDeviceWithKey(c *gin.Context, kind, name, saveError string, save func(sourceID string) error) (string, bool) {
+	authClient := clients.NewAuthClient()
+	if authClient == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Auth client not configured"})
+		return "", false
+	}
+
+	saga := logic.NewSaga(fmt.Sprintf("create %s %q", kind, name))
+	sourceID, apiKey, err := provisionDeviceKey(c, saga, authClient, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create authentication key: " + err.Error()})
+		return "", false
+	}
+
+	if err := saga.Do("save "+kind, func() error { return save(sourceID) }, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": saveError})
+		return "", false
+	}
+	return apiKey, true
+}
+
+// provisionDeviceKey creates a device API key as a step of saga, so a later
+// failing step deletes it again.
+func provisionDeviceKey(c *gin.Context, saga *logic.Saga, authClient *clients.AuthClient, name string) (sourceID, apiKey string, err error) {
+	authHeader, apiKeyHeader := c.GetHeader("Authorization"), c.GetHeader("X-Api-Key")
+	err = saga.Do("create API key for "+name, func() error {
+		authResp, err := authClient.CreateApiKey(c.Request.Context(), name+"_key", []string{"create:ingest"}, authHeader, apiKeyHeader)
+		if err != nil {
+			return err
+		}
+		sourceID, apiKey = authResp.SourceID, authResp.APIKey
+		if sourceID == "" {
+			sourceID = fmt.Sprintf("%v", authResp.ID)
+		}
+		return nil
+	}, func(ctx context.Context) error {
+		return authClient.DeleteSourceKeys(ctx, sourceID, authHeader, apiKeyHeader)
+	})
+	return sourceID, apiKey, err
+}
+
+// deleteDeviceWithKeys soft-deletes the device and then revokes its keys. If
+// auth fails the device is restored, so a device is never left without keys
+// and keys never outlive their device.
+func deleteDeviceWithKeys(c *gin.Context, kind string, config interface{}, sourceID, deleteError string) bool {
+	saga := logic.NewSaga(fmt.Sprintf("delete %s %s", kind, sourceID))
+	err := saga.Do("delete "+kind, func() error {
+		return repository.DB.Delete(config).Error
+	}, func(ctx context.Context) error {
+		return repository.DB.WithContext(ctx).Unscoped().Model(config).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": deleteError})
+		return false
+	}
+	if sourceID == "" {
+		return true
+	}
+
+	authClient := clients.NewAuthClient()
+	err = saga.Do("delete API keys", func() error {
+		if authClient == nil {
+			return errors.New("auth client not configured")
+		}
+		return authClient.DeleteSourceKeys(c.Request.Context(), sourceID, c.GetHeader("Authorization"), c.GetHeader("X-Api-Key"))
+	}, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated API keys: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func HandleRotateCameraKey(c *gin.Context) {
+	var config models.CameraConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Camera config not found"})
+		return
+	}
+	rotateDeviceKey(c, config.SourceID)
+}
+
+func HandleRotateScaleKey(c *gin.Context) {
+	var config models.ScaleConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scale configuration not found"})
+		return
+	}
+	rotateDeviceKey(c, config.SourceID)
+}
+
+// rotateDeviceKey replaces the device's API key. The source ID stays the
+// same, so events, health and config lookups are unaffected; the old key
+// keeps working for the grace period while the device is reconfigured.
+func rotateDeviceKey(c *gin.Context, sourceID string) {
+	var input struct {
+		GracePeriodSeconds *int `json:"grace_period_seconds"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	grace := logic.SettingInt(logic.SettingDeviceKeyGraceSeconds, 86400)
+	if input.GracePeriodSeconds != nil {
+		grace = *input.GracePeriodSeconds
+	}
+	if grace < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period_seconds must not be negative"})
+		return
+	}
+
+	if sourceID == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device has no API key"})
+		return
+	}
+	authClient := clients.NewAuthClient()
+	if authClient == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Auth client not configured"})
+		return
+	}
+
+	authResp, err := authClient.RotateSourceKey(
+		c.Request.Context(),
+		sourceID,
+		time.Duration(grace)*time.Second,
+		c.GetHeader("Authorization"),
+		c.GetHeader("X-Api-Key"),
+	)
+	if errors.Is(err, clients.ErrSourceNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device has no active API key"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"source_id":               sourceID,
+		"api_key":                 authResp.APIKey,
+		"previous_keys_expire_at": authResp.PreviousKeysExpireAt,
+	})
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
 	"github.com/truckguard/core/src/repository"
 	"github.com/truckguard/core/src/utils"
@@ -26,7 +28,7 @@ func HandleGetPermits(c *gin.Context) {
 	query.Limit(limit).Offset(offset).Order("created_at desc").
 		Preload("GateEvents").
 		Preload("GateEvents.Gate").
-		Preload("GateEvents.PlateEvents").
+		Preload("GateEvents.PlateEvents.Images").
 		Preload("GateEvents.WeightEvents").
 		Find(&permits)
 
@@ -39,7 +41,7 @@ func HandleGetPermitByID(c *gin.Context) {
 	if err := repository.DB.
 		Preload("GateEvents").
 		Preload("GateEvents.Gate").
-		Preload("GateEvents.PlateEvents").
+		Preload("GateEvents.PlateEvents.Images").
 		Preload("GateEvents.WeightEvents").
 		First(&permit, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Permit not found"})
@@ -48,3 +50,71 @@ func HandleGetPermitByID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, permit)
 }
+
+// HandleSetPermitLegalHold flags a permit so retention never purges its
+// evidence, or releases the flag.
+func HandleSetPermitLegalHold(c *gin.Context) {
+	var input struct {
+		LegalHold *bool  `json:"legal_hold" binding:"required"`
+		Reason    string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var permit models.Permit
+	if err := repository.DB.First(&permit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permit not found"})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"legal_hold":        *input.LegalHold,
+		"legal_hold_reason": "",
+		"legal_hold_by":     "",
+	}
+	if *input.LegalHold {
+		updates["legal_hold_reason"] = input.Reason
+		updates["legal_hold_by"] = c.GetHeader("X-User-ID")
+	}
+	if err := repository.DB.Model(&permit).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update legal hold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permit)
+}
+
+// HandleSetPermitFlow lets an operator choose the flow an open permit follows,
+// for instance when its entry gate is shared by several flows and neither a
+// booking nor the carrier decided it.
+func HandleSetPermitFlow(c *gin.Context) {
+	var input struct {
+		FlowID *uint `json:"flow_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var permit models.Permit
+	if err := repository.DB.First(&permit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permit not found"})
+		return
+	}
+
+	if err := logic.SetPermitFlow(&permit, *input.FlowID); err != nil {
+		switch {
+		case errors.Is(err, logic.ErrFlowNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, logic.ErrPermitClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set flow"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, permit)
+}
@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
 	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
 	"github.com/truckguard/core/src/repository"
 	"github.com/truckguard/core/src/utils"
 )
@@ -35,6 +37,12 @@ func HandleCreatePreset(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	mapping, err := validateMapping(preset.Format, preset.FieldMapping, payloadparser.FieldPlate)
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return
+	}
+	preset.FieldMapping = mapping
 	if err := repository.DB.Create(&preset).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create preset"})
 		return
@@ -55,8 +63,15 @@ func HandleUpdatePreset(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	mapping, err := validateMapping(preset.Format, preset.FieldMapping, payloadparser.FieldPlate)
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return
+	}
+	preset.FieldMapping = mapping
 
 	repository.DB.Save(&preset)
+	logic.PublishCameraPresetChange(preset.ID)
 	c.JSON(http.StatusOK, preset)
 }
 
@@ -75,3 +90,83 @@ func HandleDeletePreset(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
 }
+
+func HandleListScalePresets(c *gin.Context) {
+	var presets []models.ScalePreset
+	var total int64
+	repository.DB.Model(&models.ScalePreset{}).Count(&total)
+
+	limit, offset, page := utils.GetPagination(c)
+	repository.DB.Limit(limit).Offset(offset).Find(&presets)
+	utils.SendPaginatedResponse(c, presets, total, page, limit)
+}
+
+func HandleGetScalePreset(c *gin.Context) {
+	id := c.Param("id")
+	var preset models.ScalePreset
+	if err := repository.DB.First(&preset, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return
+	}
+	c.JSON(http.StatusOK, preset)
+}
+
+func HandleCreateScalePreset(c *gin.Context) {
+	var preset models.ScalePreset
+	if err := c.ShouldBindJSON(&preset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mapping, err := validateMapping(preset.Format, preset.FieldMapping, payloadparser.FieldWeight)
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return
+	}
+	preset.FieldMapping = mapping
+	if err := repository.DB.Create(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create preset"})
+		return
+	}
+	c.JSON(http.StatusCreated, preset)
+}
+
+func HandleUpdateScalePreset(c *gin.Context) {
+	id := c.Param("id")
+	var preset models.ScalePreset
+
+	if err := repository.DB.First(&preset, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&preset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mapping, err := validateMapping(preset.Format, preset.FieldMapping, payloadparser.FieldWeight)
+	if err != nil {
+		respondMappingError(c, http.StatusBadRequest, err)
+		return
+	}
+	preset.FieldMapping = mapping
+
+	repository.DB.Save(&preset)
+	logic.PublishScalePresetChange(preset.ID)
+	c.JSON(http.StatusOK, preset)
+}
+
+func HandleDeleteScalePreset(c *gin.Context) {
+	id := c.Param("id")
+	var count int64
+	repository.DB.Model(&models.ScaleConfig{}).Where("preset_id = ?", id).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete preset: it is used by active scales"})
+		return
+	}
+
+	if err := repository.DB.Delete(&models.ScalePreset{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/api/clients"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/repository"
+	"github.com/truckguard/core/src/siteconfig"
+	"gorm.io/gorm"
+)
+
+const maxSiteConfigSize = 5 << 20
+
+// HandleExportConfig returns the site's topology as a siteconfig document,
+// YAML by default or JSON with ?format=json.
+func HandleExportConfig(c *gin.Context) {
+	doc, err := siteconfig.Export(repository.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export configuration"})
+		return
+	}
+	data, contentType, err := siteconfig.Encode(doc, c.DefaultQuery("format", siteconfig.FormatYAML))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ext := siteconfig.FormatYAML
+	if contentType == "application/json" {
+		ext = siteconfig.FormatJSON
+	}
+	c.Header("Content-Disposition", `attachment; filename="site-config.`+ext+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// HandleImportConfig upserts a YAML or JSON siteconfig document by natural
+// key. With ?dry_run=true it only returns the plan. Otherwise API keys are
+// issued for new devices and the whole document is applied in one
+// transaction; if that fails nothing is written and the keys are deleted.
+func HandleImportConfig(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSiteConfigSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body) > maxSiteConfigSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document too large"})
+		return
+	}
+	doc, err := siteconfig.Decode(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := siteconfig.NewPlan(repository.DB, doc)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "plan": plan})
+		return
+	}
+
+	saga := logic.NewSaga("import site configuration")
+	sourceIDs := map[string]string{}
+	apiKeys := map[string]string{}
+	if newDevices := plan.NewDevices(); len(newDevices) > 0 {
+		authClient := clients.NewAuthClient()
+		if authClient == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Auth client not configured"})
+			return
+		}
+		for _, d := range newDevices {
+			sourceID, apiKey, err := provisionDeviceKey(c, saga, authClient, d.Key)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create authentication key for " + d.Key + ": " + err.Error()})
+				return
+			}
+			key := siteconfig.ObjectKey(d.Kind, d.Key)
+			sourceIDs[key] = sourceID
+			apiKeys[key] = apiKey
+		}
+	}
+
+	var result *siteconfig.Result
+	err = saga.Do("apply configuration", func() error {
+		return repository.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			result, err = siteconfig.Apply(tx, doc, sourceIDs)
+			return err
+		})
+	}, nil)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	for _, id := range result.CameraPresets {
+		logic.PublishCameraPresetChange(id)
+	}
+	for _, id := range result.ScalePresets {
+		logic.PublishScalePresetChange(id)
+	}
+	for _, config := range result.Cameras {
+		logic.PublishCameraConfigChange(config)
+	}
+	for _, config := range result.Scales {
+		logic.PublishScaleConfigChange(config)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": false, "plan": result.Plan, "api_keys": apiKeys})
+}
+
+func respondImportError(c *gin.Context, err error) {
+	var verr *siteconfig.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid site configuration", "details": verr.Errors})
+	case errors.Is(err, siteconfig.ErrConcurrentChange):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import configuration: " + err.Error()})
+	}
+}
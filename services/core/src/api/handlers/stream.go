@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/repository"
+)
+
+// HandleEventStream pushes live updates to operators as Server-Sent Events.
+// Optional filters: ?gate_id=1,2 and ?type=plate,weight,gate_event,permit,alert
+func HandleEventStream(c *gin.Context) {
+	gateFilter := map[uint]bool{}
+	for _, raw := range splitQuery(c.Query("gate_id")) {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gate_id: " + raw})
+			return
+		}
+		gateFilter[uint(id)] = true
+	}
+	typeFilter := map[string]bool{}
+	for _, t := range splitQuery(c.Query("type")) {
+		typeFilter[t] = true
+	}
+
+	ctx := c.Request.Context()
+	sub := repository.RDB.Subscribe(ctx, logic.LiveChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Live feed unavailable"})
+		return
+	}
+	messages := sub.Channel()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", gin.H{"at": time.Now()})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepAlive.C:
+			c.SSEvent("ping", gin.H{"at": time.Now()})
+			return true
+		case msg, ok := <-messages:
+			if !ok {
+				return false
+			}
+			var event logic.LiveEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				return true
+			}
+			if len(typeFilter) > 0 && !typeFilter[event.Type] {
+				return true
+			}
+			if len(gateFilter) > 0 && (event.GateID == nil || !gateFilter[*event.GateID]) {
+				return true
+			}
+			c.SSEvent(event.Type, msg.Payload)
+			return true
+		}
+	})
+}
+
+func splitQuery(raw string) []string {
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"github.com/truckguard/core/src/repository"
+)
+
+// testParseInput optionally overrides the stored format and mapping so a
+// change can be tried before it is saved.
+type testParseInput struct {
+	Payload      string                 `json:"payload" binding:"required"`
+	Format       *string                `json:"format"`
+	FieldMapping *payloadparser.Mapping `json:"field_mapping"`
+}
+
+// validateMapping rejects a Format/FieldMapping pair the adapters could not
+// use and returns the mapping in the canonical form it is stored in. fields
+// lists the mapping keys the device type supports.
+func validateMapping(format string, m payloadparser.Mapping, fields ...string) (payloadparser.Mapping, error) {
+	if err := payloadparser.Validate(format, m, fields...); err != nil {
+		return nil, err
+	}
+	return m.Normalize(format), nil
+}
+
+// respondMappingError reports an invalid mapping, with the location of each
+// problem when the validator found them.
+func respondMappingError(c *gin.Context, status int, err error) {
+	var verr *payloadparser.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(status, gin.H{"error": "Invalid field mapping", "details": verr.Errors})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+func HandleTestParseCamera(c *gin.Context) {
+	var config models.CameraConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Camera config not found"})
+		return
+	}
+	eff, err := logic.ResolveCameraConfig(config)
+	if err != nil {
+		respondResolveError(c, err)
+		return
+	}
+	testParse(c, eff.Format, eff.FieldMapping, payloadparser.FieldPlate)
+}
+
+func HandleTestParseScale(c *gin.Context) {
+	var config models.ScaleConfig
+	if err := repository.DB.First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scale config not found"})
+		return
+	}
+	eff, err := logic.ResolveScaleConfig(config)
+	if err != nil {
+		respondResolveError(c, err)
+		return
+	}
+	testParse(c, eff.Format, eff.FieldMapping, payloadparser.FieldWeight)
+}
+
+// testParse runs a sample payload through the effective mapping the way the
+// adapter would. A payload that yields nothing is still a 200 with ok=false and the
+// reason; only an unusable mapping is a client error.
+func testParse(c *gin.Context, format string, mapping payloadparser.Mapping, field string) {
+	var input testParseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Format != nil {
+		format = *input.Format
+	}
+	if input.FieldMapping != nil {
+		mapping = *input.FieldMapping
+	}
+	format = payloadparser.NormalizeFormat(format)
+
+	mapping, err := validateMapping(format, mapping, field)
+	if err != nil {
+		respondMappingError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result := gin.H{
+		"format":        format,
+		"field_mapping": mapping,
+		"field":         field,
+	}
+	if format != payloadparser.FormatRegex {
+		// The decoded document helps find the right path.
+		if doc, err := payloadparser.Decode(input.Payload, format); err == nil {
+			result["document"] = doc
+		}
+	}
+
+	raw, err := payloadparser.Extract(input.Payload, format, mapping, field)
+	if err != nil {
+		result["ok"] = false
+		result["error"] = err.Error()
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	result["raw_value"] = raw
+
+	switch field {
+	case payloadparser.FieldPlate:
+		result[field], err = payloadparser.ExtractPlate(input.Payload, format, mapping)
+	case payloadparser.FieldWeight:
+		result[field], err = payloadparser.ExtractWeight(input.Payload, format, mapping)
+	}
+	result["ok"] = err == nil
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"github.com/truckguard/core/src/utils"
+)
+
+func HandleGetUnmatchedEvents(c *gin.Context) {
+	var events []models.UnmatchedEvent
+	var total int64
+	limit, offset, page := utils.GetPagination(c)
+
+	query := repository.DB.Model(&models.UnmatchedEvent{})
+	if status := c.DefaultQuery("status", models.UnmatchedStatusOpen); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	query.Count(&total)
+
+	if err := query.Limit(limit).Offset(offset).Order("created_at desc").
+		Preload("PlateEvent").
+		Preload("WeightEvent").
+		Preload("GateEvent").
+		Preload("GateEvent.Gate").
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch unmatched events"})
+		return
+	}
+	utils.SendPaginatedResponse(c, events, total, page, limit)
+}
+
+func HandleGetUnmatchedEventByID(c *gin.Context) {
+	id := c.Param("id")
+	var event models.UnmatchedEvent
+	if err := repository.DB.
+		Preload("PlateEvent").
+		Preload("WeightEvent").
+		Preload("GateEvent").
+		Preload("GateEvent.Gate").
+		Preload("GateEvent.PlateEvents.Images").
+		Preload("GateEvent.WeightEvents").
+		First(&event, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unmatched event not found"})
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func HandleAttachUnmatchedEvent(c *gin.Context) {
+	var input struct {
+		PermitID uint  `json:"permit_id" binding:"required"`
+		GateID   *uint `json:"gate_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.UnmatchedEvent
+	if err := repository.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unmatched event not found"})
+		return
+	}
+
+	if err := logic.AttachUnmatchedToPermit(&event, input.PermitID, input.GateID, c.GetHeader("X-User-ID")); err != nil {
+		respondUnmatchedError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func HandleAssignPlateUnmatchedEvent(c *gin.Context) {
+	var input struct {
+		Plate  string `json:"plate" binding:"required"`
+		GateID *uint  `json:"gate_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.UnmatchedEvent
+	if err := repository.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unmatched event not found"})
+		return
+	}
+
+	permit, err := logic.AssignPlateToUnmatched(&event, input.Plate, input.GateID, c.GetHeader("X-User-ID"))
+	if err != nil {
+		respondUnmatchedError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unmatched_event": event, "permit": permit})
+}
+
+func HandleDismissUnmatchedEvent(c *gin.Context) {
+	var input struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.UnmatchedEvent
+	if err := repository.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unmatched event not found"})
+		return
+	}
+
+	if err := logic.DismissUnmatched(&event, input.Note, c.GetHeader("X-User-ID")); err != nil {
+		respondUnmatchedError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
+func respondUnmatchedError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, logic.ErrUnmatchedClosed), errors.Is(err, logic.ErrNoOpenPermit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, logic.ErrGateRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, logic.ErrGateNotFound), errors.Is(err, logic.ErrPermitNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve unmatched event"})
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"github.com/truckguard/core/src/utils"
+)
+
+type webhookInput struct {
+	Name       string `json:"name"`
+	URL        string `json:"url" binding:"required"`
+	EventTypes string `json:"event_types" binding:"required"`
+	Secret     string `json:"secret"`
+	IsActive   *bool  `json:"is_active"`
+}
+
+// validate checks the target URL and returns the normalised event type list.
+func (in *webhookInput) validate() (string, error) {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("url must be an absolute http(s) URL")
+	}
+	return logic.ParseWebhookEventTypes(in.EventTypes)
+}
+
+func HandleListWebhooks(c *gin.Context) {
+	var subs []models.WebhookSubscription
+	if err := repository.DB.Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func HandleGetWebhook(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := repository.DB.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func HandleCreateWebhook(c *gin.Context) {
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventTypes, err := input.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		rb := make([]byte, 32)
+		rand.Read(rb)
+		secret = hex.EncodeToString(rb)
+	}
+
+	sub := models.WebhookSubscription{
+		Name:       input.Name,
+		URL:        input.URL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		IsActive:   input.IsActive == nil || *input.IsActive,
+	}
+	if err := repository.DB.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": sub,
+		"secret":  secret,
+	})
+}
+
+func HandleUpdateWebhook(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := repository.DB.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	eventTypes, err := input.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub.Name = input.Name
+	sub.URL = input.URL
+	sub.EventTypes = eventTypes
+	if input.Secret != "" {
+		sub.Secret = input.Secret
+	}
+	if input.IsActive != nil {
+		sub.IsActive = *input.IsActive
+	}
+
+	if err := repository.DB.Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func HandleDeleteWebhook(c *gin.Context) {
+	if err := repository.DB.Delete(&models.WebhookSubscription{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func HandleListWebhookDeliveries(c *gin.Context) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+	limit, offset, page := utils.GetPagination(c)
+
+	query := repository.DB.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+
+	if err := query.Limit(limit).Offset(offset).Order("created_at desc").Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook deliveries"})
+		return
+	}
+	utils.SendPaginatedResponse(c, deliveries, total, page, limit)
+}
+
+func HandleRedeliverWebhook(c *gin.Context) {
+	var original models.WebhookDelivery
+	if err := repository.DB.Where("subscription_id = ?", c.Param("id")).
+		First(&original, c.Param("delivery_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+
+	delivery, err := logic.RedeliverWebhook(&original)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue redelivery"})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
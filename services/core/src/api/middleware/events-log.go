@@ -24,10 +24,14 @@ func SystemEventLogger(eventType string) gin.HandlerFunc {
 			sourceID = c.GetHeader("X-Scale-ID")
 		}
 
+		// Prefer the device's capture time, then the adapter's event time.
 		ts := time.Now()
-		if tsStr, ok := body["timestamp"].(string); ok {
-			if parsed, err := time.Parse(time.RFC3339, tsStr); err == nil {
-				ts = parsed
+		for _, field := range []string{"captured_at", "timestamp"} {
+			if tsStr, ok := body[field].(string); ok {
+				if parsed, err := time.Parse(time.RFC3339Nano, tsStr); err == nil {
+					ts = parsed
+					break
+				}
 			}
 		}
 
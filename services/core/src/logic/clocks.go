@@ -0,0 +1,108 @@
+package logic
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// clockSkewSmoothing weights a new sample in DeviceClock.SkewMs so a single
+// slow network hop does not flip the drift state.
+const clockSkewSmoothing = 0.2
+
+// eventTime picks the time an event is matched on and records the device's
+// clock skew. Live events give a skew sample (capture minus receive time).
+// The capture time is trusted unless the device is drifting, in which case
+// it is corrected by the device's smoothed skew; buffered events rely on
+// that correction since their receive time says nothing about the clock.
+func eventTime(deviceType, sourceID string, gateID *uint, fallback time.Time, capturedAt, receivedAt *time.Time, buffered bool) (time.Time, *int64) {
+	if capturedAt == nil {
+		if receivedAt != nil {
+			return *receivedAt, nil
+		}
+		return fallback, nil
+	}
+
+	var sample *int64
+	if !buffered && receivedAt != nil {
+		ms := capturedAt.Sub(*receivedAt).Milliseconds()
+		sample = &ms
+	}
+
+	clock, err := observeClockSkew(deviceType, sourceID, gateID, sample)
+	if err != nil {
+		log.Printf("Failed to track clock of %s %s: %v", deviceType, sourceID, err)
+		return *capturedAt, sample
+	}
+	if clock.Drifting {
+		return capturedAt.Add(-time.Duration(clock.SkewMs) * time.Millisecond), sample
+	}
+	return *capturedAt, sample
+}
+
+// observeClockSkew folds sample (if any) into the device's clock record and
+// raises an alert when the device starts or stops drifting beyond the
+// clock_skew_alert_ms setting.
+func observeClockSkew(deviceType, sourceID string, gateID *uint, sample *int64) (*models.DeviceClock, error) {
+	threshold := int64(SettingInt(SettingClockSkewAlertMs, 5000))
+
+	var clock models.DeviceClock
+	var changed bool
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.DeviceClock{DeviceType: deviceType, SourceID: sourceID, GateID: gateID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("device_type = ? AND source_id = ?", deviceType, sourceID).
+			First(&clock).Error; err != nil {
+			return err
+		}
+		if sample == nil {
+			return nil
+		}
+
+		if clock.Samples == 0 {
+			clock.SkewMs = *sample
+		} else {
+			clock.SkewMs += int64(clockSkewSmoothing * float64(*sample-clock.SkewMs))
+		}
+		clock.LastSkewMs = *sample
+		clock.Samples++
+		clock.LastSampleAt = time.Now()
+		clock.GateID = gateID
+
+		// Recover only well inside the threshold so a device hovering
+		// around it does not alert on every event.
+		abs := clock.SkewMs
+		if abs < 0 {
+			abs = -abs
+		}
+		switch {
+		case !clock.Drifting && abs > threshold:
+			clock.Drifting, changed = true, true
+		case clock.Drifting && abs <= threshold/2:
+			clock.Drifting, changed = false, true
+		}
+		return tx.Save(&clock).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed && clock.Drifting {
+		RaiseAlert("clock.skew",
+			fmt.Sprintf("%s %s clock is off by %s; capture times are being corrected", deviceType, sourceID, time.Duration(clock.SkewMs)*time.Millisecond),
+			gateID, clock)
+	} else if changed {
+		RaiseAlert("clock.skew_resolved",
+			fmt.Sprintf("%s %s clock is back within %s", deviceType, sourceID, time.Duration(threshold)*time.Millisecond),
+			gateID, clock)
+	}
+	return &clock, nil
+}
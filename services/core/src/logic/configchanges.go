@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// ConfigChannel is the Redis pub/sub channel adapters subscribe to so they
+// can drop a cached device config as soon as it changes.
+const ConfigChannel = "core:config"
+
+// ConfigChange tells adapters that a device's effective config changed.
+// Version matches the ETag the by-id endpoints serve; it is empty when the
+// device was deleted.
+type ConfigChange struct {
+	DeviceType string    `json:"device_type"`
+	SourceID   string    `json:"source_id"`
+	Version    string    `json:"version,omitempty"`
+	Deleted    bool      `json:"deleted,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// configVersion hashes an effective config, so the version changes exactly
+// when what the adapter runs with changes, whether through the device or
+// its preset.
+func configVersion(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+func PublishCameraConfigChange(config models.CameraConfig) {
+	eff, err := ResolveCameraConfig(config)
+	if err != nil {
+		log.Printf("Failed to resolve camera config %s for change notification: %v", config.SourceID, err)
+		return
+	}
+	publishConfigChange(ConfigChange{DeviceType: models.DeviceTypeCamera, SourceID: config.SourceID, Version: eff.Version})
+}
+
+func PublishScaleConfigChange(config models.ScaleConfig) {
+	eff, err := ResolveScaleConfig(config)
+	if err != nil {
+		log.Printf("Failed to resolve scale config %s for change notification: %v", config.SourceID, err)
+		return
+	}
+	publishConfigChange(ConfigChange{DeviceType: models.DeviceTypeScale, SourceID: config.SourceID, Version: eff.Version})
+}
+
+func PublishConfigDeleted(deviceType, sourceID string) {
+	publishConfigChange(ConfigChange{DeviceType: deviceType, SourceID: sourceID, Deleted: true})
+}
+
+// PublishCameraPresetChange notifies every camera using the preset.
+func PublishCameraPresetChange(presetID uint) {
+	var configs []models.CameraConfig
+	repository.DB.Where("preset_id = ?", presetID).Find(&configs)
+	for _, config := range configs {
+		PublishCameraConfigChange(config)
+	}
+}
+
+func PublishScalePresetChange(presetID uint) {
+	var configs []models.ScaleConfig
+	repository.DB.Where("preset_id = ?", presetID).Find(&configs)
+	for _, config := range configs {
+		PublishScaleConfigChange(config)
+	}
+}
+
+func publishConfigChange(change ConfigChange) {
+	if repository.RDB == nil || change.SourceID == "" {
+		return
+	}
+	change.At = time.Now()
+
+	msg, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("Failed to encode config change for %s %s: %v", change.DeviceType, change.SourceID, err)
+		return
+	}
+	if err := repository.RDB.Publish(context.Background(), ConfigChannel, msg).Err(); err != nil {
+		log.Printf("Failed to publish config change for %s %s: %v", change.DeviceType, change.SourceID, err)
+	}
+}
@@ -0,0 +1,231 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Redis hashes the ingestor keeps of source ID to the unix millisecond time
+// of the last heartbeat and the last accepted event.
+const (
+	lastHeartbeatKey = "devices:last_heartbeat"
+	lastEventKey     = "devices:last_event"
+)
+
+const deviceMonitorInterval = 30 * time.Second
+
+// deviceThresholds is how long a device may stay silent before it counts as
+// degraded and then offline.
+type deviceThresholds struct {
+	degraded time.Duration
+	offline  time.Duration
+}
+
+// RunDeviceMonitor refreshes DeviceHealth for every camera and scale and
+// raises an alert when a device goes offline or comes back.
+func RunDeviceMonitor() {
+	ticker := time.NewTicker(deviceMonitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkDevices(time.Now())
+	}
+}
+
+func checkDevices(now time.Time) {
+	heartbeats, err := readLastSeen(lastHeartbeatKey)
+	if err != nil {
+		log.Printf("Device monitor: failed to read heartbeats: %v", err)
+		return
+	}
+	events, err := readLastSeen(lastEventKey)
+	if err != nil {
+		log.Printf("Device monitor: failed to read device activity: %v", err)
+		return
+	}
+
+	defaults := deviceThresholds{
+		degraded: time.Duration(SettingInt(SettingDeviceDegradedAfterSec, 120)) * time.Second,
+		offline:  time.Duration(SettingInt(SettingDeviceOfflineAfterSec, 600)) * time.Second,
+	}
+	var gates []models.Gate
+	repository.DB.Find(&gates)
+	thresholds := map[uint]deviceThresholds{}
+	for _, g := range gates {
+		t := defaults
+		if g.DegradedAfterSeconds != nil {
+			t.degraded = time.Duration(*g.DegradedAfterSeconds) * time.Second
+		}
+		if g.OfflineAfterSeconds != nil {
+			t.offline = time.Duration(*g.OfflineAfterSeconds) * time.Second
+		}
+		thresholds[g.ID] = t
+	}
+	thresholdsFor := func(gateID *uint) deviceThresholds {
+		if gateID != nil {
+			if t, ok := thresholds[*gateID]; ok {
+				return t
+			}
+		}
+		return defaults
+	}
+
+	var cameras []models.CameraConfig
+	if err := repository.DB.Find(&cameras).Error; err != nil {
+		log.Printf("Device monitor: failed to load cameras: %v", err)
+		return
+	}
+	var scales []models.ScaleConfig
+	if err := repository.DB.Find(&scales).Error; err != nil {
+		log.Printf("Device monitor: failed to load scales: %v", err)
+		return
+	}
+
+	registered := map[string][]string{models.DeviceTypeCamera: nil, models.DeviceTypeScale: nil}
+	for _, cam := range cameras {
+		registered[models.DeviceTypeCamera] = append(registered[models.DeviceTypeCamera], cam.SourceID)
+		updateDeviceHealth(models.DeviceTypeCamera, cam.SourceID, cam.Name, cam.GateID,
+			heartbeats[cam.SourceID], events[cam.SourceID], thresholdsFor(cam.GateID), now)
+	}
+	for _, sc := range scales {
+		registered[models.DeviceTypeScale] = append(registered[models.DeviceTypeScale], sc.SourceID)
+		updateDeviceHealth(models.DeviceTypeScale, sc.SourceID, sc.Name, sc.GateID,
+			heartbeats[sc.SourceID], events[sc.SourceID], thresholdsFor(sc.GateID), now)
+	}
+	if err := pruneDeviceHealth(registered); err != nil {
+		log.Printf("Device monitor: failed to forget removed devices: %v", err)
+	}
+}
+
+// pruneDeviceHealth deletes the health rows of devices that are no longer
+// registered, given the source IDs registered per device type. A device
+// whose update failed this round keeps its row.
+func pruneDeviceHealth(registered map[string][]string) error {
+	for deviceType, sourceIDs := range registered {
+		stale := repository.DB.Unscoped().Where("device_type = ?", deviceType)
+		if len(sourceIDs) > 0 {
+			stale = stale.Where("source_id NOT IN ?", sourceIDs)
+		}
+		if err := stale.Delete(&models.DeviceHealth{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readLastSeen(key string) (map[string]*time.Time, error) {
+	values, err := repository.RDB.HGetAll(context.Background(), key).Result()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]*time.Time, len(values))
+	for sourceID, v := range values {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		t := time.UnixMilli(ms)
+		seen[sourceID] = &t
+	}
+	return seen, nil
+}
+
+// deviceStatus classifies a device by how long it has been silent. A device
+// never heard from is unknown rather than offline, so a newly registered
+// one does not alert.
+func deviceStatus(lastSeen *time.Time, t deviceThresholds, now time.Time) string {
+	switch {
+	case lastSeen == nil:
+		return models.DeviceStatusUnknown
+	case now.Sub(*lastSeen) <= t.degraded:
+		return models.DeviceStatusOnline
+	case now.Sub(*lastSeen) <= t.offline:
+		return models.DeviceStatusDegraded
+	}
+	return models.DeviceStatusOffline
+}
+
+// updateDeviceHealth stores the device's last-seen times and status. The row
+// is locked so only the replica that makes a transition alerts on it.
+func updateDeviceHealth(deviceType, sourceID, name string, gateID *uint, heartbeat, event *time.Time, t deviceThresholds, now time.Time) {
+	if sourceID == "" {
+		return
+	}
+
+	var health models.DeviceHealth
+	var previous string
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.DeviceHealth{DeviceType: deviceType, SourceID: sourceID, Status: models.DeviceStatusUnknown, StatusSince: now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("device_type = ? AND source_id = ?", deviceType, sourceID).
+			First(&health).Error; err != nil {
+			return err
+		}
+
+		health.Name = name
+		health.GateID = gateID
+		health.LastHeartbeatAt = heartbeat
+		health.LastEventAt = event
+		health.LastSeenAt = heartbeat
+		if event != nil && (heartbeat == nil || event.After(*heartbeat)) {
+			health.LastSeenAt = event
+		}
+
+		previous = health.Status
+		if status := deviceStatus(health.LastSeenAt, t, now); status != health.Status {
+			health.Status = status
+			health.StatusSince = now
+		}
+		return tx.Save(&health).Error
+	})
+	if err != nil {
+		log.Printf("Device monitor: failed to update %s %s: %v", deviceType, sourceID, err)
+		return
+	}
+
+	switch {
+	case health.Status == models.DeviceStatusOffline && previous != models.DeviceStatusOffline:
+		RaiseAlert("device.offline",
+			fmt.Sprintf("%s %s has not been seen since %s", deviceType, deviceLabel(name, sourceID), health.LastSeenAt.Format(time.RFC3339)),
+			gateID, health)
+	case previous == models.DeviceStatusOffline && health.Status != models.DeviceStatusOffline:
+		RaiseAlert("device.online",
+			fmt.Sprintf("%s %s is back online", deviceType, deviceLabel(name, sourceID)),
+			gateID, health)
+	}
+}
+
+// ValidateGateThresholds rejects device thresholds that are not positive or
+// would mark a device offline before degraded.
+func ValidateGateThresholds(gate models.Gate) error {
+	if gate.DegradedAfterSeconds != nil && *gate.DegradedAfterSeconds <= 0 {
+		return errors.New("degraded_after_seconds must be positive")
+	}
+	if gate.OfflineAfterSeconds != nil && *gate.OfflineAfterSeconds <= 0 {
+		return errors.New("offline_after_seconds must be positive")
+	}
+	if gate.DegradedAfterSeconds != nil && gate.OfflineAfterSeconds != nil &&
+		*gate.OfflineAfterSeconds < *gate.DegradedAfterSeconds {
+		return errors.New("offline_after_seconds must not be less than degraded_after_seconds")
+	}
+	return nil
+}
+
+func deviceLabel(name, sourceID string) string {
+	if name == "" {
+		return sourceID
+	}
+	return fmt.Sprintf("%q (%s)", name, sourceID)
+}
@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+)
+
+func TestPruneDeviceHealthKeepsRegisteredDevices(t *testing.T) {
+	db := testDB(t, &models.DeviceHealth{})
+	for _, h := range []models.DeviceHealth{
+		{DeviceType: models.DeviceTypeCamera, SourceID: "cam-1"},
+		{DeviceType: models.DeviceTypeCamera, SourceID: "cam-removed"},
+		{DeviceType: models.DeviceTypeScale, SourceID: "cam-1"},
+		{DeviceType: models.DeviceTypeScale, SourceID: "scale-removed"},
+	} {
+		h.Status = models.DeviceStatusUnknown
+		h.StatusSince = time.Now()
+		if err := db.Create(&h).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// cam-1 is registered whether or not its health update succeeded; no
+	// scale is registered any more.
+	if err := pruneDeviceHealth(map[string][]string{
+		models.DeviceTypeCamera: {"cam-1"},
+		models.DeviceTypeScale:  nil,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []models.DeviceHealth
+	if err := db.Unscoped().Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, h := range rows {
+		left = append(left, h.DeviceType+"/"+h.SourceID)
+	}
+	sort.Strings(left)
+	if len(left) != 1 || left[0] != models.DeviceTypeCamera+"/cam-1" {
+		t.Errorf("rows left = %v", left)
+	}
+}
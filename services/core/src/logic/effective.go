@@ -0,0 +1,166 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+)
+
+// Where an effective setting came from.
+const (
+	SourceDevice  = "device"
+	SourcePreset  = "preset"
+	SourceDefault = "default"
+)
+
+var ErrPresetNotFound = errors.New("preset not found")
+
+// EffectiveCameraConfig is what a camera adapter runs with: the camera's own
+// settings on top of its preset's. Provenance names the source of each
+// setting; field_mapping is tracked per mapped field
+// ("field_mapping.plate"). Version identifies the content and is served as
+// the ETag.
+type EffectiveCameraConfig struct {
+	ID           uint                  `json:"ID"`
+	SourceID     string                `json:"camera_id"`
+	Name         string                `json:"name"`
+	GateID       *uint                 `json:"gate_id"`
+	PresetID     *uint                 `json:"preset_id"`
+	Format       string                `json:"format"`
+	RunANPR      bool                  `json:"run_anpr"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
+	Provenance   map[string]string     `json:"provenance"`
+	Version      string                `json:"version"`
+}
+
+type EffectiveScaleConfig struct {
+	ID           uint                  `json:"ID"`
+	SourceID     string                `json:"scale_id"`
+	Name         string                `json:"name"`
+	GateID       *uint                 `json:"gate_id"`
+	PresetID     *uint                 `json:"preset_id"`
+	Format       string                `json:"format"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
+	Provenance   map[string]string     `json:"provenance"`
+	Version      string                `json:"version"`
+}
+
+// ResolveCameraConfig merges config over its preset. A preset that is set
+// but missing is an error so a dangling reference is not silently ignored.
+func ResolveCameraConfig(config models.CameraConfig) (EffectiveCameraConfig, error) {
+	var preset *models.CameraPreset
+	if config.PresetID != nil {
+		var p models.CameraPreset
+		if err := repository.DB.First(&p, *config.PresetID).Error; err != nil {
+			return EffectiveCameraConfig{}, presetError(*config.PresetID, err)
+		}
+		preset = &p
+	}
+	return MergeCameraConfig(config, preset), nil
+}
+
+// MergeCameraConfig applies config's overrides to preset, which may be nil.
+func MergeCameraConfig(config models.CameraConfig, preset *models.CameraPreset) EffectiveCameraConfig {
+	eff := EffectiveCameraConfig{
+		ID:         config.ID,
+		SourceID:   config.SourceID,
+		Name:       config.Name,
+		GateID:     config.GateID,
+		PresetID:   config.PresetID,
+		Provenance: map[string]string{},
+	}
+
+	var presetFormat string
+	var presetMapping payloadparser.Mapping
+	if preset != nil {
+		presetFormat, presetMapping = preset.Format, preset.FieldMapping
+	}
+	eff.Format = mergeFormat(config.Format, presetFormat, eff.Provenance)
+
+	switch {
+	case config.RunANPR != nil:
+		eff.RunANPR = *config.RunANPR
+		eff.Provenance["run_anpr"] = SourceDevice
+	case preset != nil:
+		eff.RunANPR = preset.RunANPR
+		eff.Provenance["run_anpr"] = SourcePreset
+	default:
+		eff.Provenance["run_anpr"] = SourceDefault
+	}
+
+	eff.FieldMapping = mergeMapping(config.FieldMapping, presetMapping, eff.Format, eff.Provenance)
+	eff.Version = configVersion(eff)
+	return eff
+}
+
+func ResolveScaleConfig(config models.ScaleConfig) (EffectiveScaleConfig, error) {
+	var preset *models.ScalePreset
+	if config.PresetID != nil {
+		var p models.ScalePreset
+		if err := repository.DB.First(&p, *config.PresetID).Error; err != nil {
+			return EffectiveScaleConfig{}, presetError(*config.PresetID, err)
+		}
+		preset = &p
+	}
+	return MergeScaleConfig(config, preset), nil
+}
+
+func MergeScaleConfig(config models.ScaleConfig, preset *models.ScalePreset) EffectiveScaleConfig {
+	eff := EffectiveScaleConfig{
+		ID:         config.ID,
+		SourceID:   config.SourceID,
+		Name:       config.Name,
+		GateID:     config.GateID,
+		PresetID:   config.PresetID,
+		Provenance: map[string]string{},
+	}
+
+	var presetFormat string
+	var presetMapping payloadparser.Mapping
+	if preset != nil {
+		presetFormat, presetMapping = preset.Format, preset.FieldMapping
+	}
+	eff.Format = mergeFormat(config.Format, presetFormat, eff.Provenance)
+	eff.FieldMapping = mergeMapping(config.FieldMapping, presetMapping, eff.Format, eff.Provenance)
+	eff.Version = configVersion(eff)
+	return eff
+}
+
+func mergeFormat(device, preset string, provenance map[string]string) string {
+	switch {
+	case device != "":
+		provenance["format"] = SourceDevice
+		return payloadparser.NormalizeFormat(device)
+	case preset != "":
+		provenance["format"] = SourcePreset
+		return payloadparser.NormalizeFormat(preset)
+	}
+	provenance["format"] = SourceDefault
+	return payloadparser.NormalizeFormat("")
+}
+
+// mergeMapping overrides the preset's mapping field by field, so a device
+// can remap the plate without restating the rest.
+func mergeMapping(device, preset payloadparser.Mapping, format string, provenance map[string]string) payloadparser.Mapping {
+	merged := payloadparser.Mapping{}
+	for field, ex := range preset {
+		merged[field] = ex
+		provenance["field_mapping."+field] = SourcePreset
+	}
+	for field, ex := range device {
+		merged[field] = ex
+		provenance["field_mapping."+field] = SourceDevice
+	}
+	return merged.Normalize(format)
+}
+
+func presetError(id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %d", ErrPresetNotFound, id)
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFlowNotFound = errors.New("flow not found")
+	ErrPermitClosed = errors.New("permit is closed")
+)
+
+// FlowSelection is the flow a new permit follows and why.
+type FlowSelection struct {
+	FlowID    *uint
+	Source    string
+	BookingID *uint
+	CarrierID *uint
+}
+
+// SelectFlow decides the flow of a permit opened at gate for a vehicle read
+// as any of plates, the best reading first: the booking's flow, else the
+// carrier's, else the gate's when it is a step of a single flow. When none
+// applies the permit has no flow until an operator picks one.
+// gate.FlowSteps must be loaded.
+func SelectFlow(db *gorm.DB, plates []string, gate models.Gate, at time.Time) FlowSelection {
+	var sel FlowSelection
+	if len(plates) == 0 {
+		return sel
+	}
+
+	var booking models.Booking
+	err := db.Where("plate IN ? AND permit_id IS NULL", plates).
+		Where("valid_from IS NULL OR valid_from <= ?", at).
+		Where("valid_until IS NULL OR valid_until >= ?", at).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "plate = ? DESC, valid_from DESC NULLS LAST, id DESC",
+			Vars: []interface{}{plates[0]},
+		}}).
+		First(&booking).Error
+	if err == nil {
+		sel.BookingID = &booking.ID
+		sel.CarrierID = booking.CarrierID
+		if booking.FlowID != nil {
+			sel.FlowID, sel.Source = booking.FlowID, models.FlowSourceBooking
+			return sel
+		}
+	}
+
+	var carrier models.Carrier
+	if sel.CarrierID != nil {
+		err = db.First(&carrier, *sel.CarrierID).Error
+	} else {
+		err = db.Joins("JOIN carrier_vehicles ON carrier_vehicles.carrier_id = carriers.id AND carrier_vehicles.deleted_at IS NULL").
+			Where("carrier_vehicles.plate IN ?", plates).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "carrier_vehicles.plate = ? DESC, carrier_vehicles.id DESC",
+				Vars: []interface{}{plates[0]},
+			}}).
+			First(&carrier).Error
+	}
+	if err == nil {
+		sel.CarrierID = &carrier.ID
+		if carrier.FlowID != nil {
+			sel.FlowID, sel.Source = carrier.FlowID, models.FlowSourceCarrier
+			return sel
+		}
+	}
+
+	if len(gate.FlowSteps) == 1 {
+		sel.FlowID, sel.Source = &gate.FlowSteps[0].FlowID, models.FlowSourceGate
+	}
+	return sel
+}
+
+// flowStepFor returns the step gate is in the given flow, or nil when the
+// permit has no flow or the flow does not pass the gate.
+func flowStepFor(gate models.Gate, flowID *uint) *models.FlowStep {
+	if flowID == nil {
+		return nil
+	}
+	for i := range gate.FlowSteps {
+		if gate.FlowSteps[i].FlowID == *flowID {
+			return &gate.FlowSteps[i]
+		}
+	}
+	return nil
+}
+
+// SetPermitFlow lets an operator choose an open permit's flow. The current
+// step becomes the furthest step of the new flow the permit has already
+// passed, or the first.
+func SetPermitFlow(permit *models.Permit, flowID uint) error {
+	if permit.IsClosed {
+		return ErrPermitClosed
+	}
+	var flow models.Flow
+	if err := repository.DB.Preload("Steps").First(&flow, flowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFlowNotFound
+		}
+		return err
+	}
+
+	var passed []uint
+	if err := repository.DB.Model(&models.GateEvent{}).Where("permit_id = ?", permit.ID).
+		Distinct().Pluck("gate_id", &passed).Error; err != nil {
+		return err
+	}
+	first, _ := flowBounds(flow.Steps)
+	sequence := first
+	for _, step := range flow.Steps {
+		for _, gateID := range passed {
+			if step.GateID == gateID && step.Sequence > sequence {
+				sequence = step.Sequence
+			}
+		}
+	}
+
+	permit.FlowID = &flow.ID
+	permit.FlowSource = models.FlowSourceOperator
+	permit.CurrentStepSequence = sequence
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(permit).Updates(map[string]interface{}{
+			"flow_id":               permit.FlowID,
+			"flow_source":           permit.FlowSource,
+			"current_step_sequence": permit.CurrentStepSequence,
+		}).Error; err != nil {
+			return err
+		}
+		return AppendOutbox(tx, EventPermitFlowChanged, "permit", permit.ID, permit)
+	})
+	if err != nil {
+		return fmt.Errorf("set flow of permit %d: %w", permit.ID, err)
+	}
+	WakeOutboxRelay()
+	PublishLive(LivePermit, "flow_changed", nil, permit)
+	EnqueueWebhooks(WebhookPermitFlowChanged, *permit)
+	return nil
+}
@@ -0,0 +1,92 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// LiveChannel is the Redis pub/sub channel every core replica publishes
+// operator-facing updates to; each /events/stream client subscribes to it.
+const LiveChannel = "core:live"
+
+const (
+	LivePlate     = "plate"
+	LiveWeight    = "weight"
+	LiveGateEvent = "gate_event"
+	LivePermit    = "permit"
+	LiveAlert     = "alert"
+)
+
+type LiveEvent struct {
+	Type   string      `json:"type"`
+	Action string      `json:"action"`
+	GateID *uint       `json:"gate_id,omitempty"`
+	At     time.Time   `json:"at"`
+	Data   interface{} `json:"data"`
+}
+
+func PublishLive(eventType, action string, gateID *uint, data interface{}) {
+	if repository.RDB == nil {
+		return
+	}
+
+	msg, err := json.Marshal(LiveEvent{
+		Type:   eventType,
+		Action: action,
+		GateID: gateID,
+		At:     time.Now(),
+		Data:   data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode live event %s/%s: %v", eventType, action, err)
+		return
+	}
+
+	if err := repository.RDB.Publish(context.Background(), LiveChannel, msg).Err(); err != nil {
+		log.Printf("Failed to publish live event %s/%s: %v", eventType, action, err)
+	}
+}
+
+// PlateEventGateID returns the gate of the gate event the plate event was
+// matched to, or nil while it has none.
+func PlateEventGateID(event *models.RawPlateEvent) *uint {
+	if event.GateEventID == nil {
+		return nil
+	}
+	var ge models.GateEvent
+	if err := repository.DB.Select("gate_id").First(&ge, *event.GateEventID).Error; err != nil {
+		return nil
+	}
+	return &ge.GateID
+}
+
+type Alert struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// RaiseAlert records the alert in the system event log and pushes it to the
+// live feed.
+func RaiseAlert(code, message string, gateID *uint, details interface{}) {
+	alert := Alert{Code: code, Message: message, Details: details}
+	payload, _ := json.Marshal(alert)
+
+	sysEvent := models.SystemEvent{
+		Type:      "alert",
+		SourceID:  code,
+		Payload:   string(payload),
+		Timestamp: time.Now(),
+	}
+	if err := repository.DB.Create(&sysEvent).Error; err != nil {
+		log.Printf("Failed to store alert %s: %v", code, err)
+	}
+
+	log.Printf("ALERT %s: %s", code, message)
+	PublishLive(LiveAlert, code, gateID, alert)
+}
@@ -2,12 +2,15 @@ package logic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/truckguard/core/src/models"
 	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func getGateDeviceCount(gateID uint) int64 {
@@ -24,7 +27,36 @@ func getGateDeviceCount(gateID uint) int64 {
 	return countCameras + countScales
 }
 
-func getOrCreateGateEvent(gateID uint) uint {
+// gateEventWindow is how long a gate event stays open for further readings
+// from the gate's devices.
+const gateEventWindow = 15 * time.Second
+
+// gateEventFor returns the gate event a reading taken at ts belongs to. Live
+// readings share the gate's active event; late ones (buffered during an
+// outage) join the closest event recorded within the window around ts.
+func gateEventFor(gateID uint, ts time.Time) uint {
+	if time.Since(ts) <= gateEventWindow {
+		id := getOrCreateGateEvent(gateID, ts)
+		updateGateEventCount(gateID)
+		return id
+	}
+
+	var ge models.GateEvent
+	err := repository.DB.Where("gate_id = ? AND timestamp BETWEEN ? AND ?", gateID, ts.Add(-gateEventWindow), ts.Add(gateEventWindow)).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "ABS(EXTRACT(EPOCH FROM (timestamp - ?)))", Vars: []interface{}{ts}}}).
+		First(&ge).Error
+	if err == nil {
+		return ge.ID
+	}
+
+	ge = models.GateEvent{GateID: gateID, Timestamp: ts}
+	if err := createGateEvent(&ge); err != nil {
+		log.Printf("Failed to create gate event: %v", err)
+	}
+	return ge.ID
+}
+
+func getOrCreateGateEvent(gateID uint, ts time.Time) uint {
 	ctx := context.Background()
 	redisKeyID := fmt.Sprintf("active_gate:%d", gateID)
 	redisKeyCount := fmt.Sprintf("active_gate_count:%d", gateID)
@@ -44,14 +76,16 @@ func getOrCreateGateEvent(gateID uint) uint {
 
 	newEvent := models.GateEvent{
 		GateID:    gateID,
-		Timestamp: time.Now(),
+		Timestamp: ts,
+	}
+	if err := createGateEvent(&newEvent); err != nil {
+		log.Printf("Failed to create gate event: %v", err)
 	}
-	repository.DB.Create(&newEvent)
 
 	maxDevices := getGateDeviceCount(gateID)
 
-	repository.RDB.Set(ctx, redisKeyID, newEvent.ID, 15*time.Second)
-	repository.RDB.Set(ctx, redisKeyCount, maxDevices, 15*time.Second)
+	repository.RDB.Set(ctx, redisKeyID, newEvent.ID, gateEventWindow)
+	repository.RDB.Set(ctx, redisKeyCount, maxDevices, gateEventWindow)
 
 	return uint(newEvent.ID)
 }
@@ -64,27 +98,49 @@ func updateGateEventCount(gateID uint) {
 		log.Printf("Failed to get gate event count: %v", err)
 		return
 	}
-	repository.RDB.Set(ctx, redisKeyCount, count-1, 15*time.Second)
+	repository.RDB.Set(ctx, redisKeyCount, count-1, gateEventWindow)
 }
 
 func MatchPlateEvent(event *models.RawPlateEvent) {
 	db := repository.DB
 
 	if err := db.Preload("Camera").First(&event).Error; err != nil {
-		log.Printf("Camera %+v has no GateID", &event.Camera)
+		log.Printf("Failed to load plate event %d: %v", event.ID, err)
+		return
+	}
+	// An unregistered source gets no clock record; its event keeps the
+	// time it arrived with.
+	if event.Camera.ID == 0 {
+		PublishLive(LivePlate, "created", nil, event)
+		log.Printf("Plate event %d came from unknown camera %q", event.ID, event.CameraSourceID)
+		RecordUnmatchedPlateEvent(event, models.UnmatchedUnknownCamera,
+			fmt.Sprintf("camera %q is not registered", event.CameraSourceID))
+		return
+	}
+	event.Timestamp, event.ClockSkewMs = eventTime(models.DeviceTypeCamera, event.CameraSourceID, event.Camera.GateID,
+		event.Timestamp, event.CapturedAt, event.ReceivedAt, event.Buffered)
+	db.Model(event).Updates(map[string]interface{}{"timestamp": event.Timestamp, "clock_skew_ms": event.ClockSkewMs})
+	PublishLive(LivePlate, "created", event.Camera.GateID, event)
+
+	if event.Camera.GateID == nil {
+		log.Printf("Camera %s has no GateID", event.Camera.SourceID)
+		RecordUnmatchedPlateEvent(event, models.UnmatchedCameraNoGate,
+			fmt.Sprintf("camera %q (%s) is not assigned to a gate", event.Camera.Name, event.Camera.SourceID))
 		return
 	}
 
 	var gate models.Gate
-	if err := db.Preload("FlowStep").First(&gate, *event.Camera.GateID).Error; err != nil {
+	if err := db.First(&gate, *event.Camera.GateID).Error; err != nil {
+		RecordUnmatchedPlateEvent(event, models.UnmatchedGateNotFound,
+			fmt.Sprintf("gate %d of camera %q not found", *event.Camera.GateID, event.Camera.Name))
 		return
 	}
 	log.Println("Gate:", gate.Name, *event.Camera.GateID)
-	gateEventID := getOrCreateGateEvent(gate.ID)
-	updateGateEventCount(gate.ID)
+	gateEventID := gateEventFor(gate.ID, event.Timestamp)
 
 	event.GateEventID = &gateEventID
 	db.Save(event)
+	PublishLive(LiveGateEvent, "updated", &gate.ID, map[string]uint{"id": gateEventID, "plate_event_id": event.ID})
 
 	// Trigger permit processing
 	go ProcessGateEventToPermit(gateEventID)
@@ -94,28 +150,125 @@ func MatchWeightEvent(event *models.RawWeightEvent) {
 	db := repository.DB
 
 	if err := db.Preload("Scale").First(&event).Error; err != nil {
+		log.Printf("Failed to load weight event %d: %v", event.ID, err)
+		return
+	}
+	// An unregistered source gets no clock record; its event keeps the
+	// time it arrived with.
+	if event.Scale.ID == 0 {
+		PublishLive(LiveWeight, "created", nil, event)
+		log.Printf("Weight event %d came from unknown scale %q", event.ID, event.ScaleSourceID)
+		RecordUnmatchedWeightEvent(event, models.UnmatchedUnknownScale,
+			fmt.Sprintf("scale %q is not registered", event.ScaleSourceID))
+		return
+	}
+	event.Timestamp, event.ClockSkewMs = eventTime(models.DeviceTypeScale, event.ScaleSourceID, event.Scale.GateID,
+		event.Timestamp, event.CapturedAt, event.ReceivedAt, event.Buffered)
+	db.Model(event).Updates(map[string]interface{}{"timestamp": event.Timestamp, "clock_skew_ms": event.ClockSkewMs})
+	PublishLive(LiveWeight, "created", event.Scale.GateID, event)
+
+	if event.Scale.GateID == nil {
+		log.Printf("Scale %s has no GateID", event.Scale.SourceID)
+		RecordUnmatchedWeightEvent(event, models.UnmatchedScaleNoGate,
+			fmt.Sprintf("scale %q (%s) is not assigned to a gate", event.Scale.Name, event.Scale.SourceID))
 		return
 	}
+
 	var gate models.Gate
-	if err := db.Preload("FlowStep").First(&gate, *event.Scale.GateID).Error; err != nil {
+	if err := db.First(&gate, *event.Scale.GateID).Error; err != nil {
+		RecordUnmatchedWeightEvent(event, models.UnmatchedGateNotFound,
+			fmt.Sprintf("gate %d of scale %q not found", *event.Scale.GateID, event.Scale.Name))
 		return
 	}
-	gateEventID := getOrCreateGateEvent(gate.ID)
-	updateGateEventCount(gate.ID)
+	gateEventID := gateEventFor(gate.ID, event.Timestamp)
 
 	event.GateEventID = &gateEventID
 	db.Save(event)
+	PublishLive(LiveGateEvent, "updated", &gate.ID, map[string]uint{"id": gateEventID, "weight_event_id": event.ID})
 
 	// Trigger permit processing
 	go ProcessGateEventToPermit(gateEventID)
 }
 
+// errBookingTaken means another permit claimed the selected booking first.
+var errBookingTaken = errors.New("booking already used by another permit")
+
+// createPermitAttempts bounds how often a permit is retried after losing its
+// booking to a concurrent permit.
+const createPermitAttempts = 3
+
+// createPermit opens a permit for plate. candidates are the other readings of
+// the vehicle at the gate event, also tried when looking up its booking or
+// carrier.
+func createPermit(ge *models.GateEvent, plate string, candidates []string) (*models.Permit, error) {
+	var newPermit models.Permit
+	var err error
+	for attempt := 0; attempt < createPermitAttempts; attempt++ {
+		newPermit, err = openPermit(ge, plate, candidates)
+		if !errors.Is(err, errBookingTaken) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	WakeOutboxRelay()
+	notifyPermit("opened", ge.GateID, newPermit)
+	if newPermit.FlowID == nil && len(ge.Gate.FlowSteps) > 1 {
+		RaiseAlert("permit.flow_undecided",
+			fmt.Sprintf("permit %d for %s entered at gate %q, which is in %d flows; choose its flow", newPermit.ID, plate, ge.Gate.Name, len(ge.Gate.FlowSteps)),
+			&ge.GateID, map[string]uint{"permit_id": newPermit.ID})
+	}
+	return &newPermit, nil
+}
+
+// openPermit creates the permit with the flow SelectFlow picks and claims its
+// booking in the same transaction. It fails with errBookingTaken when a
+// concurrent permit claimed the booking first, so the flow is picked again.
+func openPermit(ge *models.GateEvent, plate string, candidates []string) (models.Permit, error) {
+	newPermit := models.Permit{
+		PlateFront:          plate,        // Assumption: First seen is Front. TODO: Logic to distinguish Front/Back
+		EntryTime:           ge.Timestamp, // Or time.Now()
+		IsClosed:            false,
+		CurrentStepSequence: 1, // Default start
+	}
+
+	// Pick the flow from the booking, the carrier or the gate
+	sel := SelectFlow(repository.DB, append([]string{plate}, candidates...), ge.Gate, ge.Timestamp)
+	newPermit.FlowID, newPermit.FlowSource = sel.FlowID, sel.Source
+	newPermit.BookingID, newPermit.CarrierID = sel.BookingID, sel.CarrierID
+	if step := flowStepFor(ge.Gate, sel.FlowID); step != nil {
+		newPermit.CurrentStepSequence = step.Sequence
+	}
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newPermit).Error; err != nil {
+			return err
+		}
+		if sel.BookingID != nil {
+			res := tx.Model(&models.Booking{}).Where("id = ? AND permit_id IS NULL", *sel.BookingID).
+				Update("permit_id", newPermit.ID)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errBookingTaken
+			}
+		}
+		return AppendOutbox(tx, EventPermitOpened, "permit", newPermit.ID, newPermit)
+	})
+	if err != nil {
+		return models.Permit{}, err
+	}
+	return newPermit, nil
+}
+
 func ProcessGateEventToPermit(gateEventID uint) {
 	var ge models.GateEvent
 	// Preload necessary data
 	if err := repository.DB.
 		Preload("Gate").
-		Preload("Gate.FlowStep").
+		Preload("Gate.FlowSteps").
 		Preload("PlateEvents").
 		Preload("WeightEvents").
 		First(&ge, gateEventID).Error; err != nil {
@@ -151,6 +304,7 @@ func ProcessGateEventToPermit(gateEventID uint) {
 	// Let's check if GateEvent already has a PermitID
 	var permit models.Permit
 	var found bool
+	hadPermit := ge.PermitID != nil
 
 	if ge.PermitID != nil {
 		if err := repository.DB.First(&permit, *ge.PermitID).Error; err == nil {
@@ -173,22 +327,8 @@ func ProcessGateEventToPermit(gateEventID uint) {
 
 	// 2. Entry Gate Logic (Create Permit)
 	if !found && ge.Gate.IsEntry && bestPlate != "" {
-		// Create new permit
-		newPermit := models.Permit{
-			PlateFront:          bestPlate,    // Assumption: First seen is Front. TODO: Logic to distinguish Front/Back
-			EntryTime:           ge.Timestamp, // Or time.Now()
-			IsClosed:            false,
-			CurrentStepSequence: 1, // Default start
-		}
-
-		// Assign FlowID if Gate is part of a Flow
-		if ge.Gate.FlowStep != nil {
-			newPermit.FlowID = &ge.Gate.FlowStep.FlowID
-			newPermit.CurrentStepSequence = ge.Gate.FlowStep.Sequence
-		}
-
-		if err := repository.DB.Create(&newPermit).Error; err == nil {
-			permit = newPermit
+		if newPermit, err := createPermit(&ge, bestPlate, plateCandidates); err == nil {
+			permit = *newPermit
 			found = true
 			// Link GateEvent
 			ge.PermitID = &permit.ID
@@ -200,11 +340,25 @@ func ProcessGateEventToPermit(gateEventID uint) {
 
 	if !found {
 		log.Println("Permit not found")
+		details := fmt.Sprintf("no open permit at gate %q", ge.Gate.Name)
+		if len(plateCandidates) > 0 {
+			details += fmt.Sprintf(" for plates %v", plateCandidates)
+		} else {
+			details += ", no plate recognised"
+		}
+		RecordUnmatchedGateEvent(&ge, details)
 		return
 	}
+	resolveUnmatchedGateEvent(ge.ID, permit.ID)
+	if !hadPermit {
+		PublishLive(LiveGateEvent, "updated", &ge.GateID, map[string]uint{"id": ge.ID, "permit_id": permit.ID})
+	}
 
 	// 3. Update Permit Data
 	dirty := false
+	wasClosed := permit.IsClosed
+	prevSequence := permit.CurrentStepSequence
+	prevWeight := permit.TotalWeight
 
 	// Update Weight
 	if len(ge.WeightEvents) > 0 {
@@ -234,12 +388,12 @@ func ProcessGateEventToPermit(gateEventID uint) {
 	}
 
 	// Validate/Update Sequence (Mid/Exit)
-	// If the Gate has a sequence number, we update CurrentStepSequence
-	if ge.Gate.FlowStep != nil {
+	// If the Gate is a step of the permit's flow, we update CurrentStepSequence
+	if step := flowStepFor(ge.Gate, permit.FlowID); step != nil {
 		// Check invalid jumps?
 		// For now, simple update
-		if ge.Gate.FlowStep.Sequence > permit.CurrentStepSequence {
-			permit.CurrentStepSequence = ge.Gate.FlowStep.Sequence
+		if step.Sequence > permit.CurrentStepSequence {
+			permit.CurrentStepSequence = step.Sequence
 			dirty = true
 		}
 	}
@@ -259,6 +413,50 @@ func ProcessGateEventToPermit(gateEventID uint) {
 
 	if dirty {
 		permit.LastActivityAt = time.Now()
-		repository.DB.Save(&permit)
+		err := repository.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&permit).Error; err != nil {
+				return err
+			}
+			if permit.CurrentStepSequence != prevSequence {
+				if err := AppendOutbox(tx, EventPermitStepAdvanced, "permit", permit.ID, permit); err != nil {
+					return err
+				}
+			}
+			if permit.IsClosed && !wasClosed {
+				if err := AppendOutbox(tx, EventPermitClosed, "permit", permit.ID, permit); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to save permit %d: %v", permit.ID, err)
+			return
+		}
+		WakeOutboxRelay()
+
+		changed := false
+		if permit.TotalWeight != prevWeight {
+			notifyPermit("weighed", ge.GateID, permit)
+			changed = true
+		}
+		if permit.CurrentStepSequence != prevSequence {
+			notifyPermit("step_advanced", ge.GateID, permit)
+			changed = true
+		}
+		if permit.IsClosed && !wasClosed {
+			notifyPermit("closed", ge.GateID, permit)
+			changed = true
+		}
+		if !changed {
+			PublishLive(LivePermit, "updated", &ge.GateID, permit)
+		}
 	}
 }
+
+// notifyPermit fans a permit state change out to the live feed and to
+// webhook subscribers.
+func notifyPermit(action string, gateID uint, permit models.Permit) {
+	PublishLive(LivePermit, action, &gateID, permit)
+	EnqueueWebhooks("permit."+action, permit)
+}
@@ -0,0 +1,148 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxStream is the Redis stream other services consume domain events from.
+const OutboxStream = "core:events"
+
+const (
+	EventPermitOpened       = "PermitOpened"
+	EventPermitStepAdvanced = "PermitStepAdvanced"
+	EventPermitClosed       = "PermitClosed"
+	EventPermitFlowChanged  = "PermitFlowChanged"
+	EventGateEventCreated   = "GateEventCreated"
+	EventPlateCorrected     = "PlateCorrected"
+
+	outboxBatchSize = 100
+	// outboxStreamMaxLen bounds core:events; consumers are expected to keep up
+	// well within this window.
+	outboxStreamMaxLen = 100000
+)
+
+var outboxWake = make(chan struct{}, 1)
+
+// AppendOutbox records a domain event inside tx. It becomes visible to the
+// relay only if tx commits, so the stream never sees rolled-back changes.
+func AppendOutbox(tx *gorm.DB, eventType, aggregateType string, aggregateID uint, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       string(data),
+	}).Error
+}
+
+// WakeOutboxRelay asks the relay to publish without waiting for its next tick.
+// Call it after committing a transaction that appended outbox events.
+func WakeOutboxRelay() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+func RunOutboxRelay() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-outboxWake:
+		}
+		// Keep draining while full batches come back.
+		for relayOutbox() == outboxBatchSize {
+			continue
+		}
+	}
+}
+
+// relayOutbox publishes one batch of unpublished events in ID order and
+// returns how many were published. Rows are locked with SKIP LOCKED so
+// several core replicas can run the relay side by side.
+func relayOutbox() int {
+	published := 0
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("id").
+			Limit(outboxBatchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		var ids []uint
+		for _, e := range events {
+			if err := repository.RDB.XAdd(context.Background(), &redis.XAddArgs{
+				Stream: OutboxStream,
+				MaxLen: outboxStreamMaxLen,
+				Approx: true,
+				Values: map[string]interface{}{
+					"id":             strconv.FormatUint(uint64(e.ID), 10),
+					"type":           e.EventType,
+					"aggregate_type": e.AggregateType,
+					"aggregate_id":   strconv.FormatUint(uint64(e.AggregateID), 10),
+					"occurred_at":    e.CreatedAt.Format(time.RFC3339Nano),
+					"data":           e.Payload,
+				},
+			}).Err(); err != nil {
+				log.Printf("Failed to relay outbox event %d: %v", e.ID, err)
+				break
+			}
+			ids = append(ids, e.ID)
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+		published = len(ids)
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).
+			Update("published_at", time.Now()).Error
+	})
+	if err != nil {
+		log.Printf("Outbox relay failed: %v", err)
+		return 0
+	}
+	return published
+}
+
+// createGateEvent inserts a GateEvent together with its GateEventCreated
+// domain event.
+func createGateEvent(ge *models.GateEvent) error {
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		return insertGateEvent(tx, ge)
+	})
+	if err != nil {
+		return err
+	}
+	WakeOutboxRelay()
+	PublishLive(LiveGateEvent, "created", &ge.GateID, ge)
+	return nil
+}
+
+// insertGateEvent writes a GateEvent and its GateEventCreated domain event in
+// tx. The caller wakes the relay and publishes it once tx is committed.
+func insertGateEvent(tx *gorm.DB, ge *models.GateEvent) error {
+	if err := tx.Create(ge).Error; err != nil {
+		return err
+	}
+	return AppendOutbox(tx, EventGateEventCreated, "gate_event", ge.ID, ge)
+}
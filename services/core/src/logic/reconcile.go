@@ -0,0 +1,198 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/truckguard/core/src/api/clients"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+// reconciliationKey holds the latest ReconciliationReport as JSON.
+const reconciliationKey = "core:reconciliation"
+
+const reconcileInterval = time.Hour
+
+// deviceKeyPermission marks the API keys that belong to devices; core
+// provisions every camera and scale key with it and nothing else.
+const deviceKeyPermission = "create:ingest"
+
+var ErrReconciliationDisabled = errors.New("reconciliation requires AUTH_SERVICE_URL and AUTH_SYSTEM_KEY")
+
+// OrphanKey is a device key in auth whose source is no camera or scale, e.g.
+// left behind by a failed compensation.
+type OrphanKey struct {
+	KeyID      uint       `json:"key_id"`
+	SourceID   string     `json:"source_id"`
+	OwnerName  string     `json:"owner_name"`
+	IsActive   bool       `json:"is_active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// KeylessDevice is a camera or scale with no usable key, so it cannot send.
+type KeylessDevice struct {
+	DeviceType string `json:"device_type"`
+	ID         uint   `json:"id"`
+	SourceID   string `json:"source_id"`
+	Name       string `json:"name"`
+}
+
+type ReconciliationReport struct {
+	CheckedAt         time.Time       `json:"checked_at"`
+	OrphanKeys        []OrphanKey     `json:"orphan_keys"`
+	DevicesWithoutKey []KeylessDevice `json:"devices_without_key"`
+}
+
+// RunReconciliation compares auth's device keys with core's devices once an
+// hour. It only reports; fixing a mismatch is left to an operator.
+func RunReconciliation() {
+	if client := clients.NewAuthClient(); client == nil || client.SystemKey == "" {
+		log.Printf("Reconciliation disabled: %v", ErrReconciliationDisabled)
+		return
+	}
+
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := Reconcile(context.Background()); err != nil {
+			log.Printf("Reconciliation failed: %v", err)
+		}
+	}
+}
+
+// Reconcile builds and stores a report, alerting on mismatches the previous
+// report did not have.
+func Reconcile(ctx context.Context) (ReconciliationReport, error) {
+	client := clients.NewAuthClient()
+	if client == nil || client.SystemKey == "" {
+		return ReconciliationReport{}, ErrReconciliationDisabled
+	}
+	keys, err := client.ListApiKeys(ctx, "", client.SystemKey)
+	if err != nil {
+		return ReconciliationReport{}, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	var devices []KeylessDevice
+	var cameras []models.CameraConfig
+	if err := repository.DB.Find(&cameras).Error; err != nil {
+		return ReconciliationReport{}, err
+	}
+	for _, cam := range cameras {
+		devices = append(devices, KeylessDevice{DeviceType: models.DeviceTypeCamera, ID: cam.ID, SourceID: cam.SourceID, Name: cam.Name})
+	}
+	var scales []models.ScaleConfig
+	if err := repository.DB.Find(&scales).Error; err != nil {
+		return ReconciliationReport{}, err
+	}
+	for _, sc := range scales {
+		devices = append(devices, KeylessDevice{DeviceType: models.DeviceTypeScale, ID: sc.ID, SourceID: sc.SourceID, Name: sc.Name})
+	}
+
+	report := buildReconciliationReport(keys, devices, time.Now())
+	previous, err := LastReconciliation()
+	if err != nil {
+		log.Printf("Reconciliation: failed to read previous report: %v", err)
+	}
+	alertNewMismatches(report, previous)
+
+	if b, err := json.Marshal(report); err == nil {
+		if err := repository.RDB.Set(ctx, reconciliationKey, b, 0).Err(); err != nil {
+			log.Printf("Reconciliation: failed to store report: %v", err)
+		}
+	}
+	return report, nil
+}
+
+func buildReconciliationReport(keys []clients.APIKeyInfo, devices []KeylessDevice, now time.Time) ReconciliationReport {
+	report := ReconciliationReport{
+		CheckedAt:         now,
+		OrphanKeys:        []OrphanKey{},
+		DevicesWithoutKey: []KeylessDevice{},
+	}
+
+	known := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		known[d.SourceID] = true
+	}
+
+	usable := map[string]bool{}
+	for _, k := range keys {
+		if !k.HasPermission(deviceKeyPermission) {
+			continue
+		}
+		if !known[k.SourceID] {
+			report.OrphanKeys = append(report.OrphanKeys, OrphanKey{
+				KeyID:      k.ID,
+				SourceID:   k.SourceID,
+				OwnerName:  k.OwnerName,
+				IsActive:   k.IsActive,
+				CreatedAt:  k.CreatedAt,
+				LastUsedAt: k.LastUsedAt,
+			})
+			continue
+		}
+		if k.Usable(now) {
+			usable[k.SourceID] = true
+		}
+	}
+
+	for _, d := range devices {
+		if !usable[d.SourceID] {
+			report.DevicesWithoutKey = append(report.DevicesWithoutKey, d)
+		}
+	}
+	return report
+}
+
+// alertNewMismatches alerts once per mismatch rather than on every run.
+func alertNewMismatches(report ReconciliationReport, previous *ReconciliationReport) {
+	seenKeys := map[uint]bool{}
+	seenDevices := map[string]bool{}
+	if previous != nil {
+		for _, k := range previous.OrphanKeys {
+			seenKeys[k.KeyID] = true
+		}
+		for _, d := range previous.DevicesWithoutKey {
+			seenDevices[d.DeviceType+":"+d.SourceID] = true
+		}
+	}
+
+	for _, k := range report.OrphanKeys {
+		if !seenKeys[k.KeyID] {
+			RaiseAlert("devices.orphan_key",
+				fmt.Sprintf("API key %d (%s, source %s) belongs to no camera or scale", k.KeyID, k.OwnerName, k.SourceID),
+				nil, k)
+		}
+	}
+	for _, d := range report.DevicesWithoutKey {
+		if !seenDevices[d.DeviceType+":"+d.SourceID] {
+			RaiseAlert("devices.missing_key",
+				fmt.Sprintf("%s %s has no usable API key", d.DeviceType, deviceLabel(d.Name, d.SourceID)),
+				nil, d)
+		}
+	}
+}
+
+// LastReconciliation returns the latest report, or nil when none ran yet.
+func LastReconciliation() (*ReconciliationReport, error) {
+	v, err := repository.RDB.Get(context.Background(), reconciliationKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var report ReconciliationReport
+	if err := json.Unmarshal([]byte(v), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,306 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const retentionBatchSize = 500
+
+// RunRetentionPurger applies the retention settings once an hour. A setting
+// of 0 or less disables that policy.
+func RunRetentionPurger() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		if days := SettingInt(SettingRetentionClosedPermitDays, 90); days > 0 {
+			purgeClosedPermitEvidence(now.AddDate(0, 0, -days))
+		}
+		if days := SettingInt(SettingRetentionOrphanImageDays, 30); days > 0 {
+			purgeOrphanImages(now.AddDate(0, 0, -days))
+		}
+		if days := SettingInt(SettingRetentionSystemEventDays, 30); days > 0 {
+			purgeSystemEvents(now.AddDate(0, 0, -days))
+		}
+	}
+}
+
+// purgeClosedPermitEvidence removes the images of permits closed before
+// cutoff. Permits under legal hold are skipped; the permit and its events are
+// kept, only the evidence goes.
+func purgeClosedPermitEvidence(cutoff time.Time) {
+	for {
+		var ids []uint
+		if err := repository.DB.Model(&models.Permit{}).
+			Where("is_closed = ? AND legal_hold = ? AND evidence_purged_at IS NULL", true, false).
+			Where("COALESCE(exit_time, last_activity_at) < ?", cutoff).
+			Limit(100).Pluck("id", &ids).Error; err != nil {
+			log.Printf("Retention: failed to list closed permits: %v", err)
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			if err := purgePermitEvidence(id); err != nil {
+				log.Printf("Retention: failed to purge evidence of permit %d: %v", id, err)
+				return
+			}
+		}
+		log.Printf("Retention: purged evidence of %d closed permits", len(ids))
+	}
+}
+
+// purgePermitEvidence holds the permit row lock while deleting, so a legal
+// hold placed concurrently either waits for the purge or prevents it. The
+// objects are removed once the rows are committed: one that fails to go is
+// then an orphan for purgeOrphanImages, never a row without its image.
+func purgePermitEvidence(permitID uint) error {
+	var keys []string
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		var permit models.Permit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("legal_hold = ?", false).First(&permit, permitID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var plateEventIDs []uint
+		if err := tx.Model(&models.RawPlateEvent{}).
+			Joins("JOIN gate_events ON gate_events.id = raw_plate_events.gate_event_id").
+			Where("gate_events.permit_id = ?", permitID).
+			Pluck("raw_plate_events.id", &plateEventIDs).Error; err != nil {
+			return err
+		}
+
+		if len(plateEventIDs) > 0 {
+			if keys, err = plateEventImageKeys(tx, plateEventIDs); err != nil {
+				return err
+			}
+			if err := tx.Where("raw_plate_event_id IN ?", plateEventIDs).Delete(&models.PlateEventImage{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.RawPlateEvent{}).Where("id IN ?", plateEventIDs).
+				Update("image_key", "").Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&permit).Update("evidence_purged_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+	return removeImageObjects(keys)
+}
+
+func plateEventImageKeys(tx *gorm.DB, plateEventIDs []uint) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&models.PlateEventImage{}).Where("raw_plate_event_id IN ?", plateEventIDs).
+		Pluck("key", &keys).Error; err != nil {
+		return nil, err
+	}
+	var primary []string
+	if err := tx.Model(&models.RawPlateEvent{}).Where("id IN ? AND image_key <> ''", plateEventIDs).
+		Pluck("image_key", &primary).Error; err != nil {
+		return nil, err
+	}
+	return dedupeKeys(append(keys, primary...)), nil
+}
+
+// purgeOrphanImages deletes objects stored before cutoff that no permit needs:
+// never referenced, or referenced only by plate events that reached no permit
+// and are not waiting in the unmatched queue. Objects live under YYYY-MM-DD/
+// prefixes, so only days before cutoff are listed.
+func purgeOrphanImages(cutoff time.Time) {
+	ctx := context.Background()
+	cutoffDay := cutoff.Format("2006-01-02")
+
+	for prefix := range repository.MinioClient.ListObjects(ctx, repository.BucketName, minio.ListObjectsOptions{}) {
+		if prefix.Err != nil {
+			log.Printf("Retention: failed to list image prefixes: %v", prefix.Err)
+			return
+		}
+		day := strings.TrimSuffix(prefix.Key, "/")
+		if _, err := time.Parse("2006-01-02", day); err != nil || day >= cutoffDay {
+			continue
+		}
+
+		var batch []string
+		for obj := range repository.MinioClient.ListObjects(ctx, repository.BucketName, minio.ListObjectsOptions{Prefix: prefix.Key, Recursive: true}) {
+			if obj.Err != nil {
+				log.Printf("Retention: failed to list %s: %v", prefix.Key, obj.Err)
+				return
+			}
+			batch = append(batch, obj.Key)
+			if len(batch) == retentionBatchSize {
+				if err := purgeOrphanBatch(batch); err != nil {
+					log.Printf("Retention: failed to purge orphan images: %v", err)
+					return
+				}
+				batch = batch[:0]
+			}
+		}
+		if err := purgeOrphanBatch(batch); err != nil {
+			log.Printf("Retention: failed to purge orphan images: %v", err)
+			return
+		}
+	}
+}
+
+func purgeOrphanBatch(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// A key is still needed when a plate event using it belongs to a permit
+	// (closed-permit retention and legal holds cover those) or is under
+	// investigation in the unmatched queue.
+	needed := `(ge.permit_id IS NOT NULL OR EXISTS (
+		SELECT 1 FROM unmatched_events ue
+		WHERE ue.status = @open AND (ue.plate_event_id = rpe.id OR ue.gate_event_id = rpe.gate_event_id)))`
+	var keep []string
+	if err := repository.DB.Raw(`
+		SELECT pei.key FROM plate_event_images pei
+		JOIN raw_plate_events rpe ON rpe.id = pei.raw_plate_event_id
+		LEFT JOIN gate_events ge ON ge.id = rpe.gate_event_id
+		WHERE pei.key IN @keys AND `+needed+`
+		UNION
+		SELECT rpe.image_key FROM raw_plate_events rpe
+		LEFT JOIN gate_events ge ON ge.id = rpe.gate_event_id
+		WHERE rpe.image_key IN @keys AND `+needed,
+		map[string]interface{}{"keys": keys, "open": models.UnmatchedStatusOpen},
+	).Scan(&keep).Error; err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		kept[k] = true
+	}
+	var orphans []string
+	for _, k := range keys {
+		if !kept[k] {
+			orphans = append(orphans, k)
+		}
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	// Rows first, as for permit evidence: an object left behind is found
+	// again on the next run.
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("key IN ?", orphans).Delete(&models.PlateEventImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RawPlateEvent{}).Where("image_key IN ?", orphans).Update("image_key", "").Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := removeImageObjects(orphans); err != nil {
+		return err
+	}
+	log.Printf("Retention: purged %d orphan images", len(orphans))
+	return nil
+}
+
+// purgeSystemEvents deletes raw system events older than cutoff. Rows that
+// raw plate/weight events point at would take those events with them (the
+// foreign key cascades), so their payload is blanked instead, unless the
+// event belongs to a permit under legal hold.
+func purgeSystemEvents(cutoff time.Time) {
+	for {
+		res := repository.DB.Exec(`
+			DELETE FROM system_events WHERE id IN (
+				SELECT se.id FROM system_events se
+				WHERE se.created_at < ?
+				AND NOT EXISTS (SELECT 1 FROM raw_plate_events p WHERE p.system_event_id = se.id)
+				AND NOT EXISTS (SELECT 1 FROM raw_weight_events w WHERE w.system_event_id = se.id)
+				LIMIT ?)`, cutoff, retentionBatchSize)
+		if res.Error != nil {
+			log.Printf("Retention: failed to delete system events: %v", res.Error)
+			return
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("Retention: deleted %d system events", res.RowsAffected)
+		}
+		if res.RowsAffected < retentionBatchSize {
+			break
+		}
+	}
+
+	for {
+		res := repository.DB.Exec(`
+			UPDATE system_events SET payload = '' WHERE id IN (
+				SELECT se.id FROM system_events se
+				WHERE se.created_at < ? AND se.payload <> ''
+				AND NOT EXISTS (
+					SELECT 1 FROM raw_plate_events p
+					JOIN gate_events g ON g.id = p.gate_event_id
+					JOIN permits pm ON pm.id = g.permit_id
+					WHERE p.system_event_id = se.id AND pm.legal_hold)
+				AND NOT EXISTS (
+					SELECT 1 FROM raw_weight_events w
+					JOIN gate_events g ON g.id = w.gate_event_id
+					JOIN permits pm ON pm.id = g.permit_id
+					WHERE w.system_event_id = se.id AND pm.legal_hold)
+				LIMIT ?)`, cutoff, retentionBatchSize)
+		if res.Error != nil {
+			log.Printf("Retention: failed to blank system event payloads: %v", res.Error)
+			return
+		}
+		if res.RowsAffected < retentionBatchSize {
+			return
+		}
+	}
+}
+
+// removeImageObjects deletes objects from the evidence bucket. Missing
+// objects are not an error.
+func removeImageObjects(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	objects := make(chan minio.ObjectInfo, len(keys))
+	for _, k := range keys {
+		objects <- minio.ObjectInfo{Key: k}
+	}
+	close(objects)
+
+	var firstErr error
+	for e := range repository.MinioClient.RemoveObjects(context.Background(), repository.BucketName, objects, minio.RemoveObjectsOptions{}) {
+		if e.Err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("remove %s: %w", e.ObjectName, e.Err)
+		}
+	}
+	return firstErr
+}
+
+func dedupeKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	var out []string
+	for _, k := range keys {
+		if k != "" && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
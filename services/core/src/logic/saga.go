@@ -0,0 +1,63 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// compensationTimeout bounds the undo of a failed saga. Compensations run on
+// their own context because the request's may already be cancelled.
+const compensationTimeout = 15 * time.Second
+
+// Saga runs an operation whose steps reach beyond core's database, such as
+// provisioning a device's API key in auth. Each step registers how to undo
+// it; when a later step fails, the registered compensations run newest
+// first so no side effect is left behind.
+type Saga struct {
+	name string
+	done []sagaStep
+}
+
+type sagaStep struct {
+	name       string
+	compensate func(ctx context.Context) error
+}
+
+func NewSaga(name string) *Saga {
+	return &Saga{name: name}
+}
+
+// Do runs action. On success compensate, which may be nil, is remembered; on
+// failure the earlier steps are compensated and action's error is returned.
+func (s *Saga) Do(step string, action func() error, compensate func(ctx context.Context) error) error {
+	if err := action(); err != nil {
+		s.rollback(step, err)
+		return err
+	}
+	if compensate != nil {
+		s.done = append(s.done, sagaStep{name: step, compensate: compensate})
+	}
+	return nil
+}
+
+// rollback undoes the completed steps. A compensation that fails leaves the
+// systems inconsistent; it is alerted on, and the reconciliation job reports
+// it until an operator resolves it.
+func (s *Saga) rollback(failed string, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), compensationTimeout)
+	defer cancel()
+
+	for i := len(s.done) - 1; i >= 0; i-- {
+		step := s.done[i]
+		if err := step.compensate(ctx); err != nil {
+			RaiseAlert("saga.compensation_failed",
+				fmt.Sprintf("%s: could not undo %q after %q failed: %v", s.name, step.name, failed, err),
+				nil, map[string]string{"saga": s.name, "step": step.name, "failed_step": failed, "cause": cause.Error(), "error": err.Error()})
+			continue
+		}
+		log.Printf("%s: undid %q after %q failed: %v", s.name, step.name, failed, cause)
+	}
+	s.done = nil
+}
@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+)
+
+const (
+	SettingMatchWindowSeconds        = "match_window_seconds"
+	SettingClockSkewAlertMs          = "clock_skew_alert_ms"
+	SettingDeviceDegradedAfterSec    = "device_degraded_after_seconds"
+	SettingDeviceOfflineAfterSec     = "device_offline_after_seconds"
+	SettingDeviceKeyGraceSeconds     = "device_key_grace_seconds"
+	SettingRetentionClosedPermitDays = "retention_closed_permit_days"
+	SettingRetentionOrphanImageDays  = "retention_orphan_image_days"
+	SettingRetentionSystemEventDays  = "retention_system_event_days"
+)
+
+// defaultSettings are created on startup when missing. Operators change them
+// through /configs/settings.
+var defaultSettings = []models.SystemSetting{
+	{Key: SettingMatchWindowSeconds, Value: "120"},
+	{Key: SettingClockSkewAlertMs, Value: "5000"},
+	{Key: SettingDeviceDegradedAfterSec, Value: "120"},
+	{Key: SettingDeviceOfflineAfterSec, Value: "600"},
+	{Key: SettingDeviceKeyGraceSeconds, Value: "86400"},
+	{Key: SettingRetentionClosedPermitDays, Value: "90"},
+	{Key: SettingRetentionOrphanImageDays, Value: "30"},
+	{Key: SettingRetentionSystemEventDays, Value: "30"},
+}
+
+func SeedSettings() {
+	for _, s := range defaultSettings {
+		setting := s
+		repository.DB.Where(models.SystemSetting{Key: s.Key}).FirstOrCreate(&setting)
+	}
+}
+
+// SettingInt reads an integer setting, falling back to def when it is missing
+// or malformed.
+func SettingInt(key string, def int) int {
+	var setting models.SystemSetting
+	if err := repository.DB.Where("key = ?", key).First(&setting).Error; err != nil {
+		return def
+	}
+	v, err := strconv.Atoi(setting.Value)
+	if err != nil {
+		log.Printf("Setting %s has invalid value %q, using %d", key, setting.Value, def)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,24 @@
+package logic
+
+import (
+	"testing"
+
+	"github.com/truckguard/core/src/repository"
+	"github.com/truckguard/core/src/testdb"
+	"gorm.io/gorm"
+)
+
+// testDB points repository.DB at a fresh test schema with the given models
+// migrated, and restores it afterwards.
+func testDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db := testdb.Open(t)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+
+	old := repository.DB
+	repository.DB = db
+	t.Cleanup(func() { repository.DB = old })
+	return db
+}
@@ -0,0 +1,251 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"gorm.io/gorm"
+)
+
+// Topology problem severities. Errors break permit tracking; warnings are
+// usually an unfinished setup.
+const (
+	TopologyError   = "error"
+	TopologyWarning = "warning"
+)
+
+// TopologyProblem is one finding of ValidateTopology.
+type TopologyProblem struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Kind     string `json:"kind"`
+	ID       uint   `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Message  string `json:"message"`
+}
+
+// TopologyReport lists every topology problem of the site.
+type TopologyReport struct {
+	Valid    bool              `json:"valid"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Problems []TopologyProblem `json:"problems"`
+}
+
+func (r *TopologyReport) add(severity, code, kind string, id uint, name, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, TopologyProblem{
+		Severity: severity,
+		Code:     code,
+		Kind:     kind,
+		ID:       id,
+		Name:     name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+	if severity == TopologyError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+// ValidateFlowPosition checks that a gate may be the step at sequence in a
+// flow whose steps run from first to last. A permit is opened at an entry
+// gate and closed at an exit gate, so those can only start and end a flow.
+func ValidateFlowPosition(gate models.Gate, sequence, first, last int) error {
+	switch {
+	case gate.IsEntry && gate.IsExit && first != last:
+		return fmt.Errorf("gate %q is both entry and exit, so it can only be the single step of a flow", gate.Name)
+	case gate.IsEntry && sequence != first:
+		return fmt.Errorf("entry gate %q must be the first step", gate.Name)
+	case gate.IsExit && sequence != last:
+		return fmt.Errorf("exit gate %q must be the last step", gate.Name)
+	}
+	return nil
+}
+
+// CheckFlowSteps checks the steps of one flow. Each step's Gate must be
+// loaded, nil meaning the gate does not exist. Problems are located like
+// "steps[1].sequence".
+func CheckFlowSteps(steps []models.FlowStep) []payloadparser.FieldError {
+	var errs []payloadparser.FieldError
+	add := func(loc, format string, args ...interface{}) {
+		errs = append(errs, payloadparser.FieldError{Location: loc, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(steps) == 0 {
+		add("steps", "a flow needs at least one step")
+		return errs
+	}
+
+	first, last := steps[0].Sequence, steps[0].Sequence
+	bySequence := map[int]int{}
+	byGate := map[uint]int{}
+	for i, step := range steps {
+		loc := fmt.Sprintf("steps[%d]", i)
+		if step.Sequence < 1 {
+			add(loc+".sequence", "must be 1 or more")
+		} else if j, ok := bySequence[step.Sequence]; ok {
+			add(loc+".sequence", "sequence %d is already used by steps[%d]", step.Sequence, j)
+		} else {
+			bySequence[step.Sequence] = i
+		}
+		if step.Gate == nil {
+			add(loc+".gate_id", "gate %d does not exist", step.GateID)
+		} else if j, ok := byGate[step.GateID]; ok {
+			add(loc+".gate_id", "gate %q is already steps[%d]", step.Gate.Name, j)
+		} else {
+			byGate[step.GateID] = i
+		}
+		if step.Sequence < first {
+			first = step.Sequence
+		}
+		if step.Sequence > last {
+			last = step.Sequence
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for i, step := range steps {
+		if err := ValidateFlowPosition(*step.Gate, step.Sequence, first, last); err != nil {
+			add(fmt.Sprintf("steps[%d].gate_id", i), "%v", err)
+		}
+	}
+	return errs
+}
+
+// ValidateFlow checks a flow's steps against the stored gates.
+func ValidateFlow(db *gorm.DB, steps []models.FlowStep) ([]payloadparser.FieldError, error) {
+	gateIDs := make([]uint, 0, len(steps))
+	for _, step := range steps {
+		gateIDs = append(gateIDs, step.GateID)
+	}
+	var gates []models.Gate
+	if err := db.Where("id IN ?", append(gateIDs, 0)).Find(&gates).Error; err != nil {
+		return nil, err
+	}
+	byID := map[uint]models.Gate{}
+	for _, g := range gates {
+		byID[g.ID] = g
+	}
+	checked := make([]models.FlowStep, len(steps))
+	for i, step := range steps {
+		checked[i] = step
+		checked[i].Gate = nil
+		if g, ok := byID[step.GateID]; ok {
+			checked[i].Gate = &g
+		}
+	}
+	return CheckFlowSteps(checked), nil
+}
+
+// ValidateGateFlows checks that gate, as it is about to be saved, still fits
+// the flows it is a step of.
+func ValidateGateFlows(db *gorm.DB, gate models.Gate) error {
+	var steps []models.FlowStep
+	if err := db.Preload("Flow.Steps").Where("gate_id = ?", gate.ID).Find(&steps).Error; err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if step.Flow == nil {
+			continue
+		}
+		first, last := flowBounds(step.Flow.Steps)
+		if err := ValidateFlowPosition(gate, step.Sequence, first, last); err != nil {
+			return fmt.Errorf("flow %q: %w", step.Flow.Name, err)
+		}
+	}
+	return nil
+}
+
+func flowBounds(steps []models.FlowStep) (first, last int) {
+	for i, step := range steps {
+		if i == 0 || step.Sequence < first {
+			first = step.Sequence
+		}
+		if i == 0 || step.Sequence > last {
+			last = step.Sequence
+		}
+	}
+	return first, last
+}
+
+// ValidateTopology reports every problem in how gates, flows and devices fit
+// together.
+func ValidateTopology(db *gorm.DB) (*TopologyReport, error) {
+	var gates []models.Gate
+	if err := db.Preload("Cameras").Preload("Scales").Order("id").Find(&gates).Error; err != nil {
+		return nil, err
+	}
+	var flows []models.Flow
+	if err := db.Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("sequence, id") }).
+		Order("id").Find(&flows).Error; err != nil {
+		return nil, err
+	}
+	var cameras []models.CameraConfig
+	if err := db.Order("id").Find(&cameras).Error; err != nil {
+		return nil, err
+	}
+	var scales []models.ScaleConfig
+	if err := db.Order("id").Find(&scales).Error; err != nil {
+		return nil, err
+	}
+
+	report := &TopologyReport{Problems: []TopologyProblem{}}
+	gatesByID := map[uint]models.Gate{}
+	hasEntry, hasExit := false, false
+	for _, g := range gates {
+		gatesByID[g.ID] = g
+		hasEntry = hasEntry || g.IsEntry
+		hasExit = hasExit || g.IsExit
+	}
+	if len(gates) > 0 && !hasEntry {
+		report.add(TopologyWarning, "site.no_entry_gate", "site", 0, "", "no gate is marked as entry")
+	}
+	if len(gates) > 0 && !hasExit {
+		report.add(TopologyWarning, "site.no_exit_gate", "site", 0, "", "no gate is marked as exit, so permits are never closed")
+	}
+
+	for _, f := range flows {
+		steps := make([]models.FlowStep, len(f.Steps))
+		for i, step := range f.Steps {
+			steps[i] = step
+			if g, ok := gatesByID[step.GateID]; ok {
+				steps[i].Gate = &g
+			}
+		}
+		for _, fe := range CheckFlowSteps(steps) {
+			report.add(TopologyError, "flow.invalid_steps", "flow", f.ID, f.Name, "%s: %s", fe.Location, fe.Message)
+		}
+	}
+
+	for _, g := range gates {
+		switch {
+		case len(g.Cameras) == 0 && len(g.Scales) == 0:
+			report.add(TopologyWarning, "gate.no_devices", "gate", g.ID, g.Name, "no camera or scale is assigned to the gate")
+		case len(g.Cameras) == 0:
+			report.add(TopologyWarning, "gate.no_camera", "gate", g.ID, g.Name, "no camera is assigned to the gate, so its weights are never matched to a plate")
+		}
+	}
+
+	checkGate := func(kind string, id uint, name string, gateID *uint) {
+		switch {
+		case gateID == nil:
+			report.add(TopologyWarning, kind+".no_gate", kind, id, name, "the %s is not assigned to a gate, so its events are not matched", kind)
+		default:
+			if _, ok := gatesByID[*gateID]; !ok {
+				report.add(TopologyError, kind+".unknown_gate", kind, id, name, "the %s is assigned to gate %d, which does not exist", kind, *gateID)
+			}
+		}
+	}
+	for _, c := range cameras {
+		checkGate(models.DeviceTypeCamera, c.ID, c.Name, c.GateID)
+	}
+	for _, s := range scales {
+		checkGate(models.DeviceTypeScale, s.ID, s.Name, s.GateID)
+	}
+
+	report.Valid = report.Errors == 0
+	return report, nil
+}
@@ -0,0 +1,306 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnmatchedClosed = errors.New("unmatched event is already closed")
+	ErrGateRequired    = errors.New("gate_id is required for events that never reached a gate")
+	ErrGateNotFound    = errors.New("gate not found")
+	ErrPermitNotFound  = errors.New("permit not found")
+	ErrNoOpenPermit    = errors.New("no open permit for plate and the gate is not an entry gate")
+)
+
+func recordUnmatched(u models.UnmatchedEvent, gateID *uint) {
+	query := repository.DB.Model(&models.UnmatchedEvent{}).
+		Where("status = ? AND reason = ?", models.UnmatchedStatusOpen, u.Reason)
+	switch {
+	case u.PlateEventID != nil:
+		query = query.Where("plate_event_id = ?", *u.PlateEventID)
+	case u.WeightEventID != nil:
+		query = query.Where("weight_event_id = ?", *u.WeightEventID)
+	case u.GateEventID != nil:
+		query = query.Where("gate_event_id = ?", *u.GateEventID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err == nil && count > 0 {
+		return
+	}
+
+	u.Status = models.UnmatchedStatusOpen
+	if err := repository.DB.Create(&u).Error; err != nil {
+		log.Printf("Failed to record unmatched event (%s): %v", u.Reason, err)
+		return
+	}
+	log.Printf("Recorded unmatched event %d: %s (%s)", u.ID, u.Reason, u.Details)
+	RaiseAlert("unmatched."+u.Reason, u.Details, gateID, u)
+}
+
+func RecordUnmatchedPlateEvent(event *models.RawPlateEvent, reason, details string) {
+	recordUnmatched(models.UnmatchedEvent{
+		Reason:       reason,
+		Details:      details,
+		PlateEventID: &event.ID,
+	}, event.Camera.GateID)
+}
+
+func RecordUnmatchedWeightEvent(event *models.RawWeightEvent, reason, details string) {
+	recordUnmatched(models.UnmatchedEvent{
+		Reason:        reason,
+		Details:       details,
+		WeightEventID: &event.ID,
+	}, event.Scale.GateID)
+}
+
+func RecordUnmatchedGateEvent(ge *models.GateEvent, details string) {
+	recordUnmatched(models.UnmatchedEvent{
+		Reason:      models.UnmatchedGateEventNoPermit,
+		Details:     details,
+		GateEventID: &ge.ID,
+	}, &ge.GateID)
+}
+
+// resolveUnmatchedGateEvent closes queue entries for a gate event that the
+// matchmaker managed to link to a permit after all (e.g. the plate arrived
+// after the weight).
+func resolveUnmatchedGateEvent(gateEventID, permitID uint) {
+	now := time.Now()
+	repository.DB.Model(&models.UnmatchedEvent{}).
+		Where("gate_event_id = ? AND status = ?", gateEventID, models.UnmatchedStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      models.UnmatchedStatusResolved,
+			"permit_id":   permitID,
+			"resolved_by": "matchmaker",
+			"resolved_at": &now,
+		})
+}
+
+func closeUnmatched(tx *gorm.DB, u *models.UnmatchedEvent, status string, permitID *uint, userID, note string) error {
+	now := time.Now()
+	u.Status = status
+	u.PermitID = permitID
+	u.ResolvedBy = userID
+	u.ResolvedAt = &now
+	u.ResolutionNote = note
+	return tx.Save(u).Error
+}
+
+// unmatchedGateEvent is the GateEvent behind a queue entry, with its gate and
+// the gate's flow steps. For a raw event that never reached a gate it is a
+// new GateEvent at the operator-chosen gate, written by insert together with
+// the rest of the resolution.
+type unmatchedGateEvent struct {
+	models.GateEvent
+	link    func(tx *gorm.DB, gateEventID uint) error
+	created bool
+}
+
+// findUnmatchedGateEvent loads or prepares the GateEvent behind a queue entry
+// without writing anything, so a missing gate is reported before the entry
+// is touched.
+func findUnmatchedGateEvent(u *models.UnmatchedEvent, gateID *uint) (*unmatchedGateEvent, error) {
+	existing := u.GateEventID
+	var ts time.Time
+	var link func(tx *gorm.DB, gateEventID uint) error
+
+	switch {
+	case existing != nil:
+	case u.PlateEventID != nil:
+		var pe models.RawPlateEvent
+		if err := repository.DB.First(&pe, *u.PlateEventID).Error; err != nil {
+			return nil, err
+		}
+		existing, ts = pe.GateEventID, pe.Timestamp
+		link = func(tx *gorm.DB, id uint) error {
+			return tx.Model(&pe).Update("gate_event_id", id).Error
+		}
+	case u.WeightEventID != nil:
+		var we models.RawWeightEvent
+		if err := repository.DB.First(&we, *u.WeightEventID).Error; err != nil {
+			return nil, err
+		}
+		existing, ts = we.GateEventID, we.Timestamp
+		link = func(tx *gorm.DB, id uint) error {
+			return tx.Model(&we).Update("gate_event_id", id).Error
+		}
+	default:
+		return nil, fmt.Errorf("unmatched event %d references no event", u.ID)
+	}
+
+	ge := &unmatchedGateEvent{link: link}
+	if existing != nil {
+		if err := repository.DB.Preload("Gate").Preload("Gate.FlowSteps").First(&ge.GateEvent, *existing).Error; err != nil {
+			return nil, err
+		}
+		return ge, nil
+	}
+
+	if gateID == nil {
+		return nil, ErrGateRequired
+	}
+	if err := repository.DB.Preload("FlowSteps").First(&ge.Gate, *gateID).Error; err != nil {
+		return nil, ErrGateNotFound
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	ge.GateID, ge.Timestamp = ge.Gate.ID, ts
+	return ge, nil
+}
+
+// insert writes a new GateEvent and links the raw event and the queue entry
+// to it. An existing GateEvent is left as it is.
+func (ge *unmatchedGateEvent) insert(tx *gorm.DB, u *models.UnmatchedEvent) error {
+	if ge.ID != 0 {
+		return nil
+	}
+	row := models.GateEvent{GateID: ge.GateID, Timestamp: ge.Timestamp}
+	if err := insertGateEvent(tx, &row); err != nil {
+		return err
+	}
+	if err := ge.link(tx, row.ID); err != nil {
+		return err
+	}
+	ge.ID, ge.CreatedAt, ge.UpdatedAt = row.ID, row.CreatedAt, row.UpdatedAt
+	ge.created = true
+	u.GateEventID = &ge.ID
+	return nil
+}
+
+// announce publishes a GateEvent insert wrote, once its transaction is
+// committed.
+func (ge *unmatchedGateEvent) announce() {
+	if !ge.created {
+		return
+	}
+	WakeOutboxRelay()
+	PublishLive(LiveGateEvent, "created", &ge.GateID, ge.GateEvent)
+}
+
+// AttachUnmatchedToPermit links the event behind a queue entry to an existing
+// permit and reprocesses the gate event so weights and plates are picked up.
+func AttachUnmatchedToPermit(u *models.UnmatchedEvent, permitID uint, gateID *uint, userID string) error {
+	if u.Status != models.UnmatchedStatusOpen {
+		return ErrUnmatchedClosed
+	}
+
+	var permit models.Permit
+	if err := repository.DB.First(&permit, permitID).Error; err != nil {
+		return ErrPermitNotFound
+	}
+
+	ge, err := findUnmatchedGateEvent(u, gateID)
+	if err != nil {
+		return err
+	}
+
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ge.insert(tx, u); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.GateEvent{}).Where("id = ?", ge.ID).
+			Update("permit_id", permit.ID).Error; err != nil {
+			return err
+		}
+		return closeUnmatched(tx, u, models.UnmatchedStatusAttached, &permit.ID, userID, "")
+	})
+	if err != nil {
+		return err
+	}
+	ge.announce()
+
+	go ProcessGateEventToPermit(ge.ID)
+	return nil
+}
+
+// AssignPlateToUnmatched sets the plate an operator read off the evidence. The
+// gate event is then linked to the open permit for that plate, or opens a new
+// permit when it happened at an entry gate. The gate and permit are settled
+// first; the correction, its PlateCorrected event, the gate event link and
+// the queue entry are then written in one transaction.
+func AssignPlateToUnmatched(u *models.UnmatchedEvent, plate string, gateID *uint, userID string) (*models.Permit, error) {
+	if u.Status != models.UnmatchedStatusOpen {
+		return nil, ErrUnmatchedClosed
+	}
+	plate = payloadparser.NormalizePlate(plate)
+
+	var event *models.RawPlateEvent
+	if u.PlateEventID != nil {
+		event = &models.RawPlateEvent{}
+		if err := repository.DB.First(event, *u.PlateEventID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	ge, err := findUnmatchedGateEvent(u, gateID)
+	if err != nil {
+		return nil, err
+	}
+
+	var permit models.Permit
+	err = repository.DB.Where("(plate_front = ? OR plate_back = ?) AND is_closed = ?", plate, plate, false).
+		First(&permit).Error
+	if err != nil {
+		if !ge.Gate.IsEntry {
+			return nil, ErrNoOpenPermit
+		}
+		created, err := createPermit(&ge.GateEvent, plate, nil)
+		if err != nil {
+			return nil, err
+		}
+		permit = *created
+	}
+
+	err = repository.DB.Transaction(func(tx *gorm.DB) error {
+		if event != nil {
+			if err := tx.Model(event).Updates(map[string]interface{}{
+				"plate_corrected": plate,
+				"corrected_by":    userID,
+				"is_manual":       true,
+			}).Error; err != nil {
+				return err
+			}
+			if err := AppendOutbox(tx, EventPlateCorrected, "plate_event", event.ID, map[string]interface{}{
+				"plate_event_id":  event.ID,
+				"plate":           event.Plate,
+				"plate_corrected": plate,
+				"corrected_by":    userID,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := ge.insert(tx, u); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.GateEvent{}).Where("id = ?", ge.ID).
+			Update("permit_id", permit.ID).Error; err != nil {
+			return err
+		}
+		return closeUnmatched(tx, u, models.UnmatchedStatusResolved, &permit.ID, userID, "plate assigned: "+plate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	WakeOutboxRelay()
+	ge.announce()
+
+	go ProcessGateEventToPermit(ge.ID)
+	return &permit, nil
+}
+
+func DismissUnmatched(u *models.UnmatchedEvent, note, userID string) error {
+	if u.Status != models.UnmatchedStatusOpen {
+		return ErrUnmatchedClosed
+	}
+	return closeUnmatched(repository.DB, u, models.UnmatchedStatusDismissed, nil, userID, note)
+}
@@ -0,0 +1,290 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	WebhookPermitOpened       = "permit.opened"
+	WebhookPermitStepAdvanced = "permit.step_advanced"
+	WebhookPermitWeighed      = "permit.weighed"
+	WebhookPermitClosed       = "permit.closed"
+	WebhookPermitFlowChanged  = "permit.flow_changed"
+
+	WebhookStatusPending   = "pending"
+	WebhookStatusSucceeded = "succeeded"
+	WebhookStatusFailed    = "failed"
+
+	webhookMaxAttempts = 8
+	webhookBatchSize   = 50
+	// webhookLease keeps a claimed delivery away from other replicas while
+	// it is being sent.
+	webhookLease = time.Minute
+)
+
+var WebhookEventTypes = []string{
+	WebhookPermitOpened,
+	WebhookPermitStepAdvanced,
+	WebhookPermitWeighed,
+	WebhookPermitClosed,
+	WebhookPermitFlowChanged,
+}
+
+var (
+	webhookWake   = make(chan struct{}, 1)
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// ParseWebhookEventTypes normalises a comma-separated list of event types and
+// rejects unknown ones. "*" subscribes to everything.
+func ParseWebhookEventTypes(raw string) (string, error) {
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if t != "*" && !knownWebhookEvent(t) {
+			return "", fmt.Errorf("unknown event type %q", t)
+		}
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		return "", fmt.Errorf("at least one event type is required")
+	}
+	return strings.Join(types, ","), nil
+}
+
+func knownWebhookEvent(eventType string) bool {
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func subscribedTo(sub models.WebhookSubscription, eventType string) bool {
+	for _, t := range strings.Split(sub.EventTypes, ",") {
+		if t == "*" || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EnqueueWebhooks stores one pending delivery per active subscription; the
+// dispatcher sends them in the background.
+func EnqueueWebhooks(eventType string, data interface{}) {
+	var subs []models.WebhookSubscription
+	if err := repository.DB.Where("is_active = ?", true).Find(&subs).Error; err != nil {
+		log.Printf("Failed to load webhook subscriptions: %v", err)
+		return
+	}
+
+	body, err := json.Marshal(WebhookPayload{Event: eventType, OccurredAt: time.Now(), Data: data})
+	if err != nil {
+		log.Printf("Failed to encode webhook payload %s: %v", eventType, err)
+		return
+	}
+
+	queued := false
+	for _, sub := range subs {
+		if !subscribedTo(sub, eventType) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventType:      eventType,
+			Payload:        string(body),
+			Status:         WebhookStatusPending,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := repository.DB.Create(&delivery).Error; err != nil {
+			log.Printf("Failed to queue webhook %s for subscription %d: %v", eventType, sub.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		wakeWebhookDispatcher()
+	}
+}
+
+// RedeliverWebhook queues a fresh copy of a past delivery, keeping the original in
+// the log.
+func RedeliverWebhook(original *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         WebhookStatusPending,
+		NextAttemptAt:  time.Now(),
+		RedeliveryOf:   &original.ID,
+	}
+	if err := repository.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	wakeWebhookDispatcher()
+	return &delivery, nil
+}
+
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+func RunWebhookDispatcher() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+		dispatchDueWebhooks()
+	}
+}
+
+func dispatchDueWebhooks() {
+	var due []models.WebhookDelivery
+
+	err := repository.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", WebhookStatusPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(webhookBatchSize).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(webhookLease)).Error
+	})
+	if err != nil {
+		log.Printf("Failed to claim webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		deliverWebhook(&due[i])
+	}
+}
+
+func deliverWebhook(d *models.WebhookDelivery) {
+	var sub models.WebhookSubscription
+	if err := repository.DB.First(&sub, d.SubscriptionID).Error; err != nil || !sub.IsActive {
+		d.Status = WebhookStatusFailed
+		d.LastError = "subscription is deleted or inactive"
+		repository.DB.Save(d)
+		return
+	}
+
+	statusCode, err := sendWebhook(sub, d)
+	recordWebhookAttempt(d, statusCode, err, time.Now())
+	if d.Status == WebhookStatusFailed {
+		log.Printf("Webhook delivery %d to %s failed permanently: %v", d.ID, sub.URL, err)
+	}
+
+	if err := repository.DB.Save(d).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// recordWebhookAttempt updates d with the outcome of one send: delivered,
+// due again after the backoff, or failed for good after webhookMaxAttempts.
+func recordWebhookAttempt(d *models.WebhookDelivery, statusCode int, err error, now time.Time) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+
+	switch {
+	case err == nil:
+		d.Status = WebhookStatusSucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = WebhookStatusFailed
+		d.LastError = err.Error()
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+	}
+}
+
+// webhookBackoff doubles the delay after each failed attempt: 30s, 1m, 2m ...
+// capped at one hour.
+func webhookBackoff(attempts int) time.Duration {
+	delay := 30 * time.Second << (attempts - 1)
+	if delay > time.Hour || delay <= 0 {
+		delay = time.Hour
+	}
+	return delay
+}
+
+func sendWebhook(sub models.WebhookSubscription, d *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewBufferString(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TruckGuard-Webhooks/1.0")
+	req.Header.Set("X-TruckGuard-Event", d.EventType)
+	req.Header.Set("X-TruckGuard-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-TruckGuard-Timestamp", timestamp)
+	req.Header.Set("X-TruckGuard-Signature", SignWebhook(sub.Secret, timestamp, []byte(d.Payload)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver returned status: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the X-TruckGuard-Signature value: an HMAC-SHA256 over
+// "<timestamp>.<body>" keyed with the subscription secret.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
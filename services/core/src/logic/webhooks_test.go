@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+)
+
+func TestSignWebhook(t *testing.T) {
+	got := SignWebhook("topsecret", "1700000000", []byte(`{"event":"permit.opened"}`))
+	want := "sha256=b417d168df2a6e7522bd127268631480ee6fb9ee58833348318eab13eac0a77f"
+	if got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+}
+
+func TestSendWebhookSignsRequest(t *testing.T) {
+	payload := `{"event":"permit.closed","data":{"id":4}}`
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sub := models.WebhookSubscription{URL: srv.URL, Secret: "s3cret"}
+	d := &models.WebhookDelivery{EventType: WebhookPermitClosed, Payload: payload}
+	d.ID = 42
+	status, err := sendWebhook(sub, d)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("sendWebhook = %d, %v", status, err)
+	}
+
+	if got.Method != http.MethodPost || string(body) != payload {
+		t.Errorf("receiver got %s %q", got.Method, body)
+	}
+	if got.Header.Get("X-TruckGuard-Event") != WebhookPermitClosed || got.Header.Get("X-TruckGuard-Delivery") != "42" ||
+		got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	timestamp := got.Header.Get("X-TruckGuard-Timestamp")
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("timestamp %q is not the current Unix time", timestamp)
+	}
+	// Verify the way a receiver would.
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	if sig := got.Header.Get("X-TruckGuard-Signature"); sig != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("signature %s does not verify", sig)
+	}
+}
+
+func TestSendWebhookFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	old := webhookClient
+	webhookClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { webhookClient = old }()
+
+	cases := []struct {
+		path   string
+		status int
+	}{
+		{"/unavailable", http.StatusServiceUnavailable},
+		{"/not-modified", http.StatusNotModified},
+		{"/slow", 0},
+	}
+	for _, tc := range cases {
+		status, err := sendWebhook(models.WebhookSubscription{URL: srv.URL + tc.path}, &models.WebhookDelivery{Payload: "{}"})
+		if err == nil || status != tc.status {
+			t.Errorf("%s: sendWebhook = %d, %v; want %d and an error", tc.path, status, err, tc.status)
+		}
+	}
+}
+
+func TestRecordWebhookAttempt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	d := &models.WebhookDelivery{Status: WebhookStatusPending}
+	recordWebhookAttempt(d, http.StatusBadGateway, errors.New("receiver returned status: 502"), now)
+	if d.Status != WebhookStatusPending || d.Attempts != 1 || d.LastStatusCode != http.StatusBadGateway ||
+		!d.NextAttemptAt.Equal(now.Add(30*time.Second)) || d.LastError == "" {
+		t.Errorf("after a 502: %+v", d)
+	}
+
+	recordWebhookAttempt(d, 0, errors.New("timeout"), now)
+	if d.Status != WebhookStatusPending || d.Attempts != 2 || d.LastStatusCode != 0 || !d.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("after a timeout: %+v", d)
+	}
+
+	recordWebhookAttempt(d, http.StatusOK, nil, now)
+	if d.Status != WebhookStatusSucceeded || d.Attempts != 3 || d.LastError != "" || d.DeliveredAt == nil || !d.DeliveredAt.Equal(now) {
+		t.Errorf("after success: %+v", d)
+	}
+
+	d = &models.WebhookDelivery{Status: WebhookStatusPending, Attempts: webhookMaxAttempts - 1}
+	recordWebhookAttempt(d, http.StatusInternalServerError, errors.New("receiver returned status: 500"), now)
+	if d.Status != WebhookStatusFailed || d.Attempts != webhookMaxAttempts {
+		t.Errorf("after the last attempt: %+v", d)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		40: time.Hour,
+		70: time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+// countingReceiver counts requests per delivery id and answers status.
+func countingReceiver(t *testing.T, status int) (*httptest.Server, func() map[string]int) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get("X-TruckGuard-Delivery")]++
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		copied := map[string]int{}
+		for k, v := range seen {
+			copied[k] = v
+		}
+		return copied
+	}
+}
+
+func TestDispatchSendsEachDeliveryOnceUnderLease(t *testing.T) {
+	db := testDB(t, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+	srv, seen := countingReceiver(t, http.StatusOK)
+
+	sub := models.WebhookSubscription{URL: srv.URL, EventTypes: "*", Secret: "s", IsActive: true}
+	db.Create(&sub)
+	for i := 0; i < 20; i++ {
+		db.Create(&models.WebhookDelivery{SubscriptionID: sub.ID, EventType: WebhookPermitOpened, Payload: "{}", Status: WebhookStatusPending, NextAttemptAt: time.Now()})
+	}
+
+	// Replicas dispatching at the same time must not send a delivery twice.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatchDueWebhooks()
+		}()
+	}
+	wg.Wait()
+	dispatchDueWebhooks()
+
+	got := seen()
+	if len(got) != 20 {
+		t.Errorf("%d deliveries sent, want 20", len(got))
+	}
+	for id, n := range got {
+		if n != 1 {
+			t.Errorf("delivery %s sent %d times", id, n)
+		}
+	}
+	var succeeded int64
+	db.Model(&models.WebhookDelivery{}).Where("status = ?", WebhookStatusSucceeded).Count(&succeeded)
+	if succeeded != 20 {
+		t.Errorf("%d deliveries succeeded, want 20", succeeded)
+	}
+}
+
+func TestDispatchBacksOffAfterServerError(t *testing.T) {
+	db := testDB(t, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+	srv, seen := countingReceiver(t, http.StatusInternalServerError)
+
+	sub := models.WebhookSubscription{URL: srv.URL, EventTypes: "*", IsActive: true}
+	db.Create(&sub)
+	d := models.WebhookDelivery{SubscriptionID: sub.ID, EventType: WebhookPermitOpened, Payload: "{}", Status: WebhookStatusPending, NextAttemptAt: time.Now()}
+	db.Create(&d)
+
+	before := time.Now()
+	dispatchDueWebhooks()
+	dispatchDueWebhooks()
+
+	if n := seen()[strconv.Itoa(int(d.ID))]; n != 1 {
+		t.Errorf("sent %d times before the backoff elapsed, want 1", n)
+	}
+	db.First(&d, d.ID)
+	if d.Status != WebhookStatusPending || d.Attempts != 1 || d.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("delivery after a 500: %+v", d)
+	}
+	if d.NextAttemptAt.Before(before.Add(30*time.Second)) || d.NextAttemptAt.After(time.Now().Add(31*time.Second)) {
+		t.Errorf("next attempt at %s, want about 30s from now", d.NextAttemptAt)
+	}
+}
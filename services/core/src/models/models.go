@@ -3,15 +3,16 @@ package models
 import (
 	"time"
 
+	"github.com/truckguard/core/src/payloadparser"
 	"gorm.io/gorm"
 )
 
 type CameraPreset struct {
 	gorm.Model
-	Name         string `json:"name"`
-	Format       string `json:"format"`
-	RunANPR      bool   `json:"run_anpr"`
-	FieldMapping string `json:"field_mapping"`
+	Name         string                `json:"name"`
+	Format       string                `json:"format"`
+	RunANPR      bool                  `json:"run_anpr"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
 
 	Cameras []CameraConfig `gorm:"foreignKey:PresetID" json:"cameras,omitempty"`
 }
@@ -28,9 +29,9 @@ type CameraConfig struct {
 	GateID *uint `json:"gate_id"`
 	Gate   *Gate `gorm:"foreignKey:GateID" json:"gate,omitempty"`
 
-	Format       string `json:"format"`
-	RunANPR      *bool  `json:"run_anpr"`
-	FieldMapping string `json:"field_mapping"`
+	Format       string                `json:"format"`
+	RunANPR      *bool                 `json:"run_anpr"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
 }
 
 type ScaleConfig struct {
@@ -39,13 +40,25 @@ type ScaleConfig struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 
-	Format       string `json:"format"`
-	FieldMapping string `json:"field_mapping"`
+	PresetID *uint        `json:"preset_id"`
+	Preset   *ScalePreset `gorm:"foreignKey:PresetID" json:"preset,omitempty"`
+
+	Format       string                `json:"format"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
 
 	GateID *uint `json:"gate_id"`
 	Gate   *Gate `gorm:"foreignKey:GateID" json:"gate,omitempty"`
 }
 
+type ScalePreset struct {
+	gorm.Model
+	Name         string                `json:"name"`
+	Format       string                `json:"format"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping"`
+
+	Scales []ScaleConfig `gorm:"foreignKey:PresetID" json:"scales,omitempty"`
+}
+
 type Gate struct {
 	gorm.Model
 	Name        string `json:"name"`
@@ -53,9 +66,17 @@ type Gate struct {
 	IsEntry     bool   `json:"is_entry" gorm:"default:false"`
 	IsExit      bool   `json:"is_exit" gorm:"default:false"`
 
-	Cameras  []CameraConfig `gorm:"foreignKey:GateID" json:"cameras,omitempty"`
-	Scales   []ScaleConfig  `gorm:"foreignKey:GateID" json:"scales,omitempty"`
-	FlowStep *FlowStep      `gorm:"foreignKey:GateID" json:"flow_step,omitempty"`
+	// Devices at the gate are degraded, then offline, after this long
+	// without a heartbeat or event. Nil uses the device_degraded_after_seconds
+	// and device_offline_after_seconds settings.
+	DegradedAfterSeconds *int `json:"degraded_after_seconds"`
+	OfflineAfterSeconds  *int `json:"offline_after_seconds"`
+
+	Cameras []CameraConfig `gorm:"foreignKey:GateID" json:"cameras,omitempty"`
+	Scales  []ScaleConfig  `gorm:"foreignKey:GateID" json:"scales,omitempty"`
+	// A gate can be a step of several flows, such as import and export
+	// routes sharing a weighbridge.
+	FlowSteps []FlowStep `gorm:"foreignKey:GateID" json:"flow_steps,omitempty"`
 }
 
 type Flow struct {
@@ -75,10 +96,56 @@ type FlowStep struct {
 	Flow *Flow `gorm:"foreignKey:FlowID" json:"flow,omitempty"`
 }
 
+// Where a permit's flow came from.
+const (
+	FlowSourceBooking  = "booking"
+	FlowSourceCarrier  = "carrier"
+	FlowSourceGate     = "gate"
+	FlowSourceOperator = "operator"
+)
+
+// Carrier is a haulier. Its vehicles follow its flow unless a booking says
+// otherwise. Codes are unique among carriers that are not deleted.
+type Carrier struct {
+	gorm.Model
+	Name   string `json:"name"`
+	Code   string `gorm:"uniqueIndex:idx_carriers_live_code,where:deleted_at IS NULL;not null" json:"code"`
+	FlowID *uint  `json:"flow_id"`
+	Flow   *Flow  `gorm:"foreignKey:FlowID" json:"flow,omitempty"`
+
+	Vehicles []CarrierVehicle `gorm:"foreignKey:CarrierID" json:"vehicles"`
+}
+
+// CarrierVehicle registers a plate to a carrier.
+type CarrierVehicle struct {
+	gorm.Model
+	CarrierID uint   `gorm:"index" json:"carrier_id"`
+	Plate     string `gorm:"index;not null" json:"plate"`
+}
+
+// Booking announces a vehicle's visit. The first permit opened for its plate
+// within the validity window uses it. References are unique among bookings
+// that are not deleted.
+type Booking struct {
+	gorm.Model
+	Reference  string     `gorm:"uniqueIndex:idx_bookings_live_reference,where:deleted_at IS NULL;not null" json:"reference"`
+	Plate      string     `gorm:"index;not null" json:"plate"`
+	CarrierID  *uint      `json:"carrier_id"`
+	Carrier    *Carrier   `gorm:"foreignKey:CarrierID" json:"carrier,omitempty"`
+	FlowID     *uint      `json:"flow_id"`
+	Flow       *Flow      `gorm:"foreignKey:FlowID" json:"flow,omitempty"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+	PermitID   *uint      `gorm:"index" json:"permit_id"`
+}
+
 type Permit struct {
 	gorm.Model
 	FlowID              *uint      `json:"flow_id"`
 	Flow                *Flow      `gorm:"foreignKey:FlowID" json:"flow,omitempty"`
+	FlowSource          string     `json:"flow_source,omitempty"`
+	BookingID           *uint      `json:"booking_id"`
+	CarrierID           *uint      `json:"carrier_id"`
 	PlateFront          string     `json:"plate_front"`
 	PlateBack           string     `json:"plate_back"`
 	TotalWeight         float64    `json:"total_weight"`
@@ -88,6 +155,12 @@ type Permit struct {
 	IsClosed            bool       `gorm:"default:false" json:"is_closed"`
 	CurrentStepSequence int        `json:"current_step_sequence"`
 
+	// LegalHold exempts the permit's evidence from retention purging.
+	LegalHold        bool       `gorm:"default:false" json:"legal_hold"`
+	LegalHoldReason  string     `json:"legal_hold_reason,omitempty"`
+	LegalHoldBy      string     `json:"legal_hold_by,omitempty"`
+	EvidencePurgedAt *time.Time `json:"evidence_purged_at"`
+
 	GateEvents []GateEvent `gorm:"foreignKey:PermitID" json:"gate_events"`
 }
 
@@ -126,6 +199,18 @@ type RawPlateEvent struct {
 	ImageKey         string    `json:"image_key"`
 	Timestamp        time.Time `json:"timestamp"`
 	Suggestions      string    `gorm:"type:jsonb" json:"suggestions"`
+	IdempotencyKey   *string   `gorm:"uniqueIndex" json:"idempotency_key,omitempty"`
+
+	// Timestamp is the event time used for matching. CapturedAt is the
+	// camera's own clock and ReceivedAt the ingestor's; see DeviceClock.
+	CapturedAt  *time.Time `json:"captured_at"`
+	ReceivedAt  *time.Time `json:"received_at"`
+	Buffered    bool       `json:"buffered"`
+	ClockSkewMs *int64     `json:"clock_skew_ms"`
+
+	// Images lists every attachment the camera sent; ImageKey stays the
+	// primary still (the plate crop when there is one).
+	Images []PlateEventImage `gorm:"foreignKey:RawPlateEventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"images"`
 
 	Camera CameraConfig `gorm:"references:CameraID" json:"-"`
 
@@ -136,12 +221,36 @@ type RawPlateEvent struct {
 	GateEvent   *GateEvent `gorm:"foreignKey:GateEventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+const (
+	ImageRolePlate    = "plate"
+	ImageRoleImage    = "image"
+	ImageRoleOverview = "overview"
+	ImageRoleClip     = "clip"
+)
+
+type PlateEventImage struct {
+	ID              uint   `gorm:"primaryKey" json:"id"`
+	RawPlateEventID uint   `gorm:"index" json:"raw_plate_event_id"`
+	Role            string `json:"role"`
+	Key             string `json:"key"`
+	ContentType     string `json:"content_type"`
+	Size            int64  `json:"size"`
+	Width           int    `json:"width,omitempty"`
+	Height          int    `json:"height,omitempty"`
+}
+
 type RawWeightEvent struct {
 	gorm.Model
-	ScaleSourceID string    `json:"scale_source_id"`
-	ScaleID       string    `gorm:"column:scale_id" json:"scale_id"`
-	Weight        float64   `json:"weight"`
-	Timestamp     time.Time `json:"timestamp"`
+	ScaleSourceID  string    `json:"scale_source_id"`
+	ScaleID        string    `gorm:"column:scale_id" json:"scale_id"`
+	Weight         float64   `json:"weight"`
+	Timestamp      time.Time `json:"timestamp"`
+	IdempotencyKey *string   `gorm:"uniqueIndex" json:"idempotency_key,omitempty"`
+
+	CapturedAt  *time.Time `json:"captured_at"`
+	ReceivedAt  *time.Time `json:"received_at"`
+	Buffered    bool       `json:"buffered"`
+	ClockSkewMs *int64     `json:"clock_skew_ms"`
 
 	Scale ScaleConfig `gorm:"references:ScaleID" json:"-"`
 
@@ -152,6 +261,118 @@ type RawWeightEvent struct {
 	GateEvent   *GateEvent `gorm:"foreignKey:GateEventID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 }
 
+const (
+	UnmatchedUnknownCamera     = "camera_unknown"
+	UnmatchedCameraNoGate      = "camera_no_gate"
+	UnmatchedUnknownScale      = "scale_unknown"
+	UnmatchedScaleNoGate       = "scale_no_gate"
+	UnmatchedGateNotFound      = "gate_not_found"
+	UnmatchedGateEventNoPermit = "gate_event_no_permit"
+
+	UnmatchedStatusOpen      = "open"
+	UnmatchedStatusAttached  = "attached"
+	UnmatchedStatusResolved  = "resolved"
+	UnmatchedStatusDismissed = "dismissed"
+)
+
+type UnmatchedEvent struct {
+	gorm.Model
+	Reason  string `gorm:"index;not null" json:"reason"`
+	Status  string `gorm:"index;default:open" json:"status"`
+	Details string `json:"details"`
+
+	PlateEventID  *uint           `json:"plate_event_id"`
+	PlateEvent    *RawPlateEvent  `gorm:"foreignKey:PlateEventID;constraint:OnDelete:CASCADE;" json:"plate_event,omitempty"`
+	WeightEventID *uint           `json:"weight_event_id"`
+	WeightEvent   *RawWeightEvent `gorm:"foreignKey:WeightEventID;constraint:OnDelete:CASCADE;" json:"weight_event,omitempty"`
+	GateEventID   *uint           `json:"gate_event_id"`
+	GateEvent     *GateEvent      `gorm:"foreignKey:GateEventID;constraint:OnDelete:CASCADE;" json:"gate_event,omitempty"`
+
+	PermitID       *uint      `json:"permit_id"`
+	ResolvedBy     string     `json:"resolved_by"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	ResolutionNote string     `json:"resolution_note"`
+}
+
+type WebhookSubscription struct {
+	gorm.Model
+	Name       string `json:"name"`
+	URL        string `gorm:"not null" json:"url"`
+	EventTypes string `json:"event_types"`
+	Secret     string `json:"-"`
+	IsActive   bool   `gorm:"default:true" json:"is_active"`
+}
+
+type WebhookDelivery struct {
+	gorm.Model
+	SubscriptionID uint                 `gorm:"index" json:"subscription_id"`
+	Subscription   *WebhookSubscription `gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE;" json:"-"`
+	EventType      string               `json:"event_type"`
+	Payload        string               `gorm:"type:jsonb" json:"payload"`
+	Status         string               `gorm:"index;default:pending" json:"status"`
+	Attempts       int                  `json:"attempts"`
+	NextAttemptAt  time.Time            `gorm:"index" json:"next_attempt_at"`
+	LastStatusCode int                  `json:"last_status_code"`
+	LastError      string               `json:"last_error"`
+	DeliveredAt    *time.Time           `json:"delivered_at"`
+	RedeliveryOf   *uint                `json:"redelivery_of"`
+}
+
+// OutboxEvent is a domain event written in the same transaction as the state
+// change it describes; the relay publishes it to the core:events stream.
+type OutboxEvent struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	AggregateType string     `gorm:"index" json:"aggregate_type"`
+	AggregateID   uint       `json:"aggregate_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `gorm:"type:jsonb" json:"payload"`
+	CreatedAt     time.Time  `json:"created_at"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at"`
+}
+
+const (
+	DeviceTypeCamera = "camera"
+	DeviceTypeScale  = "scale"
+)
+
+// DeviceClock tracks how far a device's clock is from the ingestor's, from
+// the capture and receive times of its live (not buffered) events.
+type DeviceClock struct {
+	gorm.Model
+	DeviceType string `gorm:"uniqueIndex:idx_device_clock" json:"device_type"`
+	SourceID   string `gorm:"uniqueIndex:idx_device_clock" json:"source_id"`
+	GateID     *uint  `json:"gate_id"`
+	// SkewMs is smoothed over recent samples; positive means the device
+	// clock is ahead.
+	SkewMs       int64     `json:"skew_ms"`
+	LastSkewMs   int64     `json:"last_skew_ms"`
+	Samples      int64     `json:"samples"`
+	LastSampleAt time.Time `json:"last_sample_at"`
+	Drifting     bool      `json:"drifting"`
+}
+
+const (
+	DeviceStatusUnknown  = "unknown"
+	DeviceStatusOnline   = "online"
+	DeviceStatusDegraded = "degraded"
+	DeviceStatusOffline  = "offline"
+)
+
+// DeviceHealth is when a camera or scale was last heard from, by heartbeat
+// or event, and the status the device monitor derived from it.
+type DeviceHealth struct {
+	gorm.Model
+	DeviceType      string     `gorm:"uniqueIndex:idx_device_health" json:"device_type"`
+	SourceID        string     `gorm:"uniqueIndex:idx_device_health" json:"source_id"`
+	Name            string     `json:"name"`
+	GateID          *uint      `json:"gate_id"`
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at"`
+	LastEventAt     *time.Time `json:"last_event_at"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+	Status          string     `gorm:"index" json:"status"`
+	StatusSince     time.Time  `json:"status_since"`
+}
+
 type SystemSetting struct {
 	gorm.Model
 	Key   string `gorm:"uniqueIndex;not null" json:"key"`
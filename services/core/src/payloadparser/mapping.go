@@ -0,0 +1,304 @@
+package payloadparser
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Transforms applied, in order, to an extracted value.
+const (
+	TransformTrim         = "trim"
+	TransformUpper        = "upper"
+	TransformLower        = "lower"
+	TransformRemoveSpaces = "remove_spaces"
+	TransformAlphanumeric = "alphanumeric"
+	TransformNumeric      = "numeric"
+)
+
+var Transforms = []string{
+	TransformTrim, TransformUpper, TransformLower,
+	TransformRemoveSpaces, TransformAlphanumeric, TransformNumeric,
+}
+
+// Units maps the units a weight may be reported in to kilograms.
+var Units = map[string]float64{
+	"kg": 1,
+	"t":  1000,
+	"g":  0.001,
+	"lb": 0.45359237,
+}
+
+// Extractor says where a field is found in a payload and how the value is
+// cleaned up.
+//
+// Path is a JSONPath ("$.data.plates[0].text") for json payloads or an XPath
+// ("/Event/Plate/@value", "/Event/Plate[2]/text()") for xml; the older slash
+// form ("data/plate") is accepted for both. Regex is applied to the whole
+// payload for the regex format, otherwise to the value found at Path; the
+// first capture group, or the whole match, is kept. Unit is the unit a
+// weight is reported in and is converted to kg.
+type Extractor struct {
+	Path       string   `json:"path,omitempty"`
+	Regex      string   `json:"regex,omitempty"`
+	Transforms []string `json:"transforms,omitempty"`
+	Unit       string   `json:"unit,omitempty"`
+}
+
+// UnmarshalJSON also accepts the older shorthand, a bare string holding the
+// path (or, for the regex format, the pattern; see Mapping.Normalize).
+func (e *Extractor) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var path string
+		if err := json.Unmarshal(data, &path); err != nil {
+			return err
+		}
+		*e = Extractor{Path: path}
+		return nil
+	}
+	type plain Extractor
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*e = Extractor(p)
+	return nil
+}
+
+// Mapping maps a field (plate, weight) to its extractor. It is stored as a
+// jsonb column.
+type Mapping map[string]Extractor
+
+// ParseMapping decodes a mapping from its JSON text. An empty string is an
+// empty mapping.
+func ParseMapping(raw string) (Mapping, error) {
+	m := Mapping{}
+	if strings.TrimSpace(raw) == "" {
+		return m, nil
+	}
+	type plain Mapping
+	if err := json.Unmarshal([]byte(raw), (*plain)(&m)); err != nil {
+		return nil, fmt.Errorf("field_mapping must be a JSON object of extractors: %w", err)
+	}
+	return m, nil
+}
+
+// UnmarshalJSON accepts the mapping as an object or, as older clients send
+// it, as a string holding the JSON object.
+func (m *Mapping) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	} else if strings.TrimSpace(raw) == "null" {
+		raw = ""
+	}
+	parsed, err := ParseMapping(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Mapping) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[string]Extractor(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *Mapping) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	default:
+		return fmt.Errorf("field_mapping: cannot scan %T", value)
+	}
+	parsed, err := ParseMapping(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// GormDataType stores the mapping as jsonb.
+func (Mapping) GormDataType() string {
+	return "jsonb"
+}
+
+// Normalize returns the mapping in its canonical form for format: shorthand
+// patterns are moved to Regex for the regex format and transform names are
+// lower cased.
+func (m Mapping) Normalize(format string) Mapping {
+	out := make(Mapping, len(m))
+	for field, ex := range m {
+		if NormalizeFormat(format) == FormatRegex && ex.Regex == "" {
+			ex.Regex, ex.Path = ex.Path, ""
+		}
+		if len(ex.Transforms) > 0 {
+			ts := make([]string, len(ex.Transforms))
+			for i, t := range ex.Transforms {
+				ts[i] = strings.ToLower(strings.TrimSpace(t))
+			}
+			ex.Transforms = ts
+		}
+		ex.Unit = strings.ToLower(strings.TrimSpace(ex.Unit))
+		out[field] = ex
+	}
+	return out
+}
+
+// FieldError is one problem in a mapping. Location points at the offending
+// value, e.g. "field_mapping.plate.transforms[1]".
+type FieldError struct {
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// ValidationError lists every problem found in a format and mapping.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Location + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(location, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks that format is known and that every mapped field is one of
+// fields with an extractor the adapters can run. It returns a
+// *ValidationError listing all problems found.
+func Validate(format string, m Mapping, fields ...string) error {
+	verr := &ValidationError{}
+	format = NormalizeFormat(format)
+	if !knownFormat(format) {
+		verr.add("format", "%v %q, expected one of %s", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+		return verr
+	}
+
+	m = m.Normalize(format)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, field := range keys {
+		loc := "field_mapping." + field
+		if !contains(fields, field) {
+			verr.add(loc, "unknown field, expected one of %s", strings.Join(fields, ", "))
+			continue
+		}
+		validateExtractor(verr, loc, format, field, m[field])
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func validateExtractor(verr *ValidationError, loc, format, field string, ex Extractor) {
+	switch {
+	case format == FormatRegex:
+		if ex.Path != "" {
+			verr.add(loc+".path", "not used with the regex format, the pattern goes in regex")
+		}
+		if strings.TrimSpace(ex.Regex) == "" {
+			verr.add(loc+".regex", "must not be empty")
+		}
+	case strings.TrimSpace(ex.Path) == "":
+		verr.add(loc+".path", "must not be empty")
+	default:
+		if _, err := compilePath(ex.Path, format); err != nil {
+			verr.add(loc+".path", "%v", err)
+		}
+	}
+
+	if ex.Regex != "" {
+		re, err := regexp.Compile(ex.Regex)
+		if err != nil {
+			verr.add(loc+".regex", "invalid regular expression: %v", err)
+		} else if re.NumSubexp() > 1 {
+			verr.add(loc+".regex", "use at most one capture group")
+		}
+	}
+
+	for i, t := range ex.Transforms {
+		if !contains(Transforms, t) {
+			verr.add(fmt.Sprintf("%s.transforms[%d]", loc, i), "unknown transform %q, expected one of %s", t, strings.Join(Transforms, ", "))
+		}
+	}
+
+	if ex.Unit != "" {
+		if field != FieldWeight {
+			verr.add(loc+".unit", "only applies to weight")
+		} else if _, ok := Units[ex.Unit]; !ok {
+			verr.add(loc+".unit", "unknown unit %q, expected one of %s", ex.Unit, strings.Join(unitNames(), ", "))
+		}
+	}
+}
+
+func applyTransforms(v string, transforms []string) string {
+	for _, t := range transforms {
+		switch t {
+		case TransformTrim:
+			v = strings.TrimSpace(v)
+		case TransformUpper:
+			v = strings.ToUpper(v)
+		case TransformLower:
+			v = strings.ToLower(v)
+		case TransformRemoveSpaces:
+			v = strings.Join(strings.Fields(v), "")
+		case TransformAlphanumeric:
+			v = keepRunes(v, func(r rune) bool {
+				return r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			})
+		case TransformNumeric:
+			v = keepRunes(v, func(r rune) bool {
+				return r >= '0' && r <= '9' || r == '.' || r == '-'
+			})
+		}
+	}
+	return v
+}
+
+func keepRunes(s string, keep func(rune) bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		if keep(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func unitNames() []string {
+	names := make([]string, 0, len(Units))
+	for u := range Units {
+		names = append(names, u)
+	}
+	sort.Strings(names)
+	return names
+}
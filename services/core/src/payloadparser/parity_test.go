@@ -0,0 +1,57 @@
+package payloadparser
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+)
+
+// parityCase is one entry of testdata/parity.json. The adapters' Python
+// payload_parser.py runs the same file, so both sides extract the same value
+// (nil meaning none) and, for weights, the same kilograms.
+type parityCase struct {
+	Name    string   `json:"name"`
+	Format  string   `json:"format"`
+	Payload string   `json:"payload"`
+	Mapping Mapping  `json:"mapping"`
+	Field   string   `json:"field"`
+	Value   *string  `json:"value"`
+	KG      *float64 `json:"kg"`
+}
+
+func loadParityCases(t *testing.T) []parityCase {
+	t.Helper()
+	data, err := os.ReadFile("testdata/parity.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []parityCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+func TestParityWithAdapters(t *testing.T) {
+	for _, tc := range loadParityCases(t) {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := Extract(tc.Payload, tc.Format, tc.Mapping, tc.Field)
+			switch {
+			case tc.Value == nil && err == nil:
+				t.Fatalf("extracted %q, want no value", got)
+			case tc.Value != nil && err != nil:
+				t.Fatalf("extract failed: %v, want %q", err, *tc.Value)
+			case tc.Value != nil && got != *tc.Value:
+				t.Fatalf("extracted %q, want %q", got, *tc.Value)
+			}
+
+			if tc.KG != nil {
+				kg, err := ExtractWeight(tc.Payload, tc.Format, tc.Mapping)
+				if err != nil || math.Abs(kg-*tc.KG) > 1e-9 {
+					t.Errorf("weight = %v kg, %v; want %v kg", kg, err, *tc.KG)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package payloadparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one hop of a compiled path: a key lookup, a list index, or both
+// ("Plate[2]").
+type step struct {
+	key      string
+	index    int
+	hasIndex bool
+	text     bool
+}
+
+// compilePath parses a JSONPath (json), an XPath (xml) or the older slash
+// form. Only the subset needed to address a single value is supported:
+// member names, list indexes, XML attributes and text().
+func compilePath(path, format string) ([]step, error) {
+	if format == FormatJSON && strings.HasPrefix(path, "$") {
+		return compileJSONPath(path)
+	}
+	return compileSlashPath(path, format == FormatXML)
+}
+
+func compileJSONPath(path string) ([]step, error) {
+	var steps []step
+	i := 1
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			j := i + 1
+			for j < len(path) && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("empty member name at offset %d", i+1)
+			}
+			steps = append(steps, step{key: path[i+1 : j]})
+			i = j
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ at offset %d", i)
+			}
+			inner := path[i+1 : i+end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, step{key: inner[1 : len(inner)-1]})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid index %q at offset %d, expected a number or a quoted name", inner, i)
+				}
+				steps = append(steps, step{index: n, hasIndex: true})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d, expected . or [", path[i], i)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("path selects the whole document, not a value")
+	}
+	return steps, nil
+}
+
+// compileSlashPath parses "a/b/c". For xml it is an XPath: segments may
+// carry a 1-based index ("Plate[2]"), and the last may be "@attr" or
+// "text()".
+func compileSlashPath(path string, xpath bool) ([]step, error) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	steps := make([]step, 0, len(segments))
+	for i, seg := range segments {
+		if seg == "" {
+			return nil, fmt.Errorf("segment %d is empty", i+1)
+		}
+		if !xpath {
+			steps = append(steps, step{key: seg})
+			continue
+		}
+		last := i == len(segments)-1
+		if seg == "text()" {
+			if !last {
+				return nil, fmt.Errorf("text() must be the last segment")
+			}
+			steps = append(steps, step{text: true})
+			continue
+		}
+		if strings.HasPrefix(seg, "@") && !last {
+			return nil, fmt.Errorf("attribute %s must be the last segment", seg)
+		}
+		s := step{key: seg}
+		if open := strings.IndexByte(seg, '['); open >= 0 {
+			if !strings.HasSuffix(seg, "]") {
+				return nil, fmt.Errorf("segment %d: unclosed [", i+1)
+			}
+			n, err := strconv.Atoi(seg[open+1 : len(seg)-1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("segment %d: XPath indexes start at 1", i+1)
+			}
+			s = step{key: seg[:open], index: n - 1, hasIndex: true}
+		}
+		if s.key == "" {
+			return nil, fmt.Errorf("segment %d has no element name", i+1)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// resolve walks doc along steps. For xml a repeated element without an index
+// selects its first occurrence, as XPath does for a single value.
+func resolve(doc interface{}, steps []step, xml bool) (interface{}, bool) {
+	for _, s := range steps {
+		if s.text {
+			if obj, ok := doc.(map[string]interface{}); ok {
+				doc = obj["#text"]
+			}
+			continue
+		}
+		if s.key != "" {
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[s.key]; !ok {
+				return nil, false
+			}
+		}
+		list, isList := doc.([]interface{})
+		switch {
+		case s.hasIndex && isList:
+			if s.index >= len(list) {
+				return nil, false
+			}
+			doc = list[s.index]
+		case s.hasIndex && xml && s.index == 0:
+			// A single element is its own first occurrence.
+		case s.hasIndex:
+			return nil, false
+		case isList && xml && len(list) > 0:
+			doc = list[0]
+		}
+	}
+	return doc, true
+}
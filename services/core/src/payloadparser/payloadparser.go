@@ -0,0 +1,174 @@
+// Package payloadparser extracts plate and weight values from device payloads
+// using a config's Format and FieldMapping. It follows the adapters' rules so
+// core can validate mappings and preview what the adapters will extract.
+package payloadparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatXML   = "xml"
+	FormatRegex = "regex"
+
+	FieldPlate  = "plate"
+	FieldWeight = "weight"
+)
+
+var Formats = []string{FormatJSON, FormatXML, FormatRegex}
+
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrNotMapped     = errors.New("field is not mapped")
+	ErrNoValue       = errors.New("no value at mapped path")
+)
+
+// NormalizeFormat returns the effective format; adapters treat an empty
+// format as json.
+func NormalizeFormat(format string) string {
+	if format == "" {
+		return FormatJSON
+	}
+	return strings.ToLower(format)
+}
+
+// Decode parses payload into the tree paths are resolved against: JSON
+// values, or for XML the xmltodict layout (attributes as "@name", text next
+// to attributes as "#text", repeated elements as lists). Regex payloads stay
+// a string.
+func Decode(payload, format string) (interface{}, error) {
+	switch NormalizeFormat(format) {
+	case FormatJSON:
+		dec := json.NewDecoder(strings.NewReader(payload))
+		dec.UseNumber()
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+		return doc, nil
+	case FormatXML:
+		doc, err := decodeXML(bytes.NewReader([]byte(payload)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML payload: %w", err)
+		}
+		return doc, nil
+	case FormatRegex:
+		return payload, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// Extract returns the value mapped to field, after the extractor's regex and
+// transforms.
+func Extract(payload, format string, m Mapping, field string) (string, error) {
+	format = NormalizeFormat(format)
+	ex, ok := m.Normalize(format)[field]
+	if !ok || (ex.Path == "" && ex.Regex == "") {
+		return "", fmt.Errorf("%s: %w", field, ErrNotMapped)
+	}
+
+	value := payload
+	if format != FormatRegex {
+		steps, err := compilePath(ex.Path, format)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", field, err)
+		}
+		doc, err := Decode(payload, format)
+		if err != nil {
+			return "", err
+		}
+		node, found := resolve(doc, steps, format == FormatXML)
+		if obj, isObj := node.(map[string]interface{}); isObj && format == FormatXML {
+			// An element with attributes; its value is its text.
+			node = obj["#text"]
+		}
+		if value, ok = scalar(node); !found || !ok || value == "" {
+			return "", fmt.Errorf("%s: %w %q", field, ErrNoValue, ex.Path)
+		}
+	}
+
+	if ex.Regex != "" {
+		re, err := regexp.Compile(ex.Regex)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindStringSubmatch(value)
+		if match == nil {
+			return "", fmt.Errorf("%s: %w", field, ErrNoValue)
+		}
+		value = match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+	}
+
+	value = applyTransforms(value, ex.Transforms)
+	if value == "" {
+		return "", fmt.Errorf("%s: %w", field, ErrNoValue)
+	}
+	return value, nil
+}
+
+// NormalizePlate writes a plate the way the camera adapter sends it to core:
+// upper case without spaces. Plates entered by hand must be normalized the
+// same way to match events.
+func NormalizePlate(plate string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(plate), " ", ""))
+}
+
+// ExtractPlate returns the plate as the camera adapter sends it to core.
+func ExtractPlate(payload, format string, m Mapping) (string, error) {
+	v, err := Extract(payload, format, m, FieldPlate)
+	if err != nil {
+		return "", err
+	}
+	return NormalizePlate(v), nil
+}
+
+// ExtractWeight returns the weight in kg.
+func ExtractWeight(payload, format string, m Mapping) (float64, error) {
+	v, err := Extract(payload, format, m, FieldWeight)
+	if err != nil {
+		return 0, err
+	}
+	w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return 0, fmt.Errorf("weight: %q is not a number", v)
+	}
+	if factor, ok := Units[m.Normalize(format)[FieldWeight].Unit]; ok {
+		w *= factor
+	}
+	return w, nil
+}
+
+func scalar(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
+
+func knownFormat(format string) bool {
+	return contains(Formats, format)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package payloadparser
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeXMLLikeXmltodict(t *testing.T) {
+	doc, err := Decode(`<Event xmlns="urn:x" id="7"><Plate conf="90"> AB1 </Plate><Pic>a</Pic><Pic>b</Pic><Empty/></Event>`, FormatXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Event": map[string]interface{}{
+			"@xmlns": "urn:x",
+			"@id":    "7",
+			"Plate":  map[string]interface{}{"@conf": "90", "#text": "AB1"},
+			"Pic":    []interface{}{"a", "b"},
+			"Empty":  nil,
+		},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Decode = %#v", doc)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		payload, format string
+		err             error
+	}{
+		{"{", FormatJSON, nil},
+		{"<a><b></a>", FormatXML, nil},
+		{"", FormatXML, nil},
+		{"x", "csv", ErrUnknownFormat},
+	}
+	for _, tc := range cases {
+		_, err := Decode(tc.payload, tc.format)
+		if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("Decode(%q, %s) = %v", tc.payload, tc.format, err)
+		}
+	}
+	if doc, err := Decode("anything", FormatRegex); err != nil || doc != "anything" {
+		t.Errorf("regex payload decoded to %v, %v", doc, err)
+	}
+}
+
+func TestCompilePath(t *testing.T) {
+	cases := []struct {
+		path, format string
+		want         []step
+		err          bool
+	}{
+		{"$.a.b[2]", FormatJSON, []step{{key: "a"}, {key: "b"}, {index: 2, hasIndex: true}}, false},
+		{`$["x.y"]['z']`, FormatJSON, []step{{key: "x.y"}, {key: "z"}}, false},
+		{"a/b", FormatJSON, []step{{key: "a"}, {key: "b"}}, false},
+		{"/A/B[2]/@c", FormatXML, []step{{key: "A"}, {key: "B", index: 1, hasIndex: true}, {key: "@c"}}, false},
+		{"A/text()", FormatXML, []step{{key: "A"}, {text: true}}, false},
+		{"$", FormatJSON, nil, true},
+		{"$.", FormatJSON, nil, true},
+		{"$.a[", FormatJSON, nil, true},
+		{"$.a[-1]", FormatJSON, nil, true},
+		{"$a", FormatJSON, nil, true},
+		{"a//b", FormatJSON, nil, true},
+		{"A/text()/B", FormatXML, nil, true},
+		{"A/@b/C", FormatXML, nil, true},
+		{"A[0]", FormatXML, nil, true},
+		{"A[1", FormatXML, nil, true},
+		{"[1]", FormatXML, nil, true},
+	}
+	for _, tc := range cases {
+		got, err := compilePath(tc.path, tc.format)
+		if (err != nil) != tc.err || (!tc.err && !reflect.DeepEqual(got, tc.want)) {
+			t.Errorf("compilePath(%q, %s) = %+v, %v", tc.path, tc.format, got, err)
+		}
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		format  string
+		mapping Mapping
+		err     error
+	}{
+		{"unmapped", `{}`, FormatJSON, Mapping{}, ErrNotMapped},
+		{"empty extractor", `{}`, FormatJSON, Mapping{FieldPlate: {}}, ErrNotMapped},
+		{"missing value", `{"a": 1}`, FormatJSON, Mapping{FieldPlate: {Path: "$.b"}}, ErrNoValue},
+		{"regex without match", `{"a": "x"}`, FormatJSON, Mapping{FieldPlate: {Path: "$.a", Regex: `\d`}}, ErrNoValue},
+		{"emptied by transforms", `{"a": "--"}`, FormatJSON, Mapping{FieldPlate: {Path: "$.a", Transforms: []string{TransformAlphanumeric}}}, ErrNoValue},
+	}
+	for _, tc := range cases {
+		if _, err := Extract(tc.payload, tc.format, tc.mapping, FieldPlate); !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+		}
+	}
+	if _, err := Extract(`{`, FormatJSON, Mapping{FieldPlate: {Path: "$.a"}}, FieldPlate); err == nil {
+		t.Error("malformed payload was accepted")
+	}
+}
+
+func TestExtractPlateNormalizes(t *testing.T) {
+	got, err := ExtractPlate(`{"p": " ab 12 cd "}`, FormatJSON, Mapping{FieldPlate: {Path: "$.p"}})
+	if err != nil || got != "AB12CD" {
+		t.Errorf("ExtractPlate = %q, %v", got, err)
+	}
+}
+
+func TestExtractWeight(t *testing.T) {
+	if _, err := ExtractWeight(`{"w": "heavy"}`, FormatJSON, Mapping{FieldWeight: {Path: "$.w"}}); err == nil {
+		t.Error("non-numeric weight was accepted")
+	}
+	w, err := ExtractWeight(`{"w": " 2.5 "}`, FormatJSON, Mapping{FieldWeight: {Path: "$.w", Unit: " T "}})
+	if err != nil || w != 2500 {
+		t.Errorf("ExtractWeight = %v, %v", w, err)
+	}
+}
+
+func TestApplyTransforms(t *testing.T) {
+	cases := []struct {
+		in         string
+		transforms []string
+		want       string
+	}{
+		{" a b ", []string{TransformTrim}, "a b"},
+		{"aB", []string{TransformUpper}, "AB"},
+		{"aB", []string{TransformLower}, "ab"},
+		{" a \t b\n", []string{TransformRemoveSpaces}, "ab"},
+		{"AB-12 ÄÖ", []string{TransformAlphanumeric}, "AB12"},
+		{"-1,234.5 kg", []string{TransformNumeric}, "-1234.5"},
+		{" ab-1 ", []string{TransformUpper, TransformAlphanumeric}, "AB1"},
+		{"x", nil, "x"},
+	}
+	for _, tc := range cases {
+		if got := applyTransforms(tc.in, tc.transforms); got != tc.want {
+			t.Errorf("applyTransforms(%q, %v) = %q, want %q", tc.in, tc.transforms, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		format  string
+		mapping Mapping
+		want    []string
+	}{
+		{"valid json", FormatJSON, Mapping{FieldPlate: {Path: "$.p", Transforms: []string{"Upper"}}}, nil},
+		{"valid regex shorthand", FormatRegex, Mapping{FieldWeight: {Path: `(\d+)`, Unit: "t"}}, nil},
+		{"unknown format", "csv", Mapping{}, []string{"format"}},
+		{"unknown field", FormatJSON, Mapping{"speed": {Path: "$.s"}}, []string{"field_mapping.speed"}},
+		{"empty path", FormatJSON, Mapping{FieldPlate: {Path: " "}}, []string{"field_mapping.plate.path"}},
+		{"bad path", FormatXML, Mapping{FieldPlate: {Path: "A[0]"}}, []string{"field_mapping.plate.path"}},
+		{"path with regex format", FormatRegex, Mapping{FieldPlate: {Path: "p", Regex: "x"}}, []string{"field_mapping.plate.path"}},
+		{"bad regex", FormatJSON, Mapping{FieldPlate: {Path: "$.p", Regex: "("}}, []string{"field_mapping.plate.regex"}},
+		{"two groups", FormatJSON, Mapping{FieldPlate: {Path: "$.p", Regex: "(a)(b)"}}, []string{"field_mapping.plate.regex"}},
+		{
+			"every problem is listed",
+			FormatJSON,
+			Mapping{
+				FieldPlate:  {Path: "$.p", Transforms: []string{"trim", "reverse"}, Unit: "kg"},
+				FieldWeight: {Path: "$.w", Unit: "stone"},
+			},
+			[]string{"field_mapping.plate.transforms[1]", "field_mapping.plate.unit", "field_mapping.weight.unit"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.format, tc.mapping, FieldPlate, FieldWeight)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("Validate = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate = %v, want a ValidationError", err)
+			}
+			var got []string
+			for _, fe := range verr.Errors {
+				got = append(got, fe.Location)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("locations = %v, want %v (%v)", got, tc.want, err)
+			}
+		})
+	}
+}
+
+func TestMappingJSON(t *testing.T) {
+	want := Mapping{FieldPlate: {Path: "$.p"}, FieldWeight: {Path: "$.w", Unit: "t"}}
+	inputs := []string{
+		`{"plate": "$.p", "weight": {"path": "$.w", "unit": "t"}}`,
+		`"{\"plate\": \"$.p\", \"weight\": {\"path\": \"$.w\", \"unit\": \"t\"}}"`,
+	}
+	for _, in := range inputs {
+		var m Mapping
+		if err := json.Unmarshal([]byte(in), &m); err != nil || !reflect.DeepEqual(m, want) {
+			t.Errorf("Unmarshal(%s) = %+v, %v", in, m, err)
+		}
+	}
+
+	var m Mapping
+	if err := json.Unmarshal([]byte(`null`), &m); err != nil || m == nil || len(m) != 0 {
+		t.Errorf("null mapping = %#v, %v", m, err)
+	}
+	if err := json.Unmarshal([]byte(`[1]`), &m); err == nil {
+		t.Error("array mapping was accepted")
+	}
+}
+
+func TestMappingDatabaseValue(t *testing.T) {
+	v, err := Mapping(nil).Value()
+	if err != nil || v != "{}" {
+		t.Errorf("nil mapping stored as %v, %v", v, err)
+	}
+
+	stored, err := Mapping{FieldPlate: {Path: "$.p"}}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Mapping
+	if err := m.Scan([]byte(stored.(string))); err != nil || m[FieldPlate].Path != "$.p" {
+		t.Errorf("round trip = %+v, %v", m, err)
+	}
+	for _, v := range []interface{}{nil, "", " "} {
+		if err := m.Scan(v); err != nil || len(m) != 0 {
+			t.Errorf("Scan(%#v) = %+v, %v", v, m, err)
+		}
+	}
+	if err := m.Scan(42); err == nil {
+		t.Error("Scan(42) was accepted")
+	}
+}
+
+func TestNormalizePlate(t *testing.T) {
+	for in, want := range map[string]string{" ab 12 cd ": "AB12CD", "AB12CD": "AB12CD", "": "", "a-1": "A-1"} {
+		if got := NormalizePlate(in); got != want {
+			t.Errorf("NormalizePlate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package payloadparser
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// decodeXML builds the same tree as Python's xmltodict.parse, which the
+// adapters use: the root element is the single top-level key.
+func decodeXML(r io.Reader) (map[string]interface{}, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("no root element")
+			}
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			value, err := decodeElement(dec, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{xmlName(start.Name): value}, nil
+		}
+	}
+}
+
+func decodeElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		node["@"+xmlName(attr.Name)] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := xmlName(t.Name)
+			switch existing := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(existing, child)
+			default:
+				node[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				if s == "" {
+					return nil, nil
+				}
+				return s, nil
+			}
+			if s != "" {
+				node["#text"] = s
+			}
+			return node, nil
+		}
+	}
+}
+
+// xmlName keeps the local name; xmltodict only keeps prefixes that are
+// written in the document, and ANPR payloads use a default namespace.
+func xmlName(n xml.Name) string {
+	if n.Space == "xmlns" {
+		return "xmlns:" + n.Local
+	}
+	return n.Local
+}
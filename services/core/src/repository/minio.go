@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+var (
+	// MinioClient talks to MinIO on the internal network.
+	MinioClient *minio.Client
+	// MinioPresignClient signs URLs for the endpoint browsers can reach
+	// (MINIO_PUBLIC_ENDPOINT). Presigning is offline, so the region must be
+	// known up front.
+	MinioPresignClient *minio.Client
+	BucketName         = os.Getenv("BUCKET_NAME")
+)
+
+func InitMinio(endpoint, accessKey, secretKey string) {
+	region := os.Getenv("MINIO_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	publicEndpoint := os.Getenv("MINIO_PUBLIC_ENDPOINT")
+	if publicEndpoint == "" {
+		publicEndpoint = endpoint
+	}
+
+	MinioClient = newMinioClient(endpoint, accessKey, secretKey, region, os.Getenv("MINIO_USE_SSL") == "true")
+	MinioPresignClient = newMinioClient(publicEndpoint, accessKey, secretKey, region, os.Getenv("MINIO_PUBLIC_USE_SSL") == "true")
+}
+
+func newMinioClient(endpoint, accessKey, secretKey, region string, secure bool) *minio.Client {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: secure,
+		Region: region,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize Minio: %v", err))
+	}
+	return client
+}
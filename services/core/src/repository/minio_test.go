@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// keepMinioClients restores the package clients InitMinio replaces.
+func keepMinioClients(t *testing.T) {
+	client, presign := MinioClient, MinioPresignClient
+	t.Cleanup(func() { MinioClient, MinioPresignClient = client, presign })
+}
+
+func TestInitMinioSignsForPublicEndpoint(t *testing.T) {
+	keepMinioClients(t)
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/evidence/plate.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", "4")
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		case "/evidence/private.jpg":
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`))
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("MINIO_REGION", "")
+	t.Setenv("MINIO_USE_SSL", "")
+	t.Setenv("MINIO_PUBLIC_ENDPOINT", "files.example.com")
+	t.Setenv("MINIO_PUBLIC_USE_SSL", "true")
+	internal := strings.TrimPrefix(srv.URL, "http://")
+	InitMinio(internal, "access", "secret")
+
+	if u := MinioClient.EndpointURL(); u.Host != internal || u.Scheme != "http" {
+		t.Errorf("internal client uses %s", u)
+	}
+	if u := MinioPresignClient.EndpointURL(); u.Host != "files.example.com" || u.Scheme != "https" {
+		t.Errorf("presign client uses %s", u)
+	}
+
+	ctx := context.Background()
+	u, err := MinioPresignClient.PresignedGetObject(ctx, "evidence", "plate.jpg", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "files.example.com" || !strings.Contains(u.Query().Get("X-Amz-Credential"), "/us-east-1/s3/") {
+		t.Errorf("presigned URL %s is not for the public endpoint in the default region", u)
+	}
+
+	if _, err := MinioClient.StatObject(ctx, "evidence", "plate.jpg", minio.StatObjectOptions{}); err != nil {
+		t.Errorf("stat of a stored object: %v", err)
+	}
+	_, err = MinioClient.StatObject(ctx, "evidence", "gone.jpg", minio.StatObjectOptions{})
+	if code := minio.ToErrorResponse(err).Code; code != "NoSuchKey" {
+		t.Errorf("stat of a missing object gave %q (%v)", code, err)
+	}
+	_, err = MinioClient.StatObject(ctx, "evidence", "private.jpg", minio.StatObjectOptions{})
+	if code := minio.ToErrorResponse(err).Code; code != "AccessDenied" {
+		t.Errorf("stat of a forbidden object gave %q (%v)", code, err)
+	}
+
+	// Presigning happens offline and the region is configured, so the only
+	// requests are the three stats and none looks up the bucket location.
+	if len(requests) != 3 {
+		t.Errorf("MinIO saw %v", requests)
+	}
+	for _, r := range requests {
+		if strings.Contains(r, "location") || !strings.HasPrefix(r, "HEAD /evidence/") {
+			t.Errorf("unexpected request %s", r)
+		}
+	}
+}
+
+func TestInitMinioRegion(t *testing.T) {
+	keepMinioClients(t)
+	t.Setenv("MINIO_REGION", "eu-central-1")
+	t.Setenv("MINIO_PUBLIC_ENDPOINT", "")
+	InitMinio("minio:9000", "access", "secret")
+
+	if MinioPresignClient.EndpointURL().Host != "minio:9000" {
+		t.Errorf("presign client without MINIO_PUBLIC_ENDPOINT uses %s", MinioPresignClient.EndpointURL())
+	}
+	u, err := MinioPresignClient.PresignedGetObject(context.Background(), "evidence", "plate.jpg", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(u.Query().Get("X-Amz-Credential"), "/eu-central-1/s3/") {
+		t.Errorf("presigned URL %s is not for eu-central-1", u)
+	}
+}
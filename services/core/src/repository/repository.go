@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -17,24 +22,134 @@ func InitDB(dsn string) {
 		panic("Failed to connect to Core Database")
 	}
 
-	db.AutoMigrate(
+	if err := migrateFieldMappings(db); err != nil {
+		panic(fmt.Sprintf("Failed to migrate field mappings: %v", err))
+	}
+	if err := db.AutoMigrate(
 		&models.SystemEvent{},
 		&models.RawPlateEvent{},
+		&models.PlateEventImage{},
 		&models.RawWeightEvent{},
 		&models.CameraConfig{},
 		&models.ScaleConfig{},
 		&models.CameraPreset{},
+		&models.ScalePreset{},
 		&models.Gate{},
 		&models.Flow{},
 		&models.FlowStep{},
 		&models.SystemSetting{},
 		&models.ExcludedPlate{},
+		&models.Carrier{},
+		&models.CarrierVehicle{},
+		&models.Booking{},
 		&models.Permit{},
 		&models.User{},
-	)
+		&models.UnmatchedEvent{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.DeviceClock{},
+		&models.DeviceHealth{},
+	); err != nil {
+		panic(fmt.Sprintf("Failed to migrate Core Database: %v", err))
+	}
+	if err := dropReplacedIndexes(db); err != nil {
+		panic(fmt.Sprintf("Failed to drop replaced indexes: %v", err))
+	}
 	DB = db
 }
 
+// dropReplacedIndexes removes unique indexes that also covered soft-deleted
+// rows, so a deleted carrier's code or booking's reference could not be used
+// again. Partial indexes over live rows replace them.
+func dropReplacedIndexes(db *gorm.DB) error {
+	replaced := []struct {
+		model interface{}
+		name  string
+	}{
+		{&models.Carrier{}, "idx_carriers_code"},
+		{&models.Booking{}, "idx_bookings_reference"},
+	}
+	for _, r := range replaced {
+		if db.Migrator().HasIndex(r.model, r.name) {
+			if err := db.Migrator().DropIndex(r.model, r.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldMappingTables have a field_mapping column that was text before it
+// became jsonb.
+var fieldMappingTables = []string{"camera_presets", "camera_configs", "scale_presets", "scale_configs"}
+
+// migrateFieldMappings readies field_mapping columns that are still text for
+// their change to jsonb. Values that parse as a mapping, the older
+// string-wrapped form included, are rewritten as a JSON object. Anything else
+// would fail the cast, so it is cleared to an empty mapping and logged for
+// the device to be mapped again.
+func migrateFieldMappings(db *gorm.DB) error {
+	for _, table := range fieldMappingTables {
+		var dataType string
+		if err := db.Raw(`SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'field_mapping'`, table).Scan(&dataType).Error; err != nil {
+			return err
+		}
+		if dataType != "text" {
+			continue
+		}
+
+		var rows []struct {
+			ID           uint
+			FieldMapping *string
+		}
+		if err := db.Table(table).Select("id, field_mapping").Find(&rows).Error; err != nil {
+			return fmt.Errorf("read %s: %w", table, err)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				raw := ""
+				if row.FieldMapping != nil {
+					raw = *row.FieldMapping
+				}
+				value, err := legacyFieldMapping(raw)
+				if err != nil {
+					log.Printf("Clearing field_mapping of %s %d, %q is not a mapping: %v", table, row.ID, raw, err)
+				}
+				if row.FieldMapping != nil && value == raw {
+					continue
+				}
+				if err := tx.Table(table).Where("id = ?", row.ID).Update("field_mapping", value).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("migrate %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// legacyFieldMapping returns the JSON object a text field_mapping becomes,
+// "{}" together with the parse error when it holds no mapping.
+func legacyFieldMapping(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "{}", nil
+	}
+	var m payloadparser.Mapping
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return "{}", err
+	}
+	value, err := m.Value()
+	if err != nil {
+		return "{}", err
+	}
+	return value.(string), nil
+}
+
 var RDB *redis.Client
 
 func InitRedis(addr string) {
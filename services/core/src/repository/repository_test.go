@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/testdb"
+)
+
+func TestLegacyFieldMapping(t *testing.T) {
+	cases := []struct {
+		raw, want string
+		err       bool
+	}{
+		{"", "{}", false},
+		{"  ", "{}", false},
+		{"null", "{}", false},
+		{`{"plate": "$.p"}`, `{"plate":{"path":"$.p"}}`, false},
+		{`"{\"weight\": {\"path\": \"w\", \"unit\": \"t\"}}"`, `{"weight":{"path":"w","unit":"t"}}`, false},
+		{"data/plate", "{}", true},
+		{`"data/plate"`, "{}", true},
+		{`["plate"]`, "{}", true},
+		{`{"plate": 1}`, "{}", true},
+	}
+	for _, tc := range cases {
+		got, err := legacyFieldMapping(tc.raw)
+		if got != tc.want || (err != nil) != tc.err {
+			t.Errorf("legacyFieldMapping(%q) = %s, %v; want %s", tc.raw, got, err, tc.want)
+		}
+	}
+}
+
+func TestMigrateFieldMappingsBeforeJSONB(t *testing.T) {
+	db := testdb.Open(t)
+	// The column as it was before it became jsonb.
+	if err := db.Exec(`CREATE TABLE camera_configs (id bigserial PRIMARY KEY, name text, camera_id text,
+		field_mapping text, created_at timestamptz, updated_at timestamptz, deleted_at timestamptz)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	legacy := map[string]*string{
+		"empty":   strPtr(""),
+		"null":    nil,
+		"object":  strPtr(`{"plate": "$.p"}`),
+		"wrapped": strPtr(`"{\"plate\": \"$.q\"}"`),
+		"garbage": strPtr("data/plate"),
+	}
+	for name, fm := range legacy {
+		if err := db.Exec("INSERT INTO camera_configs (name, camera_id, field_mapping) VALUES (?, ?, ?)", name, name, fm).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateFieldMappings(db); err != nil {
+		t.Fatalf("migrateFieldMappings: %v", err)
+	}
+	if err := db.Migrator().AlterColumn(&models.CameraConfig{}, "FieldMapping"); err != nil {
+		t.Fatalf("text to jsonb migration failed: %v", err)
+	}
+
+	var configs []models.CameraConfig
+	if err := db.Find(&configs).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"empty": "", "null": "", "object": "$.p", "wrapped": "$.q", "garbage": ""}
+	if len(configs) != len(want) {
+		t.Fatalf("got %d configs", len(configs))
+	}
+	for _, c := range configs {
+		if got := c.FieldMapping["plate"].Path; got != want[c.Name] {
+			t.Errorf("%s: plate path = %q, want %q", c.Name, got, want[c.Name])
+		}
+	}
+
+	// Once the column is jsonb the migration leaves it alone.
+	if err := migrateFieldMappings(db); err != nil {
+		t.Errorf("second run: %v", err)
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,265 @@
+package siteconfig
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/truckguard/core/src/models"
+	"gorm.io/gorm"
+)
+
+// importLockID serializes imports, so two cannot both create the same gate.
+const importLockID = 7265048
+
+// ErrConcurrentChange means the stored configuration changed between
+// planning an import and applying it, so the devices to create no longer
+// match the API keys issued for them.
+var ErrConcurrentChange = errors.New("site configuration changed during import, retry")
+
+// Result is what Apply wrote, so the caller can announce the changes.
+type Result struct {
+	Plan          *Plan
+	Cameras       []models.CameraConfig
+	Scales        []models.ScaleConfig
+	CameraPresets []uint
+	ScalePresets  []uint
+}
+
+// Apply imports doc in tx. sourceIDs holds the source ID of the API key
+// issued for each camera and scale the plan creates, by ObjectKey. Objects
+// not in doc are left alone, and unchanged objects are not written.
+func Apply(tx *gorm.DB, doc Document, sourceIDs map[string]string) (*Result, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", importLockID).Error; err != nil {
+		return nil, err
+	}
+	s, err := loadSite(tx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plan(&doc)
+	if err != nil {
+		return nil, err
+	}
+	newDevices := plan.NewDevices()
+	if len(newDevices) != len(sourceIDs) {
+		return nil, ErrConcurrentChange
+	}
+	for _, d := range newDevices {
+		if sourceIDs[ObjectKey(d.Kind, d.Key)] == "" {
+			return nil, ErrConcurrentChange
+		}
+	}
+
+	a := &applier{tx: tx, site: s, plan: plan, result: &Result{Plan: plan}}
+	steps := []func(Document) error{
+		a.settings, a.excludedPlates, a.cameraPresets, a.scalePresets, a.gates, a.flows,
+		func(doc Document) error { return a.cameras(doc, sourceIDs) },
+		func(doc Document) error { return a.scales(doc, sourceIDs) },
+	}
+	for _, step := range steps {
+		if err := step(doc); err != nil {
+			return nil, err
+		}
+	}
+	return a.result, nil
+}
+
+type applier struct {
+	tx     *gorm.DB
+	site   *site
+	plan   *Plan
+	result *Result
+
+	cameraPresetIDs map[string]uint
+	scalePresetIDs  map[string]uint
+	gateIDs         map[string]uint
+}
+
+func (a *applier) changed(kind, key string) bool {
+	return a.plan.action(kind, key) != ActionUnchanged
+}
+
+func (a *applier) settings(doc Document) error {
+	for key, value := range doc.Settings {
+		if !a.changed(KindSetting, key) {
+			continue
+		}
+		setting, ok := a.site.settingsByKey[key]
+		if !ok {
+			setting = models.SystemSetting{Key: key}
+		}
+		setting.Value = string(value)
+		if err := a.tx.Save(&setting).Error; err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (a *applier) excludedPlates(doc Document) error {
+	for _, e := range doc.ExcludedPlates {
+		if !a.changed(KindExcludedPlate, e.Plate) {
+			continue
+		}
+		plate, ok := a.site.excludedByPlate[e.Plate]
+		if !ok {
+			plate = models.ExcludedPlate{Plate: e.Plate}
+		}
+		plate.Comment = e.Comment
+		if err := a.tx.Save(&plate).Error; err != nil {
+			return fmt.Errorf("excluded plate %s: %w", e.Plate, err)
+		}
+	}
+	return nil
+}
+
+func (a *applier) cameraPresets(doc Document) error {
+	a.cameraPresetIDs = map[string]uint{}
+	for name, presets := range a.site.cameraPresetsByName {
+		a.cameraPresetIDs[name] = presets[0].ID
+	}
+	for _, e := range doc.CameraPresets {
+		var preset models.CameraPreset
+		if existing := a.site.cameraPresetsByName[e.Name]; len(existing) == 1 {
+			preset = existing[0]
+		}
+		if preset.ID != 0 && !a.changed(KindCameraPreset, e.Name) {
+			continue
+		}
+		preset.Name, preset.Format, preset.RunANPR, preset.FieldMapping = e.Name, e.Format, e.RunANPR, e.FieldMapping
+		if err := a.tx.Save(&preset).Error; err != nil {
+			return fmt.Errorf("camera preset %s: %w", e.Name, err)
+		}
+		a.cameraPresetIDs[e.Name] = preset.ID
+		a.result.CameraPresets = append(a.result.CameraPresets, preset.ID)
+	}
+	return nil
+}
+
+func (a *applier) scalePresets(doc Document) error {
+	a.scalePresetIDs = map[string]uint{}
+	for name, presets := range a.site.scalePresetsByName {
+		a.scalePresetIDs[name] = presets[0].ID
+	}
+	for _, e := range doc.ScalePresets {
+		var preset models.ScalePreset
+		if existing := a.site.scalePresetsByName[e.Name]; len(existing) == 1 {
+			preset = existing[0]
+		}
+		if preset.ID != 0 && !a.changed(KindScalePreset, e.Name) {
+			continue
+		}
+		preset.Name, preset.Format, preset.FieldMapping = e.Name, e.Format, e.FieldMapping
+		if err := a.tx.Save(&preset).Error; err != nil {
+			return fmt.Errorf("scale preset %s: %w", e.Name, err)
+		}
+		a.scalePresetIDs[e.Name] = preset.ID
+		a.result.ScalePresets = append(a.result.ScalePresets, preset.ID)
+	}
+	return nil
+}
+
+func (a *applier) gates(doc Document) error {
+	a.gateIDs = map[string]uint{}
+	for name, gates := range a.site.gatesByName {
+		a.gateIDs[name] = gates[0].ID
+	}
+	for _, e := range doc.Gates {
+		var gate models.Gate
+		if existing := a.site.gatesByName[e.Name]; len(existing) == 1 {
+			gate = existing[0]
+		}
+		if gate.ID != 0 && !a.changed(KindGate, e.Name) {
+			continue
+		}
+		gate.Name, gate.Description, gate.IsEntry, gate.IsExit = e.Name, e.Description, e.IsEntry, e.IsExit
+		gate.DegradedAfterSeconds, gate.OfflineAfterSeconds = e.DegradedAfterSeconds, e.OfflineAfterSeconds
+		if err := a.tx.Save(&gate).Error; err != nil {
+			return fmt.Errorf("gate %s: %w", e.Name, err)
+		}
+		a.gateIDs[e.Name] = gate.ID
+	}
+	return nil
+}
+
+func (a *applier) flows(doc Document) error {
+	for _, e := range doc.Flows {
+		var flow models.Flow
+		if existing := a.site.flowsByName[e.Name]; len(existing) == 1 {
+			flow = existing[0]
+		}
+		if flow.ID != 0 && !a.changed(KindFlow, e.Name) {
+			continue
+		}
+		flow.Name, flow.Description, flow.Steps = e.Name, e.Description, nil
+		if err := a.tx.Save(&flow).Error; err != nil {
+			return fmt.Errorf("flow %s: %w", e.Name, err)
+		}
+		if err := a.tx.Where("flow_id = ?", flow.ID).Delete(&models.FlowStep{}).Error; err != nil {
+			return fmt.Errorf("flow %s: %w", e.Name, err)
+		}
+		for _, s := range e.Steps {
+			step := models.FlowStep{FlowID: flow.ID, GateID: a.gateIDs[s.Gate], Sequence: s.Sequence}
+			if err := a.tx.Create(&step).Error; err != nil {
+				return fmt.Errorf("flow %s: %w", e.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *applier) cameras(doc Document, sourceIDs map[string]string) error {
+	for _, e := range doc.Cameras {
+		var config models.CameraConfig
+		if existing := a.site.camerasByName[e.Name]; len(existing) == 1 {
+			config = existing[0]
+		} else {
+			config.SourceID = sourceIDs[ObjectKey(KindCamera, e.Name)]
+		}
+		if config.ID != 0 && !a.changed(KindCamera, e.Name) {
+			continue
+		}
+		config.Name, config.Description, config.Format = e.Name, e.Description, e.Format
+		config.RunANPR, config.FieldMapping = e.RunANPR, e.FieldMapping
+		config.PresetID = ref(a.cameraPresetIDs, e.Preset)
+		config.GateID = ref(a.gateIDs, e.Gate)
+		config.Preset, config.Gate = nil, nil
+		if err := a.tx.Save(&config).Error; err != nil {
+			return fmt.Errorf("camera %s: %w", e.Name, err)
+		}
+		a.result.Cameras = append(a.result.Cameras, config)
+	}
+	return nil
+}
+
+func (a *applier) scales(doc Document, sourceIDs map[string]string) error {
+	for _, e := range doc.Scales {
+		var config models.ScaleConfig
+		if existing := a.site.scalesByName[e.Name]; len(existing) == 1 {
+			config = existing[0]
+		} else {
+			config.SourceID = sourceIDs[ObjectKey(KindScale, e.Name)]
+		}
+		if config.ID != 0 && !a.changed(KindScale, e.Name) {
+			continue
+		}
+		config.Name, config.Description, config.Format = e.Name, e.Description, e.Format
+		config.FieldMapping = e.FieldMapping
+		config.PresetID = ref(a.scalePresetIDs, e.Preset)
+		config.GateID = ref(a.gateIDs, e.Gate)
+		config.Preset, config.Gate = nil, nil
+		if err := a.tx.Save(&config).Error; err != nil {
+			return fmt.Errorf("scale %s: %w", e.Name, err)
+		}
+		a.result.Scales = append(a.result.Scales, config)
+	}
+	return nil
+}
+
+func ref(ids map[string]uint, name string) *uint {
+	if name == "" {
+		return nil
+	}
+	id := ids[name]
+	return &id
+}
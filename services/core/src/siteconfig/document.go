@@ -0,0 +1,176 @@
+// Package siteconfig describes a site's topology - gates, cameras, scales,
+// presets, flows, settings and excluded plates - as one versioned document,
+// exports it and imports it back. Objects are matched by natural key (names,
+// plates, setting keys) rather than database IDs, so a document exported
+// from one site can set up another.
+package siteconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/truckguard/core/src/payloadparser"
+)
+
+// Version is the document version this build reads and writes.
+const Version = 1
+
+// Document formats.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Document is a site's configuration. A section that is left out is not
+// touched by an import; an entry that is present describes its object
+// completely, so a field left out of an entry is cleared.
+type Document struct {
+	Version        int                     `json:"version"`
+	ExportedAt     *time.Time              `json:"exported_at,omitempty"`
+	Settings       map[string]SettingValue `json:"settings,omitempty"`
+	ExcludedPlates []ExcludedPlate         `json:"excluded_plates,omitempty"`
+	CameraPresets  []CameraPreset          `json:"camera_presets,omitempty"`
+	ScalePresets   []ScalePreset           `json:"scale_presets,omitempty"`
+	Gates          []Gate                  `json:"gates,omitempty"`
+	Flows          []Flow                  `json:"flows,omitempty"`
+	Cameras        []Camera                `json:"cameras,omitempty"`
+	Scales         []Scale                 `json:"scales,omitempty"`
+}
+
+// SettingValue is a setting as stored, a string. Numbers and booleans are
+// accepted too so YAML documents need not quote them.
+type SettingValue string
+
+func (v *SettingValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = SettingValue(s)
+		return nil
+	}
+	var scalar interface{}
+	if err := json.Unmarshal(data, &scalar); err != nil {
+		return err
+	}
+	switch scalar.(type) {
+	case float64, bool:
+		*v = SettingValue(bytes.TrimSpace(data))
+		return nil
+	}
+	return fmt.Errorf("setting value must be a string, number or boolean, got %s", data)
+}
+
+type ExcludedPlate struct {
+	Plate   string `json:"plate"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type CameraPreset struct {
+	Name         string                `json:"name"`
+	Format       string                `json:"format,omitempty"`
+	RunANPR      bool                  `json:"run_anpr,omitempty"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping,omitempty"`
+}
+
+type ScalePreset struct {
+	Name         string                `json:"name"`
+	Format       string                `json:"format,omitempty"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping,omitempty"`
+}
+
+type Gate struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	IsEntry              bool   `json:"is_entry,omitempty"`
+	IsExit               bool   `json:"is_exit,omitempty"`
+	DegradedAfterSeconds *int   `json:"degraded_after_seconds,omitempty"`
+	OfflineAfterSeconds  *int   `json:"offline_after_seconds,omitempty"`
+}
+
+type Flow struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Steps       []FlowStep `json:"steps"`
+}
+
+// FlowStep is a gate of a flow and its sequence number. Open permits store
+// the sequence they reached, so the export keeps the stored numbers. A step
+// may also be written as just the gate name; a step without a sequence
+// follows the one before it.
+type FlowStep struct {
+	Gate     string `json:"gate"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+func (s *FlowStep) UnmarshalJSON(data []byte) error {
+	var gate string
+	if err := json.Unmarshal(data, &gate); err == nil {
+		*s = FlowStep{Gate: gate}
+		return nil
+	}
+	type plain FlowStep
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(s))
+}
+
+// Camera refers to its preset and gate by name. Its source ID is not part of
+// the document: it belongs to the API key, which is issued when the camera
+// is created.
+type Camera struct {
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	Preset       string                `json:"preset,omitempty"`
+	Gate         string                `json:"gate,omitempty"`
+	Format       string                `json:"format,omitempty"`
+	RunANPR      *bool                 `json:"run_anpr,omitempty"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping,omitempty"`
+}
+
+type Scale struct {
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	Preset       string                `json:"preset,omitempty"`
+	Gate         string                `json:"gate,omitempty"`
+	Format       string                `json:"format,omitempty"`
+	FieldMapping payloadparser.Mapping `json:"field_mapping,omitempty"`
+}
+
+// Decode reads a YAML or JSON document; JSON is read as YAML. Unknown fields
+// are rejected so a misspelt key is not silently ignored.
+func Decode(data []byte) (Document, error) {
+	js, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return Document{}, fmt.Errorf("invalid document: %w", err)
+	}
+	var doc Document
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return Document{}, fmt.Errorf("invalid document: %w", err)
+	}
+	return doc, nil
+}
+
+// Encode writes doc as YAML or JSON and returns the content type to serve it
+// with.
+func Encode(doc Document, format string) ([]byte, string, error) {
+	js, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return js, "application/json", nil
+	case FormatYAML, "yml", "":
+		out, err := yaml.JSONToYAML(js)
+		if err != nil {
+			return nil, "", err
+		}
+		return out, "application/yaml", nil
+	}
+	return nil, "", fmt.Errorf("unknown format %q, expected %s or %s", format, FormatYAML, FormatJSON)
+}
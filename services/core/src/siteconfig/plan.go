@@ -0,0 +1,484 @@
+package siteconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/truckguard/core/src/logic"
+	"github.com/truckguard/core/src/models"
+	"github.com/truckguard/core/src/payloadparser"
+	"gorm.io/gorm"
+)
+
+// Kinds of object in a plan.
+const (
+	KindSetting       = "setting"
+	KindExcludedPlate = "excluded_plate"
+	KindCameraPreset  = "camera_preset"
+	KindScalePreset   = "scale_preset"
+	KindGate          = "gate"
+	KindFlow          = "flow"
+	KindCamera        = models.DeviceTypeCamera
+	KindScale         = models.DeviceTypeScale
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+)
+
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Change is what an import does to one object. Fields lists the fields an
+// update changes, as they appear in the document.
+type Change struct {
+	Kind   string                 `json:"kind"`
+	Key    string                 `json:"key"`
+	Action string                 `json:"action"`
+	Fields map[string]FieldChange `json:"fields,omitempty"`
+}
+
+// Plan is the diff between a document and the stored configuration.
+type Plan struct {
+	Changes []Change       `json:"changes"`
+	Summary map[string]int `json:"summary"`
+
+	index map[string]int
+}
+
+func (p *Plan) add(kind, key string, from, to interface{}) {
+	change := Change{Kind: kind, Key: key, Action: ActionCreate}
+	if from != nil {
+		change.Fields = diff(from, to)
+		change.Action = ActionUpdate
+		if len(change.Fields) == 0 {
+			change.Action = ActionUnchanged
+		}
+	}
+	p.index[ObjectKey(kind, key)] = len(p.Changes)
+	p.Changes = append(p.Changes, change)
+	p.Summary[change.Action]++
+}
+
+func (p *Plan) action(kind, key string) string {
+	if i, ok := p.index[ObjectKey(kind, key)]; ok {
+		return p.Changes[i].Action
+	}
+	return ""
+}
+
+// NewDevices lists the cameras and scales the import creates; each needs an
+// API key before the plan can be applied.
+func (p *Plan) NewDevices() []Change {
+	var out []Change
+	for _, c := range p.Changes {
+		if (c.Kind == KindCamera || c.Kind == KindScale) && c.Action == ActionCreate {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ObjectKey identifies an object of a plan, e.g. "camera:Entry ANPR".
+func ObjectKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// ValidationError lists every problem in a document, located like
+// "cameras[2].gate".
+type ValidationError struct {
+	Errors []payloadparser.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Location + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(location, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, payloadparser.FieldError{Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// addMapping adds the problems of a mapping under the entry's location.
+func (e *ValidationError) addMapping(location string, err error) {
+	if verr, ok := err.(*payloadparser.ValidationError); ok {
+		for _, fe := range verr.Errors {
+			e.add(location+"."+fe.Location, "%s", fe.Message)
+		}
+		return
+	}
+	e.add(location, "%v", err)
+}
+
+// NewPlan validates doc against the stored configuration and returns what
+// importing it would change. Invalid documents yield a *ValidationError.
+func NewPlan(db *gorm.DB, doc Document) (*Plan, error) {
+	s, err := loadSite(db)
+	if err != nil {
+		return nil, err
+	}
+	return s.plan(&doc)
+}
+
+// plan validates doc, normalizes it in place the way the config endpoints
+// store it, and diffs it against s.
+func (s *site) plan(doc *Document) (*Plan, error) {
+	verr := &ValidationError{}
+	if doc.Version != Version {
+		verr.add("version", "unsupported version %d, expected %d", doc.Version, Version)
+		return nil, verr
+	}
+	p := &Plan{Changes: []Change{}, Summary: map[string]int{}, index: map[string]int{}}
+
+	keys := make([]string, 0, len(doc.Settings))
+	for k := range doc.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.TrimSpace(k) == "" {
+			verr.add("settings", "setting key must not be empty")
+			continue
+		}
+		var from interface{}
+		if existing, ok := s.settingsByKey[k]; ok {
+			from = existing.Value
+		}
+		p.add(KindSetting, k, from, string(doc.Settings[k]))
+	}
+
+	seen := map[string]bool{}
+	for i := range doc.ExcludedPlates {
+		e := &doc.ExcludedPlates[i]
+		loc := fmt.Sprintf("excluded_plates[%d]", i)
+		e.Plate = strings.TrimSpace(e.Plate)
+		if !uniqueKey(verr, loc+".plate", e.Plate, seen) {
+			continue
+		}
+		var from interface{}
+		if existing, ok := s.excludedByPlate[e.Plate]; ok {
+			from = excludedPlateEntry(existing)
+		}
+		p.add(KindExcludedPlate, e.Plate, from, *e)
+	}
+
+	docCameraPresets := map[string]CameraPreset{}
+	seen = map[string]bool{}
+	for i := range doc.CameraPresets {
+		e := &doc.CameraPresets[i]
+		loc := fmt.Sprintf("camera_presets[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "camera presets", len(s.cameraPresetsByName[e.Name])) {
+			continue
+		}
+		if err := payloadparser.Validate(e.Format, e.FieldMapping, payloadparser.FieldPlate); err != nil {
+			verr.addMapping(loc, err)
+			continue
+		}
+		e.FieldMapping = e.FieldMapping.Normalize(e.Format)
+		docCameraPresets[e.Name] = *e
+		var from interface{}
+		if existing := s.cameraPresetsByName[e.Name]; len(existing) == 1 {
+			from = cameraPresetEntry(existing[0])
+		}
+		p.add(KindCameraPreset, e.Name, from, *e)
+	}
+
+	docScalePresets := map[string]ScalePreset{}
+	seen = map[string]bool{}
+	for i := range doc.ScalePresets {
+		e := &doc.ScalePresets[i]
+		loc := fmt.Sprintf("scale_presets[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "scale presets", len(s.scalePresetsByName[e.Name])) {
+			continue
+		}
+		if err := payloadparser.Validate(e.Format, e.FieldMapping, payloadparser.FieldWeight); err != nil {
+			verr.addMapping(loc, err)
+			continue
+		}
+		e.FieldMapping = e.FieldMapping.Normalize(e.Format)
+		docScalePresets[e.Name] = *e
+		var from interface{}
+		if existing := s.scalePresetsByName[e.Name]; len(existing) == 1 {
+			from = scalePresetEntry(existing[0])
+		}
+		p.add(KindScalePreset, e.Name, from, *e)
+	}
+
+	docGates := map[string]int{}
+	seen = map[string]bool{}
+	for i := range doc.Gates {
+		e := &doc.Gates[i]
+		loc := fmt.Sprintf("gates[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "gates", len(s.gatesByName[e.Name])) {
+			continue
+		}
+		if err := logic.ValidateGateThresholds(models.Gate{DegradedAfterSeconds: e.DegradedAfterSeconds, OfflineAfterSeconds: e.OfflineAfterSeconds}); err != nil {
+			verr.add(loc, "%v", err)
+			continue
+		}
+		docGates[e.Name] = i
+		var from interface{}
+		if existing := s.gatesByName[e.Name]; len(existing) == 1 {
+			from = gateEntry(existing[0])
+		}
+		p.add(KindGate, e.Name, from, *e)
+	}
+	gateRef := func(loc, name string) {
+		if _, ok := docGates[name]; name != "" && !ok {
+			refExists(verr, loc, "gate", name, len(s.gatesByName[name]))
+		}
+	}
+
+	docFlows := map[string]int{}
+	seen = map[string]bool{}
+	for i := range doc.Flows {
+		e := &doc.Flows[i]
+		loc := fmt.Sprintf("flows[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "flows", len(s.flowsByName[e.Name])) {
+			continue
+		}
+		if e.Steps == nil {
+			e.Steps = []FlowStep{}
+		}
+		valid := len(verr.Errors)
+		previous := 0
+		for j := range e.Steps {
+			step := &e.Steps[j]
+			if step.Sequence == 0 {
+				step.Sequence = previous + 1
+			}
+			previous = step.Sequence
+			if step.Gate == "" {
+				verr.add(fmt.Sprintf("%s.steps[%d].gate", loc, j), "gate name must not be empty")
+				continue
+			}
+			gateRef(fmt.Sprintf("%s.steps[%d].gate", loc, j), step.Gate)
+		}
+		if len(verr.Errors) == valid {
+			docFlows[e.Name] = i
+		}
+		var from interface{}
+		if existing := s.flowsByName[e.Name]; len(existing) == 1 {
+			from = s.flowEntry(existing[0])
+		}
+		p.add(KindFlow, e.Name, from, *e)
+	}
+	s.checkFlows(verr, doc, docGates, docFlows)
+
+	seen = map[string]bool{}
+	for i := range doc.Cameras {
+		e := &doc.Cameras[i]
+		loc := fmt.Sprintf("cameras[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "cameras", len(s.camerasByName[e.Name])) {
+			continue
+		}
+		gateRef(loc+".gate", e.Gate)
+
+		var preset *models.CameraPreset
+		if e.Preset != "" {
+			if dp, ok := docCameraPresets[e.Preset]; ok {
+				preset = &models.CameraPreset{Name: dp.Name, Format: dp.Format, RunANPR: dp.RunANPR, FieldMapping: dp.FieldMapping}
+			} else if refExists(verr, loc+".preset", "camera preset", e.Preset, len(s.cameraPresetsByName[e.Preset])) {
+				preset = &s.cameraPresetsByName[e.Preset][0]
+			} else {
+				continue
+			}
+		}
+		eff := logic.MergeCameraConfig(models.CameraConfig{Format: e.Format, RunANPR: e.RunANPR, FieldMapping: e.FieldMapping}, preset)
+		if !validDeviceMapping(verr, loc, eff.Format, e.FieldMapping, eff.FieldMapping, payloadparser.FieldPlate) {
+			continue
+		}
+		e.FieldMapping = e.FieldMapping.Normalize(eff.Format)
+
+		var from interface{}
+		if existing := s.camerasByName[e.Name]; len(existing) == 1 {
+			from = s.cameraEntry(existing[0])
+		}
+		p.add(KindCamera, e.Name, from, *e)
+	}
+
+	seen = map[string]bool{}
+	for i := range doc.Scales {
+		e := &doc.Scales[i]
+		loc := fmt.Sprintf("scales[%d]", i)
+		e.Name = strings.TrimSpace(e.Name)
+		if !uniqueKey(verr, loc+".name", e.Name, seen) || !unambiguous(verr, loc+".name", "scales", len(s.scalesByName[e.Name])) {
+			continue
+		}
+		gateRef(loc+".gate", e.Gate)
+
+		var preset *models.ScalePreset
+		if e.Preset != "" {
+			if dp, ok := docScalePresets[e.Preset]; ok {
+				preset = &models.ScalePreset{Name: dp.Name, Format: dp.Format, FieldMapping: dp.FieldMapping}
+			} else if refExists(verr, loc+".preset", "scale preset", e.Preset, len(s.scalePresetsByName[e.Preset])) {
+				preset = &s.scalePresetsByName[e.Preset][0]
+			} else {
+				continue
+			}
+		}
+		eff := logic.MergeScaleConfig(models.ScaleConfig{Format: e.Format, FieldMapping: e.FieldMapping}, preset)
+		if !validDeviceMapping(verr, loc, eff.Format, e.FieldMapping, eff.FieldMapping, payloadparser.FieldWeight) {
+			continue
+		}
+		e.FieldMapping = e.FieldMapping.Normalize(eff.Format)
+
+		var from interface{}
+		if existing := s.scalesByName[e.Name]; len(existing) == 1 {
+			from = s.scaleEntry(existing[0])
+		}
+		p.add(KindScale, e.Name, from, *e)
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return p, nil
+}
+
+// checkFlows checks the flows as they will be after the import, as the flow
+// endpoints do. docGates and docFlows hold the index of each valid gate and
+// flow entry by name. Stored flows the document leaves alone are only checked
+// against the gates it changes, so a problem the site already has does not
+// block imports.
+func (s *site) checkFlows(verr *ValidationError, doc *Document, docGates, docFlows map[string]int) {
+	gates := map[string]models.Gate{}
+	ids := map[string]uint{}
+	for name, existing := range s.gatesByName {
+		if len(existing) == 1 {
+			gates[name] = existing[0]
+		}
+	}
+	for name, i := range docGates {
+		e := doc.Gates[i]
+		gates[name] = models.Gate{Name: e.Name, IsEntry: e.IsEntry, IsExit: e.IsExit}
+	}
+	stepsOf := func(entries []FlowStep) []models.FlowStep {
+		steps := make([]models.FlowStep, len(entries))
+		for j, e := range entries {
+			if _, ok := ids[e.Gate]; !ok {
+				ids[e.Gate] = uint(len(ids) + 1)
+			}
+			g := gates[e.Gate]
+			steps[j] = models.FlowStep{GateID: ids[e.Gate], Sequence: e.Sequence, Gate: &g}
+		}
+		return steps
+	}
+
+	for _, f := range s.flows {
+		if _, ok := docFlows[f.Name]; ok || len(s.flowsByName[f.Name]) > 1 {
+			continue
+		}
+		entry := s.flowEntry(f)
+		for _, fe := range logic.CheckFlowSteps(stepsOf(entry.Steps)) {
+			var j int
+			if _, err := fmt.Sscanf(fe.Location, "steps[%d]", &j); err != nil {
+				continue
+			}
+			if i, ok := docGates[entry.Steps[j].Gate]; ok {
+				verr.add(fmt.Sprintf("gates[%d]", i), "flow %q: %s", f.Name, fe.Message)
+			}
+		}
+	}
+
+	for i, e := range doc.Flows {
+		if j, ok := docFlows[e.Name]; !ok || j != i {
+			continue
+		}
+		loc := fmt.Sprintf("flows[%d]", i)
+		for _, fe := range logic.CheckFlowSteps(stepsOf(e.Steps)) {
+			verr.add(loc+"."+strings.Replace(fe.Location, ".gate_id", ".gate", 1), "%s", fe.Message)
+		}
+	}
+}
+
+// validDeviceMapping checks the device's own mapping and the one merged with
+// its preset, as the config endpoints do.
+func validDeviceMapping(verr *ValidationError, loc, format string, own, merged payloadparser.Mapping, field string) bool {
+	err := payloadparser.Validate(format, own, field)
+	if err == nil {
+		err = payloadparser.Validate(format, merged, field)
+	}
+	if err != nil {
+		verr.addMapping(loc, err)
+		return false
+	}
+	return true
+}
+
+func uniqueKey(verr *ValidationError, loc, key string, seen map[string]bool) bool {
+	switch {
+	case key == "":
+		verr.add(loc, "must not be empty")
+		return false
+	case seen[key]:
+		verr.add(loc, "duplicate %q", key)
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// unambiguous rejects a name that several stored objects share, since the
+// import could not tell which one to update.
+func unambiguous(verr *ValidationError, loc, kind string, matches int) bool {
+	if matches > 1 {
+		verr.add(loc, "matches %d existing %s; rename them first", matches, kind)
+		return false
+	}
+	return true
+}
+
+func refExists(verr *ValidationError, loc, kind, name string, matches int) bool {
+	switch {
+	case matches == 0:
+		verr.add(loc, "unknown %s %q", kind, name)
+		return false
+	case matches > 1:
+		verr.add(loc, "%s %q is ambiguous, %d exist", kind, name, matches)
+		return false
+	}
+	return true
+}
+
+// diff compares two entries field by field as they appear in the document.
+func diff(from, to interface{}) map[string]FieldChange {
+	a, b := fieldsOf(from), fieldsOf(to)
+	changes := map[string]FieldChange{}
+	for k, v := range b {
+		if !reflect.DeepEqual(a[k], v) {
+			changes[k] = FieldChange{From: a[k], To: v}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = FieldChange{From: v, To: nil}
+		}
+	}
+	return changes
+}
+
+func fieldsOf(v interface{}) map[string]interface{} {
+	b, _ := json.Marshal(v)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		// A scalar, such as a setting value.
+		return map[string]interface{}{"value": v}
+	}
+	return fields
+}
@@ -0,0 +1,197 @@
+package siteconfig
+
+import (
+	"sort"
+	"time"
+
+	"github.com/truckguard/core/src/models"
+	"gorm.io/gorm"
+)
+
+// site is the configuration currently stored, indexed by natural key. Names
+// are not unique in the database, so each name maps to every object using it.
+type site struct {
+	settings       []models.SystemSetting
+	excludedPlates []models.ExcludedPlate
+	cameraPresets  []models.CameraPreset
+	scalePresets   []models.ScalePreset
+	gates          []models.Gate
+	flows          []models.Flow
+	cameras        []models.CameraConfig
+	scales         []models.ScaleConfig
+
+	settingsByKey       map[string]models.SystemSetting
+	excludedByPlate     map[string]models.ExcludedPlate
+	cameraPresetsByName map[string][]models.CameraPreset
+	scalePresetsByName  map[string][]models.ScalePreset
+	gatesByName         map[string][]models.Gate
+	flowsByName         map[string][]models.Flow
+	camerasByName       map[string][]models.CameraConfig
+	scalesByName        map[string][]models.ScaleConfig
+
+	gateNames         map[uint]string
+	cameraPresetNames map[uint]string
+	scalePresetNames  map[uint]string
+}
+
+func loadSite(db *gorm.DB) (*site, error) {
+	s := &site{}
+	loads := []struct {
+		dest  interface{}
+		order string
+	}{
+		{&s.settings, "key"},
+		{&s.excludedPlates, "plate"},
+		{&s.cameraPresets, "name, id"},
+		{&s.scalePresets, "name, id"},
+		{&s.gates, "name, id"},
+		{&s.cameras, "name, id"},
+		{&s.scales, "name, id"},
+	}
+	for _, l := range loads {
+		if err := db.Order(l.order).Find(l.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := db.Preload("Steps").Order("name, id").Find(&s.flows).Error; err != nil {
+		return nil, err
+	}
+
+	s.settingsByKey = map[string]models.SystemSetting{}
+	for _, v := range s.settings {
+		s.settingsByKey[v.Key] = v
+	}
+	s.excludedByPlate = map[string]models.ExcludedPlate{}
+	for _, v := range s.excludedPlates {
+		s.excludedByPlate[v.Plate] = v
+	}
+	s.cameraPresetsByName = map[string][]models.CameraPreset{}
+	s.cameraPresetNames = map[uint]string{}
+	for _, v := range s.cameraPresets {
+		s.cameraPresetsByName[v.Name] = append(s.cameraPresetsByName[v.Name], v)
+		s.cameraPresetNames[v.ID] = v.Name
+	}
+	s.scalePresetsByName = map[string][]models.ScalePreset{}
+	s.scalePresetNames = map[uint]string{}
+	for _, v := range s.scalePresets {
+		s.scalePresetsByName[v.Name] = append(s.scalePresetsByName[v.Name], v)
+		s.scalePresetNames[v.ID] = v.Name
+	}
+	s.gatesByName = map[string][]models.Gate{}
+	s.gateNames = map[uint]string{}
+	for _, v := range s.gates {
+		s.gatesByName[v.Name] = append(s.gatesByName[v.Name], v)
+		s.gateNames[v.ID] = v.Name
+	}
+	s.flowsByName = map[string][]models.Flow{}
+	for _, v := range s.flows {
+		s.flowsByName[v.Name] = append(s.flowsByName[v.Name], v)
+	}
+	s.camerasByName = map[string][]models.CameraConfig{}
+	for _, v := range s.cameras {
+		s.camerasByName[v.Name] = append(s.camerasByName[v.Name], v)
+	}
+	s.scalesByName = map[string][]models.ScaleConfig{}
+	for _, v := range s.scales {
+		s.scalesByName[v.Name] = append(s.scalesByName[v.Name], v)
+	}
+	return s, nil
+}
+
+// Export returns the stored configuration as a document.
+func Export(db *gorm.DB) (Document, error) {
+	s, err := loadSite(db)
+	if err != nil {
+		return Document{}, err
+	}
+	now := time.Now()
+	doc := Document{Version: Version, ExportedAt: &now, Settings: map[string]SettingValue{}}
+	for _, v := range s.settings {
+		doc.Settings[v.Key] = SettingValue(v.Value)
+	}
+	for _, v := range s.excludedPlates {
+		doc.ExcludedPlates = append(doc.ExcludedPlates, excludedPlateEntry(v))
+	}
+	for _, v := range s.cameraPresets {
+		doc.CameraPresets = append(doc.CameraPresets, cameraPresetEntry(v))
+	}
+	for _, v := range s.scalePresets {
+		doc.ScalePresets = append(doc.ScalePresets, scalePresetEntry(v))
+	}
+	for _, v := range s.gates {
+		doc.Gates = append(doc.Gates, gateEntry(v))
+	}
+	for _, v := range s.flows {
+		doc.Flows = append(doc.Flows, s.flowEntry(v))
+	}
+	for _, v := range s.cameras {
+		doc.Cameras = append(doc.Cameras, s.cameraEntry(v))
+	}
+	for _, v := range s.scales {
+		doc.Scales = append(doc.Scales, s.scaleEntry(v))
+	}
+	return doc, nil
+}
+
+func excludedPlateEntry(p models.ExcludedPlate) ExcludedPlate {
+	return ExcludedPlate{Plate: p.Plate, Comment: p.Comment}
+}
+
+func cameraPresetEntry(p models.CameraPreset) CameraPreset {
+	return CameraPreset{Name: p.Name, Format: p.Format, RunANPR: p.RunANPR, FieldMapping: p.FieldMapping}
+}
+
+func scalePresetEntry(p models.ScalePreset) ScalePreset {
+	return ScalePreset{Name: p.Name, Format: p.Format, FieldMapping: p.FieldMapping}
+}
+
+func gateEntry(g models.Gate) Gate {
+	return Gate{
+		Name:                 g.Name,
+		Description:          g.Description,
+		IsEntry:              g.IsEntry,
+		IsExit:               g.IsExit,
+		DegradedAfterSeconds: g.DegradedAfterSeconds,
+		OfflineAfterSeconds:  g.OfflineAfterSeconds,
+	}
+}
+
+func (s *site) flowEntry(f models.Flow) Flow {
+	steps := append([]models.FlowStep(nil), f.Steps...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Sequence < steps[j].Sequence })
+	entry := Flow{Name: f.Name, Description: f.Description, Steps: []FlowStep{}}
+	for _, step := range steps {
+		entry.Steps = append(entry.Steps, FlowStep{Gate: s.gateNames[step.GateID], Sequence: step.Sequence})
+	}
+	return entry
+}
+
+func (s *site) cameraEntry(c models.CameraConfig) Camera {
+	return Camera{
+		Name:         c.Name,
+		Description:  c.Description,
+		Preset:       nameOf(s.cameraPresetNames, c.PresetID),
+		Gate:         nameOf(s.gateNames, c.GateID),
+		Format:       c.Format,
+		RunANPR:      c.RunANPR,
+		FieldMapping: c.FieldMapping,
+	}
+}
+
+func (s *site) scaleEntry(c models.ScaleConfig) Scale {
+	return Scale{
+		Name:         c.Name,
+		Description:  c.Description,
+		Preset:       nameOf(s.scalePresetNames, c.PresetID),
+		Gate:         nameOf(s.gateNames, c.GateID),
+		Format:       c.Format,
+		FieldMapping: c.FieldMapping,
+	}
+}
+
+func nameOf(names map[uint]string, id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return names[*id]
+}
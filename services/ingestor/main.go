@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/truckguard/ingestor/src/api/handlers"
 	"github.com/truckguard/ingestor/src/api/middleware"
+	"github.com/truckguard/ingestor/src/indicators"
+	"github.com/truckguard/ingestor/src/mqtt"
+	"github.com/truckguard/ingestor/src/protocols"
 	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
 )
 
 func main() {
@@ -17,11 +25,42 @@ func main() {
 	secretKey := os.Getenv("MINIO_SECRET_KEY")
 	repository.InitMinio(endpoint, accessKey, secretKey)
 
+	go streams.RunRetention(context.Background(), repository.RDB, repository.Retention, time.Minute)
+
+	if opts, ok := protocols.MQTTOptionsFromEnv(); ok {
+		go mqtt.Run(context.Background(), opts, protocols.HandleMQTT)
+	}
+
+	weighbridges, err := indicators.LoadConfigs()
+	if err != nil {
+		log.Fatalf("Failed to load weighbridge listeners: %v", err)
+	}
+	for _, cfg := range weighbridges {
+		go indicators.Run(context.Background(), cfg)
+	}
+
 	r := gin.Default()
-	ingestLines := r.Group("/ingest", middleware.RequirePermission("create:ingest"))
+	ingestLines := r.Group("/ingest",
+		middleware.RequirePermission("create:ingest"),
+		middleware.RateLimitBySource(middleware.SourceLimitsFromEnv()),
+		middleware.LimitConcurrency(envInt("INGEST_MAX_CONCURRENT", 64), envDuration("INGEST_QUEUE_WAIT", 2*time.Second)),
+	)
 	{
 		ingestLines.POST("/camera", handlers.HandleCameraIngest)
 		ingestLines.POST("/weight", handlers.HandleWeightIngest)
+		ingestLines.POST("/hikvision", handlers.HandleHikvisionIngest)
+		ingestLines.POST("/dahua", handlers.HandleDahuaIngest)
+		ingestLines.POST("/batch", handlers.HandleBatchIngest)
+	}
+
+	// Heartbeats skip the per-source rate limit and the concurrency cap so
+	// an idle device is never reported offline because it was throttled.
+	r.POST("/ingest/heartbeat", middleware.RequirePermission("create:ingest"), handlers.HandleHeartbeat)
+
+	admin := r.Group("/ingest/admin", middleware.RequirePermission("read:streams"))
+	{
+		admin.GET("/streams", handlers.HandleStreamStats)
+		admin.GET("/metrics", handlers.HandleIngestMetrics)
 	}
 
 	r.GET("/health", func(c *gin.Context) {
@@ -34,3 +73,17 @@ func main() {
 	}
 	r.Run(":" + port)
 }
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/truckguard/ingestor/src/models"
+	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
+)
+
+// Per-item outcomes of a batch. Devices drop accepted, duplicate and rejected
+// items from their buffer and retry failed ones.
+const (
+	BatchAccepted  = "accepted"
+	BatchDuplicate = "duplicate"
+	BatchRejected  = "rejected"
+	BatchFailed    = "failed"
+)
+
+var (
+	batchMaxEvents = envInt("BATCH_MAX_EVENTS", 500)
+	batchMaxBytes  = int64(envInt("BATCH_MAX_BYTES", 64<<20))
+)
+
+type batchAttachment struct {
+	Role string `json:"role"`
+	// Data is the file, base64 encoded.
+	Data string `json:"data"`
+}
+
+type batchEvent struct {
+	DeviceEventID string            `json:"device_event_id"`
+	Type          string            `json:"type"`
+	DeviceID      string            `json:"device_id"`
+	CapturedAt    *time.Time        `json:"captured_at"`
+	Payload       string            `json:"payload"`
+	Attachments   []batchAttachment `json:"attachments"`
+}
+
+type batchRequest struct {
+	Events []batchEvent `json:"events"`
+}
+
+type batchResult struct {
+	Index         int    `json:"index"`
+	DeviceEventID string `json:"device_event_id"`
+	Status        string `json:"status"`
+	EventID       string `json:"event_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// HandleBatchIngest accepts events an edge device buffered while offline. Each
+// item carries its own device event ID and capture time; items the ingestor
+// has already seen from the same source are reported as duplicates.
+func HandleBatchIngest(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, batchMaxBytes)
+
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "batch exceeds " + strconv.FormatInt(batchMaxBytes, 10) + " bytes"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events must not be empty"})
+		return
+	}
+	if len(req.Events) > batchMaxEvents {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "batch exceeds " + strconv.Itoa(batchMaxEvents) + " events"})
+		return
+	}
+
+	sourceID := c.GetHeader("X-Source-ID")
+	sourceName := c.GetHeader("X-Source-Name")
+
+	counts := map[string]int{}
+	results := make([]batchResult, len(req.Events))
+	for i := range req.Events {
+		results[i] = ingestBatchEvent(&req.Events[i], sourceID, sourceName)
+		results[i].Index = i
+		counts[results[i].Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":    results,
+		"accepted":   counts[BatchAccepted],
+		"duplicates": counts[BatchDuplicate],
+		"rejected":   counts[BatchRejected],
+		"failed":     counts[BatchFailed],
+	})
+}
+
+func ingestBatchEvent(item *batchEvent, sourceID, sourceName string) batchResult {
+	result := batchResult{DeviceEventID: item.DeviceEventID}
+	reject := func(msg string) batchResult {
+		result.Status = BatchRejected
+		result.Error = msg
+		return result
+	}
+
+	if item.DeviceEventID == "" {
+		return reject("device_event_id is required")
+	}
+
+	var stream string
+	switch item.Type {
+	case "camera":
+		stream = streams.CameraRaw
+	case "weight":
+		stream = streams.WeightRaw
+	default:
+		return reject("type must be camera or weight")
+	}
+
+	var uploads []repository.Upload
+	seen := map[string]bool{}
+	for _, a := range item.Attachments {
+		if !isAttachmentRole(a.Role) {
+			return reject("unknown attachment role " + strconv.Quote(a.Role))
+		}
+		if seen[a.Role] {
+			return reject("only one attachment allowed for " + a.Role)
+		}
+		seen[a.Role] = true
+		data, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return reject("attachment " + a.Role + " is not valid base64")
+		}
+		uploads = append(uploads, repository.BytesUpload(a.Role, data))
+	}
+	if item.Type == "camera" && len(uploads) == 0 {
+		return reject("camera events need at least one attachment")
+	}
+
+	event := models.IngestEvent{
+		EventID:       uuid.New().String(),
+		Type:          item.Type,
+		SourceID:      sourceID,
+		SourceName:    sourceName,
+		DeviceID:      item.DeviceID,
+		Payload:       item.Payload,
+		DeviceEventID: item.DeviceEventID,
+		CapturedAt:    item.CapturedAt,
+	}
+
+	reserved, existing, err := repository.ReserveDeviceEvent(sourceID, item.DeviceEventID, event.EventID)
+	if err != nil {
+		result.Status = BatchFailed
+		result.Error = "deduplication unavailable"
+		return result
+	}
+	if !reserved {
+		result.Status = BatchDuplicate
+		result.EventID = existing
+		return result
+	}
+
+	if err := repository.PublishEvent(uploads, &event, stream); err != nil {
+		repository.ReleaseDeviceEvent(sourceID, item.DeviceEventID)
+		if ingestErrorStatus(err) < http.StatusInternalServerError {
+			return reject(err.Error())
+		}
+		result.Status = BatchFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = BatchAccepted
+	result.EventID = event.EventID
+	return result
+}
+
+func isAttachmentRole(role string) bool {
+	for _, r := range models.AttachmentRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/truckguard/ingestor/src/images"
+	"github.com/truckguard/ingestor/src/metrics"
+	"github.com/truckguard/ingestor/src/models"
 	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
 )
 
 type CameraMetadata struct {
@@ -13,21 +18,42 @@ type CameraMetadata struct {
 }
 
 func HandleCameraIngest(c *gin.Context) {
-	file, err := c.FormFile("image")
+	form, err := c.MultipartForm()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "image required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart form required"})
+		return
+	}
+
+	var uploads []repository.Upload
+	for _, role := range models.AttachmentRoles {
+		files := form.File[role]
+		if len(files) > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only one file allowed for " + role})
+			return
+		}
+		if len(files) == 1 {
+			uploads = append(uploads, repository.FileUpload(role, files[0]))
+		}
+	}
+	if len(uploads) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one of image, plate, overview or clip is required"})
 		return
 	}
 
 	deviceID := c.PostForm("device_id")
 	payload := c.PostForm("payload")
+	capturedAt, err := models.ParseCapturedAt(c.PostForm("captured_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	sourceID := c.GetHeader("X-Source-ID")
 	sourceName := c.GetHeader("X-Source-Name")
 
-	event, err := repository.ProcessIncomingEvent(file, deviceID, payload, sourceID, sourceName, "camera", "camera:raw")
+	event, err := repository.ProcessIncomingEvent(uploads, deviceID, payload, sourceID, sourceName, "camera", streams.CameraRaw, capturedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondIngestError(c, err)
 		return
 	}
 
@@ -37,15 +63,45 @@ func HandleCameraIngest(c *gin.Context) {
 func HandleWeightIngest(c *gin.Context) {
 	deviceID := c.PostForm("device_id")
 	payload := c.PostForm("payload")
+	capturedAt, err := models.ParseCapturedAt(c.PostForm("captured_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	sourceID := c.GetHeader("X-Source-ID")
 	sourceName := c.GetHeader("X-Source-Name")
 
-	event, err := repository.ProcessIncomingEvent(nil, deviceID, payload, sourceID, sourceName, "weight", "weight:raw")
+	event, err := repository.ProcessIncomingEvent(nil, deviceID, payload, sourceID, sourceName, "weight", streams.WeightRaw, capturedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondIngestError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusAccepted, event)
 }
+
+// ingestErrorStatus maps upload validation failures to client errors and
+// storage trouble to 503 so devices back off and retry.
+func ingestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, images.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, images.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, images.ErrCorrupt), errors.Is(err, images.ErrDimensions):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrBackendUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func respondIngestError(c *gin.Context, err error) {
+	status := ingestErrorStatus(err)
+	if status == http.StatusServiceUnavailable {
+		metrics.DroppedFor(c.GetHeader("X-Source-ID"))
+		c.Header("Retry-After", "5")
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/ingestor/src/repository"
+)
+
+// HandleHeartbeat lets a device report that it is alive while it has nothing
+// to send. Accepted events count as well, so busy devices need not call it.
+func HandleHeartbeat(c *gin.Context) {
+	sourceID := c.GetHeader("X-Source-ID")
+	if sourceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Source-ID is required"})
+		return
+	}
+	if err := repository.RecordHeartbeat(sourceID); err != nil {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "at": time.Now()})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/ingestor/src/metrics"
+	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
+)
+
+// HandleStreamStats reports length, pending entries and consumer lag for the
+// ingest streams and their dead-letter queues.
+func HandleStreamStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var stats []streams.StreamStats
+	for _, stream := range streams.Managed {
+		s, err := streams.Stats(ctx, repository.RDB, stream)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stream " + stream + ": " + err.Error()})
+			return
+		}
+		stats = append(stats, s)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"streams": stats,
+		"retention": gin.H{
+			"max_len":         repository.Retention.MaxLen,
+			"max_age_seconds": int64(repository.Retention.MaxAge.Seconds()),
+		},
+	})
+}
+
+// HandleIngestMetrics reports this replica's accepted, throttled and dropped
+// request counters.
+func HandleIngestMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, metrics.Read())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/ingestor/src/protocols"
+	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
+)
+
+// maxVendorBody bounds non-multipart vendor pushes. Dahua embeds its pictures
+// base64 encoded in the JSON, so this has to fit several images.
+const maxVendorBody = 32 << 20
+
+// HandleHikvisionIngest accepts ISAPI alarm pushes ("HTTP listening") from
+// Hikvision ANPR cameras, either as bare XML or multipart with pictures.
+func HandleHikvisionIngest(c *gin.Context) {
+	var (
+		event *protocols.VendorEvent
+		err   error
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, ferr := c.MultipartForm()
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart body"})
+			return
+		}
+		event, err = protocols.ParseHikvision(nil, form)
+	} else {
+		body, rerr := readVendorBody(c)
+		if rerr != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": rerr.Error()})
+			return
+		}
+		event, err = protocols.ParseHikvision(body, nil)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ingestVendorEvent(c, event)
+}
+
+// HandleDahuaIngest accepts Dahua ITC JSON uploads.
+func HandleDahuaIngest(c *gin.Context) {
+	body, err := readVendorBody(c)
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	event, err := protocols.ParseDahua(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ingestVendorEvent(c, event)
+}
+
+func readVendorBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxVendorBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxVendorBody {
+		return nil, errors.New("request body too large")
+	}
+	return body, nil
+}
+
+// ingestVendorEvent answers 200 rather than 202: several camera firmwares
+// treat anything else as a failed push and retry it.
+func ingestVendorEvent(c *gin.Context, event *protocols.VendorEvent) {
+	if event.Ignore {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	sourceID := c.GetHeader("X-Source-ID")
+	sourceName := c.GetHeader("X-Source-Name")
+
+	ingested, err := repository.ProcessIncomingEvent(event.Uploads, event.DeviceID, event.Payload, sourceID, sourceName, "camera", streams.CameraRaw, event.CapturedAt)
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ingested)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/truckguard/ingestor/src/metrics"
+	"github.com/truckguard/ingestor/src/repository"
+)
+
+// SourceLimits caps requests per X-Source-ID. Counting happens in Redis so
+// the limit holds across ingestor replicas.
+type SourceLimits struct {
+	// Rate is the sustained requests per second; 0 disables limiting.
+	Rate float64
+	// Window is the counting window. Longer windows allow bigger bursts at
+	// the same rate.
+	Window time.Duration
+	// Overrides sets a different rate for particular sources; 0 exempts a
+	// source.
+	Overrides map[string]float64
+}
+
+func SourceLimitsFromEnv() SourceLimits {
+	l := SourceLimits{Rate: 10, Window: 10 * time.Second}
+	if v, err := strconv.ParseFloat(os.Getenv("INGEST_RATE_LIMIT"), 64); err == nil && v >= 0 {
+		l.Rate = v
+	}
+	if d, err := time.ParseDuration(os.Getenv("INGEST_RATE_WINDOW")); err == nil && d >= time.Second {
+		l.Window = d
+	}
+	if raw := os.Getenv("INGEST_SOURCE_LIMITS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &l.Overrides); err != nil {
+			log.Printf("Ignoring invalid INGEST_SOURCE_LIMITS: %v", err)
+		}
+	}
+	return l
+}
+
+// limitFor returns the requests allowed per window, or 0 when the source is
+// not limited.
+func (l SourceLimits) limitFor(sourceID string) int64 {
+	rate := l.Rate
+	if r, ok := l.Overrides[sourceID]; ok {
+		rate = r
+	}
+	if rate <= 0 {
+		return 0
+	}
+	if n := int64(rate * l.Window.Seconds()); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// RateLimitBySource answers 429 with Retry-After once a source has used its
+// allowance for the current window. If Redis cannot be reached the request
+// is shed with 503, since it could not be stored anyway.
+func RateLimitBySource(l SourceLimits) gin.HandlerFunc {
+	windowSecs := int64(l.Window.Seconds())
+
+	return func(c *gin.Context) {
+		sourceID := c.GetHeader("X-Source-ID")
+		limit := l.limitFor(sourceID)
+		if limit == 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now().Unix()
+		window := now / windowSecs
+		key := "ingest:rate:" + sourceID + ":" + strconv.FormatInt(window, 10)
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second)
+		defer cancel()
+		pipe := repository.RDB.TxPipeline()
+		incr := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, l.Window+time.Second)
+		if _, err := pipe.Exec(ctx); err != nil {
+			metrics.DroppedFor(sourceID)
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Ingestion temporarily unavailable"})
+			return
+		}
+
+		if incr.Val() > limit {
+			metrics.ThrottledFor(sourceID)
+			c.Header("Retry-After", strconv.FormatInt((window+1)*windowSecs-now, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded for source " + sourceID})
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitConcurrency caps requests processed at once by this replica. A
+// request waits up to wait for a slot before it is shed with 503.
+func LimitConcurrency(max int, wait time.Duration) gin.HandlerFunc {
+	slots := make(chan struct{}, max)
+
+	return func(c *gin.Context) {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			metrics.DroppedFor(c.GetHeader("X-Source-ID"))
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Ingestor is at capacity"})
+			return
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+
+		metrics.InFlight.Add(1)
+		defer func() {
+			metrics.InFlight.Add(-1)
+			<-slots
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package images
+
+import (
+	"io"
+	"net/http"
+)
+
+var (
+	MP4  = Format{ContentType: "video/mp4", Extension: "mp4"}
+	WebM = Format{ContentType: "video/webm", Extension: "webm"}
+)
+
+// ReadClip loads a short video attachment. Only the container type and size
+// are checked; clips are evidence, not recognition input.
+func ReadClip(r io.Reader, maxBytes int64) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	clip := &Image{Data: data}
+	switch http.DetectContentType(data) {
+	case MP4.ContentType:
+		clip.Format = MP4
+	case WebM.ContentType:
+		clip.Format = WebM
+	default:
+		return nil, ErrUnsupportedType
+	}
+	return clip, nil
+}
@@ -0,0 +1,181 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported file type: images must be JPEG, PNG or WebP and clips MP4 or WebM")
+	ErrTooLarge        = errors.New("image exceeds the maximum upload size")
+	ErrCorrupt         = errors.New("image is truncated or corrupt")
+	ErrDimensions      = errors.New("image dimensions are out of range")
+)
+
+// Format describes a stored image type.
+type Format struct {
+	ContentType string
+	Extension   string
+}
+
+var (
+	JPEG = Format{ContentType: "image/jpeg", Extension: "jpg"}
+	PNG  = Format{ContentType: "image/png", Extension: "png"}
+	WebP = Format{ContentType: "image/webp", Extension: "webp"}
+)
+
+// Limits bounds what an upload may look like. Read from IMAGE_MAX_BYTES,
+// IMAGE_MIN_WIDTH, IMAGE_MIN_HEIGHT, IMAGE_MAX_WIDTH, IMAGE_MAX_HEIGHT and
+// CLIP_MAX_BYTES.
+type Limits struct {
+	MaxBytes     int64
+	ClipMaxBytes int64
+	MinWidth     int
+	MinHeight    int
+	MaxWidth     int
+	MaxHeight    int
+}
+
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxBytes:     envInt("IMAGE_MAX_BYTES", 10<<20),
+		ClipMaxBytes: envInt("CLIP_MAX_BYTES", 50<<20),
+		MinWidth:     int(envInt("IMAGE_MIN_WIDTH", 64)),
+		MinHeight:    int(envInt("IMAGE_MIN_HEIGHT", 64)),
+		MaxWidth:     int(envInt("IMAGE_MAX_WIDTH", 8192)),
+		MaxHeight:    int(envInt("IMAGE_MAX_HEIGHT", 8192)),
+	}
+}
+
+func envInt(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// Image is a validated upload held in memory.
+type Image struct {
+	Data   []byte
+	Format Format
+	Width  int
+	Height int
+}
+
+// Read loads an upload and checks it against the limits. The type comes from
+// the content itself, never from the file name or the client's Content-Type.
+func Read(r io.Reader, limits Limits) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	img := &Image{Data: data}
+	switch http.DetectContentType(data) {
+	case JPEG.ContentType:
+		img.Format = JPEG
+		if !jpegComplete(data) {
+			return nil, ErrCorrupt
+		}
+		err = img.decodeConfig()
+	case PNG.ContentType:
+		img.Format = PNG
+		if !pngComplete(data) {
+			return nil, ErrCorrupt
+		}
+		err = img.decodeConfig()
+	case WebP.ContentType:
+		img.Format = WebP
+		img.Width, img.Height, err = webpSize(data)
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if img.Width < limits.MinWidth || img.Height < limits.MinHeight ||
+		img.Width > limits.MaxWidth || img.Height > limits.MaxHeight {
+		return nil, fmt.Errorf("%w: %dx%d (allowed %dx%d to %dx%d)", ErrDimensions,
+			img.Width, img.Height, limits.MinWidth, limits.MinHeight, limits.MaxWidth, limits.MaxHeight)
+	}
+	return img, nil
+}
+
+func (img *Image) decodeConfig() error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return ErrCorrupt
+	}
+	img.Width, img.Height = cfg.Width, cfg.Height
+	return nil
+}
+
+// jpegComplete looks for the EOI marker, allowing the padding some cameras
+// append after it.
+func jpegComplete(data []byte) bool {
+	tail := data
+	if len(tail) > 64 {
+		tail = tail[len(tail)-64:]
+	}
+	return bytes.Contains(tail, []byte{0xFF, 0xD9})
+}
+
+// pngComplete checks that the file ends with the IEND chunk.
+func pngComplete(data []byte) bool {
+	iend := []byte{0, 0, 0, 0, 'I', 'E', 'N', 'D', 0xAE, 0x42, 0x60, 0x82}
+	return bytes.HasSuffix(data, iend)
+}
+
+// webpSize reads the canvas size from the first chunk of a RIFF/WEBP file
+// (lossy VP8, lossless VP8L or extended VP8X).
+func webpSize(data []byte) (int, int, error) {
+	if len(data) < 30 {
+		return 0, 0, ErrCorrupt
+	}
+	riffSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	if riffSize+8 > len(data) {
+		return 0, 0, ErrCorrupt
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ":
+		if chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, ErrCorrupt
+		}
+		w := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		return w, h, nil
+	case "VP8L":
+		if chunk[0] != 0x2f {
+			return 0, 0, ErrCorrupt
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		w := int(uint32(chunk[4]) | uint32(chunk[5])<<8 | uint32(chunk[6])<<16)
+		h := int(uint32(chunk[7]) | uint32(chunk[8])<<8 | uint32(chunk[9])<<16)
+		return w + 1, h + 1, nil
+	}
+	return 0, 0, ErrCorrupt
+}
@@ -0,0 +1,146 @@
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoWeight   = errors.New("frame has no weight field")
+	ErrShortFrame = errors.New("frame shorter than configured field positions")
+)
+
+// FrameFormat describes one indicator's continuous output. Offsets count from
+// the first byte after Start; a negative offset disables the field.
+//
+// Mettler Toledo continuous output, for example, is
+// STX SWA SWB SWC <6 weight digits> <6 tare digits> CR with the motion flag in
+// bit 3 of SWB: {"start":"\u0002","end":"\r","weight_offset":3,
+// "weight_length":6,"status_offset":1,"motion_mask":8}.
+type FrameFormat struct {
+	// Start is optional; bytes before it are discarded.
+	Start string `json:"start"`
+	// End terminates a frame. Defaults to "\r\n".
+	End string `json:"end"`
+
+	// WeightOffset and WeightLength locate the weight. A zero length takes
+	// the rest of the frame; the field may contain a sign, spaces and a unit.
+	WeightOffset int `json:"weight_offset"`
+	WeightLength int `json:"weight_length"`
+	// Decimals shifts an implied decimal point, for indicators that send
+	// 012345 meaning 1234.5.
+	Decimals int `json:"decimals"`
+
+	// StatusOffset locates the stable/motion flag. Motion is detected by
+	// MotionChars (e.g. "M"), by StableChars (anything else is motion) or by
+	// MotionMask against the status byte. With none set every frame is stable.
+	StatusOffset *int   `json:"status_offset"`
+	MotionChars  string `json:"motion_chars"`
+	StableChars  string `json:"stable_chars"`
+	MotionMask   byte   `json:"motion_mask"`
+
+	// Unit is reported with each reading. Defaults to "kg".
+	Unit string `json:"unit"`
+	// MaxLength drops frames that never see End. Defaults to 256 bytes.
+	MaxLength int `json:"max_length"`
+}
+
+type Reading struct {
+	Weight float64
+	Stable bool
+	Raw    string
+}
+
+func (f *FrameFormat) setDefaults() {
+	if f.End == "" {
+		f.End = "\r\n"
+	}
+	if f.Unit == "" {
+		f.Unit = "kg"
+	}
+	if f.MaxLength <= 0 {
+		f.MaxLength = 256
+	}
+}
+
+func (f *FrameFormat) validate() error {
+	if f.WeightOffset < 0 || f.WeightLength < 0 {
+		return errors.New("weight_offset and weight_length must not be negative")
+	}
+	if f.Decimals < 0 || f.Decimals > 6 {
+		return errors.New("decimals must be between 0 and 6")
+	}
+	if f.StatusOffset != nil && *f.StatusOffset < 0 {
+		return errors.New("status_offset must not be negative")
+	}
+	if f.StatusOffset == nil && (f.MotionChars != "" || f.StableChars != "" || f.MotionMask != 0) {
+		return errors.New("motion_chars, stable_chars and motion_mask require status_offset")
+	}
+	return nil
+}
+
+// Parse reads one frame with Start and End already stripped.
+func (f *FrameFormat) Parse(frame []byte) (Reading, error) {
+	r := Reading{Raw: string(frame), Stable: true}
+
+	if f.WeightOffset > len(frame) {
+		return r, ErrShortFrame
+	}
+	end := len(frame)
+	if f.WeightLength > 0 {
+		end = f.WeightOffset + f.WeightLength
+		if end > len(frame) {
+			return r, ErrShortFrame
+		}
+	}
+	weight, err := parseWeight(string(frame[f.WeightOffset:end]))
+	if err != nil {
+		return r, err
+	}
+	if f.Decimals > 0 {
+		weight /= math.Pow10(f.Decimals)
+	}
+	r.Weight = weight
+
+	if f.StatusOffset != nil {
+		if *f.StatusOffset >= len(frame) {
+			return r, ErrShortFrame
+		}
+		status := frame[*f.StatusOffset]
+		switch {
+		case f.MotionMask != 0:
+			r.Stable = status&f.MotionMask == 0
+		case f.StableChars != "":
+			r.Stable = strings.IndexByte(f.StableChars, status) >= 0
+		case f.MotionChars != "":
+			r.Stable = strings.IndexByte(f.MotionChars, status) < 0
+		}
+	}
+	return r, nil
+}
+
+// parseWeight keeps the sign, digits and decimal point of a field such as
+// "-  1234.5kg" and drops everything else.
+func parseWeight(field string) (float64, error) {
+	var b strings.Builder
+	for _, ch := range field {
+		switch {
+		case ch >= '0' && ch <= '9', ch == '.':
+			b.WriteRune(ch)
+		case ch == '-' && b.Len() == 0:
+			b.WriteRune(ch)
+		}
+	}
+	s := b.String()
+	if s == "" || s == "-" {
+		return 0, ErrNoWeight
+	}
+	w, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid weight %q: %w", strings.TrimSpace(field), err)
+	}
+	return w, nil
+}
@@ -0,0 +1,85 @@
+package indicators
+
+import (
+	"errors"
+	"testing"
+)
+
+func intPtr(i int) *int { return &i }
+
+func TestFrameFormatParse(t *testing.T) {
+	mettler := FrameFormat{WeightOffset: 3, WeightLength: 6, StatusOffset: intPtr(1), MotionMask: 8}
+
+	cases := []struct {
+		name   string
+		format FrameFormat
+		frame  string
+		weight float64
+		stable bool
+		err    error
+	}{
+		{"mettler stable", mettler, "4 \x20012340000000", 12340, true, nil},
+		{"mettler motion", mettler, "4(\x20012340000000", 12340, false, nil},
+		{"implied decimals", FrameFormat{WeightOffset: 0, WeightLength: 6, Decimals: 1}, "012345", 1234.5, true, nil},
+		{"rest of frame", FrameFormat{WeightOffset: 3}, "ST,+  1234.5kg", 1234.5, true, nil},
+		{"negative", FrameFormat{}, "-  120 kg", -120, true, nil},
+		{"motion chars", FrameFormat{WeightOffset: 2, StatusOffset: intPtr(0), MotionChars: "M"}, "M 15000", 15000, false, nil},
+		{"motion chars stable", FrameFormat{WeightOffset: 2, StatusOffset: intPtr(0), MotionChars: "M"}, "S 15000", 15000, true, nil},
+		{"stable chars", FrameFormat{WeightOffset: 2, StatusOffset: intPtr(0), StableChars: "S"}, "U 15000", 15000, false, nil},
+		{"stable chars stable", FrameFormat{WeightOffset: 2, StatusOffset: intPtr(0), StableChars: "S"}, "S 15000", 15000, true, nil},
+		{"weight past end", FrameFormat{WeightOffset: 10}, "12345", 0, false, ErrShortFrame},
+		{"weight field cut", FrameFormat{WeightOffset: 2, WeightLength: 6}, "  1234", 0, false, ErrShortFrame},
+		{"status past end", FrameFormat{StatusOffset: intPtr(9)}, "1234", 1234, true, ErrShortFrame},
+		{"no digits", FrameFormat{}, "OVERLOAD", 0, true, ErrNoWeight},
+		{"only sign", FrameFormat{}, " - ", 0, true, ErrNoWeight},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.format.Parse([]byte(tc.frame))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if r.Raw != tc.frame {
+				t.Errorf("raw = %q", r.Raw)
+			}
+			if err != nil {
+				return
+			}
+			if r.Weight != tc.weight || r.Stable != tc.stable {
+				t.Errorf("got %v stable=%v, want %v stable=%v", r.Weight, r.Stable, tc.weight, tc.stable)
+			}
+		})
+	}
+}
+
+func TestParseWeightRejectsMalformedNumber(t *testing.T) {
+	if _, err := parseWeight("12.3.4"); err == nil || errors.Is(err, ErrNoWeight) {
+		t.Errorf("parseWeight(12.3.4) = %v, want an invalid weight error", err)
+	}
+}
+
+func TestFrameFormatValidate(t *testing.T) {
+	invalid := []FrameFormat{
+		{WeightOffset: -1},
+		{WeightLength: -1},
+		{Decimals: 7},
+		{StatusOffset: intPtr(-1)},
+		{MotionChars: "M"},
+		{MotionMask: 8},
+	}
+	for _, f := range invalid {
+		if err := f.validate(); err == nil {
+			t.Errorf("%+v was accepted", f)
+		}
+	}
+	valid := FrameFormat{StatusOffset: intPtr(0), MotionMask: 8, Decimals: 2}
+	if err := valid.validate(); err != nil {
+		t.Errorf("valid format rejected: %v", err)
+	}
+
+	var f FrameFormat
+	f.setDefaults()
+	if f.End != "\r\n" || f.Unit != "kg" || f.MaxLength != 256 {
+		t.Errorf("defaults = %+v", f)
+	}
+}
@@ -0,0 +1,314 @@
+// Package indicators reads continuous ASCII output from weighbridge indicators
+// over raw TCP (directly or through a serial-to-Ethernet converter) and
+// publishes settled weights to weight:raw.
+package indicators
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
+)
+
+// idleTimeout closes connections from indicators that stopped sending; they
+// output several frames per second while powered.
+const idleTimeout = time.Minute
+
+// heartbeatInterval spaces the heartbeats recorded for a connected indicator.
+const heartbeatInterval = 30 * time.Second
+
+// Config binds one indicator to a scale. SourceID must be the source ID of the
+// scale's API key so the weight adapter finds its configuration.
+type Config struct {
+	Name       string `json:"name"`
+	SourceID   string `json:"source_id"`
+	SourceName string `json:"source_name"`
+	DeviceID   string `json:"device_id"`
+
+	// Exactly one of Listen (accept connections from the indicator or
+	// converter) and Connect (dial a converter in TCP server mode) is set.
+	Listen  string `json:"listen"`
+	Connect string `json:"connect"`
+
+	Frame FrameFormat `json:"frame"`
+
+	// MinWeight ignores an empty or nearly empty platform.
+	MinWeight float64 `json:"min_weight"`
+	// MinChange is the difference from the last published weight needed
+	// before another stable weight is published for the same vehicle.
+	MinChange float64 `json:"min_change"`
+	// StableFrames is how many consecutive stable frames with the same
+	// weight count as settled. Defaults to 3.
+	StableFrames int `json:"stable_frames"`
+}
+
+// LoadConfigs reads the listener list from WEIGHBRIDGE_LISTENERS (a JSON
+// array) or from the file named by WEIGHBRIDGE_LISTENERS_FILE.
+func LoadConfigs() ([]Config, error) {
+	data := []byte(os.Getenv("WEIGHBRIDGE_LISTENERS"))
+	if path := os.Getenv("WEIGHBRIDGE_LISTENERS_FILE"); len(data) == 0 && path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid weighbridge listener config: %w", err)
+	}
+	for i := range configs {
+		if err := configs[i].prepare(); err != nil {
+			return nil, fmt.Errorf("weighbridge listener %d (%s): %w", i, configs[i].Name, err)
+		}
+	}
+	return configs, nil
+}
+
+func (c *Config) prepare() error {
+	if c.SourceID == "" {
+		return errors.New("source_id is required")
+	}
+	if (c.Listen == "") == (c.Connect == "") {
+		return errors.New("exactly one of listen and connect is required")
+	}
+	if c.Name == "" {
+		c.Name = c.SourceID
+	}
+	if c.SourceName == "" {
+		c.SourceName = c.Name
+	}
+	if c.DeviceID == "" {
+		c.DeviceID = c.Name
+	}
+	if c.StableFrames <= 0 {
+		c.StableFrames = 3
+	}
+	c.Frame.setDefaults()
+	return c.Frame.validate()
+}
+
+// Run serves one listener until ctx is cancelled.
+func Run(ctx context.Context, cfg Config) {
+	if cfg.Listen != "" {
+		listen(ctx, cfg)
+		return
+	}
+	connect(ctx, cfg)
+}
+
+func listen(ctx context.Context, cfg Config) {
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Printf("Weighbridge %s: failed to listen on %s: %v", cfg.Name, cfg.Listen, err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	log.Printf("Weighbridge %s: listening on %s", cfg.Name, cfg.Listen)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Weighbridge %s: accept failed: %v", cfg.Name, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go func() {
+			log.Printf("Weighbridge %s: indicator connected from %s", cfg.Name, conn.RemoteAddr())
+			err := serve(ctx, cfg, conn)
+			log.Printf("Weighbridge %s: connection from %s closed: %v", cfg.Name, conn.RemoteAddr(), err)
+		}()
+	}
+}
+
+func connect(ctx context.Context, cfg Config) {
+	backoff := time.Second
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	for ctx.Err() == nil {
+		conn, err := dialer.DialContext(ctx, "tcp", cfg.Connect)
+		if err == nil {
+			log.Printf("Weighbridge %s: connected to %s", cfg.Name, cfg.Connect)
+			backoff = time.Second
+			err = serve(ctx, cfg, conn)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Weighbridge %s: %s: %v; retrying in %s", cfg.Name, cfg.Connect, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func serve(ctx context.Context, cfg Config, conn net.Conn) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	var s settler
+	var lastBeat time.Time
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		frame, err := readFrame(r, &cfg.Frame)
+		if err != nil {
+			return err
+		}
+		if frame == nil {
+			continue
+		}
+
+		reading, err := cfg.Frame.Parse(frame)
+		if err != nil {
+			continue
+		}
+		// The indicator streams readings all the time; that keeps the scale
+		// online in core even while nothing is weighed.
+		if time.Since(lastBeat) >= heartbeatInterval {
+			if err := repository.RecordHeartbeat(cfg.SourceID); err == nil {
+				lastBeat = time.Now()
+			}
+		}
+		if s.observe(&cfg, reading) {
+			publish(cfg, reading)
+		}
+	}
+}
+
+// readFrame returns the next frame without Start and End, or nil for an
+// oversized frame that was dropped. Without Start, the rest of a dropped
+// frame is skipped through End so its tail is not read as the next frame.
+func readFrame(r *bufio.Reader, f *FrameFormat) ([]byte, error) {
+	if f.Start != "" {
+		if err := skipTo(r, f.Start); err != nil {
+			return nil, err
+		}
+	}
+
+	var frame []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		frame = append(frame, b)
+		if bytes.HasSuffix(frame, []byte(f.End)) {
+			frame = frame[:len(frame)-len(f.End)]
+			if f.Start != "" {
+				// A dropped byte can leave a Start inside the frame; the
+				// last one begins the real frame.
+				if i := bytes.LastIndex(frame, []byte(f.Start)); i >= 0 {
+					frame = frame[i+len(f.Start):]
+				}
+			}
+			return frame, nil
+		}
+		if len(frame) > f.MaxLength {
+			if f.Start == "" {
+				if err := skipTo(r, f.End); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		}
+	}
+}
+
+func skipTo(r *bufio.Reader, marker string) error {
+	matched := 0
+	for matched < len(marker) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case b == marker[matched]:
+			matched++
+		case b == marker[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return nil
+}
+
+// settler turns a continuous reading stream into one event per settled
+// weight. A vehicle is published once per weight; the platform has to drop
+// below MinWeight or the weight has to move by MinChange before the next.
+type settler struct {
+	candidate float64
+	count     int
+	published *float64
+}
+
+func (s *settler) observe(cfg *Config, r Reading) bool {
+	if r.Weight < cfg.MinWeight {
+		s.published = nil
+		s.count = 0
+		return false
+	}
+	if !r.Stable {
+		s.count = 0
+		return false
+	}
+	if s.count == 0 || r.Weight != s.candidate {
+		s.candidate = r.Weight
+		s.count = 0
+	}
+	s.count++
+	if s.count < cfg.StableFrames {
+		return false
+	}
+	if s.published != nil {
+		if diff := math.Abs(*s.published - r.Weight); diff == 0 || diff < cfg.MinChange {
+			return false
+		}
+	}
+	w := r.Weight
+	s.published = &w
+	return true
+}
+
+// payload is what the scale's field mapping sees; map "weight" to "weight".
+type payload struct {
+	Weight float64 `json:"weight"`
+	Unit   string  `json:"unit"`
+	Stable bool    `json:"stable"`
+	Raw    string  `json:"raw"`
+}
+
+func publish(cfg Config, r Reading) {
+	data, _ := json.Marshal(payload{Weight: r.Weight, Unit: cfg.Frame.Unit, Stable: r.Stable, Raw: r.Raw})
+	event, err := repository.ProcessIncomingEvent(nil, cfg.DeviceID, string(data), cfg.SourceID, cfg.SourceName, "weight", streams.WeightRaw, nil)
+	if err != nil {
+		log.Printf("Weighbridge %s: failed to publish %.2f %s: %v", cfg.Name, r.Weight, cfg.Frame.Unit, err)
+		return
+	}
+	log.Printf("Weighbridge %s: published %.2f %s as %s", cfg.Name, r.Weight, cfg.Frame.Unit, event.EventID)
+}
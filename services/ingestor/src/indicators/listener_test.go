@@ -0,0 +1,152 @@
+package indicators
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// frames reads every frame of input, "<dropped>" marking an oversized one.
+func frames(t *testing.T, f FrameFormat, input string) []string {
+	t.Helper()
+	f.setDefaults()
+	r := bufio.NewReader(strings.NewReader(input))
+	var got []string
+	for {
+		frame, err := readFrame(r, &f)
+		if errors.Is(err, io.EOF) {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame == nil {
+			got = append(got, "<dropped>")
+		} else {
+			got = append(got, string(frame))
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	cases := []struct {
+		name   string
+		format FrameFormat
+		input  string
+		want   []string
+	}{
+		{"end only", FrameFormat{}, "100\r\n200\r\n", []string{"100", "200"}},
+		{"start and end", FrameFormat{Start: "\x02", End: "\r"}, "\x02100\r\x02200\r", []string{"100", "200"}},
+		{"noise before start", FrameFormat{Start: "\x02", End: "\r"}, "garbage\r\x02100\r", []string{"100"}},
+		{"restarted frame", FrameFormat{Start: "\x02", End: "\r"}, "\x0210\x02200\r", []string{"200"}},
+		{"multi-byte start", FrameFormat{Start: "ST", End: ";"}, "SSTa;xxSTb;", []string{"a", "b"}},
+		{"partial frame at eof", FrameFormat{}, "100\r\n20", []string{"100"}},
+		{
+			"oversized with start",
+			FrameFormat{Start: "\x02", End: "\r", MaxLength: 8},
+			"\x02123456789012\r\x02200\r",
+			[]string{"<dropped>", "200"},
+		},
+		{
+			// The tail of the dropped frame must not come back as a frame.
+			"oversized without start",
+			FrameFormat{End: "\r\n", MaxLength: 8},
+			"1234567890123456\r\n200\r\n",
+			[]string{"<dropped>", "200"},
+		},
+		{
+			"oversized without start at eof",
+			FrameFormat{End: "\r\n", MaxLength: 4},
+			"1234567",
+			nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := frames(t, tc.format, tc.input)
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("frames = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSettler(t *testing.T) {
+	cfg := &Config{MinWeight: 500, MinChange: 100, StableFrames: 3}
+	type step struct {
+		weight  float64
+		stable  bool
+		publish bool
+	}
+	steps := []step{
+		{0, true, false},     // empty platform
+		{8000, false, false}, // driving on
+		{15000, true, false}, // 1st stable frame
+		{15000, true, false}, // 2nd
+		{15020, true, false}, // changed, starts over
+		{15020, true, false}, // 2nd
+		{15020, true, true},  // 3rd: settled
+		{15020, true, false}, // same weight is not published again
+		{15050, true, false}, // within MinChange
+		{15050, true, false},
+		{15050, true, false},  // settled but within MinChange
+		{15300, false, false}, // moving
+		{15300, true, false},
+		{15300, true, false},
+		{15300, true, true}, // moved by MinChange: published
+		{200, true, false},  // platform emptied
+		{15300, true, false},
+		{15300, true, false},
+		{15300, true, true}, // next vehicle of the same weight
+	}
+	var s settler
+	for i, st := range steps {
+		if got := s.observe(cfg, Reading{Weight: st.weight, Stable: st.stable}); got != st.publish {
+			t.Errorf("step %d (%v, stable=%v): publish = %v, want %v", i, st.weight, st.stable, got, st.publish)
+		}
+	}
+}
+
+func TestConfigPrepare(t *testing.T) {
+	cfg := Config{SourceID: "scale-1", Listen: ":4001"}
+	if err := cfg.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "scale-1" || cfg.SourceName != "scale-1" || cfg.DeviceID != "scale-1" || cfg.StableFrames != 3 || cfg.Frame.End != "\r\n" {
+		t.Errorf("defaults = %+v", cfg)
+	}
+
+	for _, bad := range []Config{
+		{Listen: ":4001"},
+		{SourceID: "s"},
+		{SourceID: "s", Listen: ":4001", Connect: "converter:4001"},
+		{SourceID: "s", Listen: ":4001", Frame: FrameFormat{Decimals: 9}},
+	} {
+		if err := bad.prepare(); err == nil {
+			t.Errorf("%+v was accepted", bad)
+		}
+	}
+}
+
+func TestLoadConfigs(t *testing.T) {
+	t.Setenv("WEIGHBRIDGE_LISTENERS", `[{"source_id":"s1","connect":"10.0.0.5:4001","frame":{"start":"\u0002","end":"\r","weight_offset":3,"weight_length":6,"status_offset":1,"motion_mask":8}}]`)
+	configs, err := LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].Frame.Start != "\x02" || *configs[0].Frame.StatusOffset != 1 || configs[0].Frame.MotionMask != 8 {
+		t.Errorf("configs = %+v", configs)
+	}
+
+	t.Setenv("WEIGHBRIDGE_LISTENERS", `[{"listen":":4001"}]`)
+	if _, err := LoadConfigs(); err == nil {
+		t.Error("listener without source_id was accepted")
+	}
+	t.Setenv("WEIGHBRIDGE_LISTENERS", "")
+	t.Setenv("WEIGHBRIDGE_LISTENERS_FILE", "")
+	if configs, err := LoadConfigs(); err != nil || configs != nil {
+		t.Errorf("unset config gave %v, %v", configs, err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package metrics keeps in-process counters of ingest outcomes. Each replica
+// reports its own; sum them across replicas for the cluster view.
+package metrics
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Accepted counts events appended to a stream.
+	Accepted atomic.Int64
+	// Throttled counts requests refused with 429 by a per-source limit.
+	Throttled atomic.Int64
+	// Dropped counts requests shed with 503: the concurrency cap was full or
+	// a backend was slow or down.
+	Dropped atomic.Int64
+	// InFlight is the number of ingest requests being processed now.
+	InFlight atomic.Int64
+
+	started = time.Now()
+
+	mu              sync.Mutex
+	throttledSource = map[string]int64{}
+	droppedSource   = map[string]int64{}
+)
+
+func ThrottledFor(sourceID string) {
+	Throttled.Add(1)
+	mu.Lock()
+	throttledSource[sourceID]++
+	mu.Unlock()
+}
+
+func DroppedFor(sourceID string) {
+	Dropped.Add(1)
+	mu.Lock()
+	droppedSource[sourceID]++
+	mu.Unlock()
+}
+
+type Snapshot struct {
+	Instance          string           `json:"instance"`
+	Since             time.Time        `json:"since"`
+	Accepted          int64            `json:"accepted"`
+	Throttled         int64            `json:"throttled"`
+	Dropped           int64            `json:"dropped"`
+	InFlight          int64            `json:"in_flight"`
+	ThrottledBySource map[string]int64 `json:"throttled_by_source"`
+	DroppedBySource   map[string]int64 `json:"dropped_by_source"`
+}
+
+func Read() Snapshot {
+	host, _ := os.Hostname()
+	s := Snapshot{
+		Instance:          host,
+		Since:             started,
+		Accepted:          Accepted.Load(),
+		Throttled:         Throttled.Load(),
+		Dropped:           Dropped.Load(),
+		InFlight:          InFlight.Load(),
+		ThrottledBySource: map[string]int64{},
+		DroppedBySource:   map[string]int64{},
+	}
+	mu.Lock()
+	for k, v := range throttledSource {
+		s.ThrottledBySource[k] = v
+	}
+	for k, v := range droppedSource {
+		s.DroppedBySource[k] = v
+	}
+	mu.Unlock()
+	return s
+}
@@ -2,17 +2,69 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Attachment roles a camera may upload, one file each. ImageKey points at the
+// first of RolePlate, RoleImage and RoleOverview present.
+const (
+	RoleImage    = "image"
+	RolePlate    = "plate"
+	RoleOverview = "overview"
+	RoleClip     = "clip"
+)
+
+var AttachmentRoles = []string{RolePlate, RoleImage, RoleOverview, RoleClip}
+
+type Attachment struct {
+	Role        string `json:"role"`
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+}
+
 type IngestEvent struct {
-	Type       string    `json:"type"`
-	SourceID   string    `json:"source_id"`
-	SourceName string    `json:"source_name"`
-	DeviceID   string    `json:"device_id"`
-	ImageKey   *string   `json:"image_key"`
-	Payload    string    `json:"payload"`
-	At         time.Time `json:"at"`
+	EventID     string       `json:"event_id"`
+	Type        string       `json:"type"`
+	SourceID    string       `json:"source_id"`
+	SourceName  string       `json:"source_name"`
+	DeviceID    string       `json:"device_id"`
+	ImageKey    *string      `json:"image_key"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Payload     string       `json:"payload"`
+	At          time.Time    `json:"at"`
+
+	// CapturedAt is the device's own time for the event, when it sent one;
+	// At is always the ingestor's receive time. DeviceEventID is set by
+	// devices that buffer events offline, see /ingest/batch.
+	CapturedAt    *time.Time `json:"captured_at,omitempty"`
+	DeviceEventID string     `json:"device_event_id,omitempty"`
+}
+
+// ParseCapturedAt reads a device capture time given as RFC 3339 or as a Unix
+// timestamp in seconds or milliseconds. An empty string yields nil.
+func ParseCapturedAt(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		t := time.Unix(n, 0)
+		if n > 1e11 {
+			t = time.UnixMilli(n)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, fmt.Errorf("captured_at must be RFC 3339 or a Unix timestamp: %q", s)
+	}
+	return &t, nil
 }
 
 func (e *IngestEvent) ToJSON() string {
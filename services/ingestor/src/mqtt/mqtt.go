@@ -0,0 +1,355 @@
+// Package mqtt is a minimal MQTT 3.1.1 subscriber: enough to receive device
+// publishes at QoS 0 and 1. It does not publish.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPubrec     = 5
+	packetPubrel     = 6
+	packetPubcomp    = 7
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+
+	maxPacketSize = 16 << 20
+)
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retained is set when the broker replays the topic's retained message
+	// on subscribe rather than forwarding a new publish.
+	Retained bool
+}
+
+// Handler processes one message. Returning an error drops the connection
+// without acknowledging, so the broker redelivers QoS 1 messages after the
+// reconnect. Return nil for messages that can never succeed.
+type Handler func(ctx context.Context, msg Message) error
+
+type Options struct {
+	// Broker is host:port, optionally prefixed with tcp:// or tls://.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	Topics   []string
+	// QoS is the maximum QoS requested for every topic (0 or 1).
+	QoS       byte
+	KeepAlive time.Duration
+}
+
+// Run keeps a session open until ctx is cancelled, reconnecting with backoff.
+// The session is persistent (clean session off) so QoS 1 messages published
+// while disconnected are delivered on reconnect.
+func Run(ctx context.Context, opts Options, handle Handler) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 30 * time.Second
+	}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := session(ctx, opts, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("MQTT session with %s ended: %v; reconnecting in %s", opts.Broker, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+type conn struct {
+	net.Conn
+	r  *bufio.Reader
+	mu sync.Mutex
+}
+
+func (c *conn) writePacket(header byte, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	buf := []byte{header}
+	buf = appendLength(buf, len(body))
+	buf = append(buf, body...)
+	_, err := c.Write(buf)
+	return err
+}
+
+func (c *conn) readPacket() (byte, []byte, error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := readLength(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length > maxPacketSize {
+		return 0, nil, fmt.Errorf("packet of %d bytes exceeds limit", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func dial(ctx context.Context, broker string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	switch {
+	case strings.HasPrefix(broker, "tls://"):
+		host := strings.TrimPrefix(broker, "tls://")
+		return (&tls.Dialer{NetDialer: d}).DialContext(ctx, "tcp", host)
+	default:
+		return d.DialContext(ctx, "tcp", strings.TrimPrefix(broker, "tcp://"))
+	}
+}
+
+func session(ctx context.Context, opts Options, handle Handler) error {
+	nc, err := dial(ctx, opts.Broker)
+	if err != nil {
+		return err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	defer c.Close()
+
+	// Unblock reads when the caller shuts down.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.writePacket(packetDisconnect<<4, nil)
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	if err := connect(c, opts); err != nil {
+		return err
+	}
+	if err := subscribe(c, opts); err != nil {
+		return err
+	}
+	log.Printf("MQTT connected to %s, subscribed to %s", opts.Broker, strings.Join(opts.Topics, ", "))
+
+	go keepAlive(c, opts.KeepAlive, done)
+
+	for {
+		c.SetReadDeadline(time.Now().Add(opts.KeepAlive * 3 / 2))
+		header, body, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+
+		switch header >> 4 {
+		case packetPublish:
+			if err := onPublish(ctx, c, header, body, handle); err != nil {
+				return err
+			}
+		case packetPubrel:
+			// Only reachable if the broker ignored our QoS 1 cap.
+			if len(body) < 2 {
+				return errors.New("PUBREL without packet id")
+			}
+			if err := c.writePacket(packetPubcomp<<4, body[:2]); err != nil {
+				return err
+			}
+		case packetSuback:
+			if err := checkSuback(body, opts.Topics); err != nil {
+				return err
+			}
+		case packetPingresp, packetPuback:
+		default:
+			return fmt.Errorf("unexpected packet type %d", header>>4)
+		}
+	}
+}
+
+func connect(c *conn, opts Options) error {
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+
+	var flags byte // clean session off
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive/time.Second))
+
+	body = appendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+	if err := c.writePacket(packetConnect<<4, body); err != nil {
+		return err
+	}
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	header, resp, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if header>>4 != packetConnack || len(resp) < 2 {
+		return errors.New("expected CONNACK")
+	}
+	if resp[1] != 0 {
+		return fmt.Errorf("connection refused by broker (code %d)", resp[1])
+	}
+	return nil
+}
+
+func subscribe(c *conn, opts Options) error {
+	if len(opts.Topics) == 0 {
+		return errors.New("no topics to subscribe to")
+	}
+	body := binary.BigEndian.AppendUint16(nil, 1)
+	for _, topic := range opts.Topics {
+		body = appendString(body, topic)
+		body = append(body, opts.QoS)
+	}
+	return c.writePacket(packetSubscribe<<4|0x02, body)
+}
+
+// checkSuback fails when the broker refused any of the topics, usually
+// because its ACL does not let this client read them.
+func checkSuback(body []byte, topics []string) error {
+	if len(body) != 2+len(topics) {
+		return fmt.Errorf("SUBACK with %d return codes for %d topics", len(body)-2, len(topics))
+	}
+	var refused []string
+	for i, code := range body[2:] {
+		if code == 0x80 {
+			refused = append(refused, topics[i])
+		}
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("subscription refused by broker for %s", strings.Join(refused, ", "))
+	}
+	return nil
+}
+
+func onPublish(ctx context.Context, c *conn, header byte, body []byte, handle Handler) error {
+	qos := header >> 1 & 0x03
+	retained := header&0x01 != 0
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	var packetID []byte
+	if qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("PUBLISH without packet id")
+		}
+		packetID, rest = rest[:2], rest[2:]
+	}
+
+	if err := handle(ctx, Message{Topic: topic, Payload: rest, Retained: retained}); err != nil {
+		return fmt.Errorf("handling message on %s: %w", topic, err)
+	}
+
+	switch qos {
+	case 1:
+		return c.writePacket(packetPuback<<4, packetID)
+	case 2:
+		return c.writePacket(packetPubrec<<4, packetID)
+	}
+	return nil
+}
+
+func keepAlive(c *conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writePacket(packetPingreq<<4, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func appendLength(buf []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			return buf
+		}
+	}
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	n, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		mult *= 128
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("short string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("short string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
@@ -0,0 +1,188 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLengthRoundTrip(t *testing.T) {
+	cases := []struct {
+		n     int
+		bytes int
+	}{
+		{0, 1}, {127, 1}, {128, 2}, {16383, 2}, {16384, 3},
+		{2097151, 3}, {2097152, 4}, {268435455, 4},
+	}
+	for _, tc := range cases {
+		buf := appendLength(nil, tc.n)
+		if len(buf) != tc.bytes {
+			t.Errorf("appendLength(%d) used %d bytes, want %d", tc.n, len(buf), tc.bytes)
+		}
+		got, err := readLength(bytes.NewReader(buf))
+		if err != nil || got != tc.n {
+			t.Errorf("readLength(appendLength(%d)) = %d, %v", tc.n, got, err)
+		}
+	}
+}
+
+func TestReadLengthMalformed(t *testing.T) {
+	if _, err := readLength(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x01})); err == nil {
+		t.Error("five length bytes were accepted")
+	}
+	if _, err := readLength(bytes.NewReader([]byte{0x80})); err == nil {
+		t.Error("truncated length was accepted")
+	}
+}
+
+func TestReadString(t *testing.T) {
+	s, rest, err := readString(append(appendString(nil, "a/b"), 0x00, 0x01))
+	if err != nil || s != "a/b" || !bytes.Equal(rest, []byte{0x00, 0x01}) {
+		t.Errorf("readString = %q, %v, %v", s, rest, err)
+	}
+	for _, b := range [][]byte{nil, {0x00}, {0x00, 0x05, 'a'}} {
+		if _, _, err := readString(b); err == nil {
+			t.Errorf("readString(%v) accepted a short string", b)
+		}
+	}
+}
+
+func TestCheckSuback(t *testing.T) {
+	topics := []string{"a/+", "b/+"}
+	if err := checkSuback([]byte{0, 1, 0x01, 0x00}, topics); err != nil {
+		t.Errorf("granted subscription failed: %v", err)
+	}
+	err := checkSuback([]byte{0, 1, 0x01, 0x80}, topics)
+	if err == nil || !strings.Contains(err.Error(), "b/+") || strings.Contains(err.Error(), "a/+") {
+		t.Errorf("refused subscription gave %v", err)
+	}
+	if err := checkSuback([]byte{0, 1, 0x01}, topics); err == nil {
+		t.Error("SUBACK with a missing return code was accepted")
+	}
+}
+
+// fakeBroker accepts one connection, answers CONNECT and SUBSCRIBE and then
+// runs script against the client.
+func fakeBroker(t *testing.T, suback []byte, script func(b *conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		b := &conn{Conn: nc, r: bufio.NewReader(nc)}
+		defer b.Close()
+		b.SetDeadline(time.Now().Add(5 * time.Second))
+
+		header, body, err := b.readPacket()
+		if err != nil || header>>4 != packetConnect {
+			t.Errorf("expected CONNECT, got %d: %v", header>>4, err)
+			return
+		}
+		if flags := body[7]; flags&0x02 != 0 {
+			t.Errorf("CONNECT asked for a clean session")
+		}
+		b.writePacket(packetConnack<<4, []byte{0, 0})
+
+		header, _, err = b.readPacket()
+		if err != nil || header != packetSubscribe<<4|0x02 {
+			t.Errorf("expected SUBSCRIBE, got %#x: %v", header, err)
+			return
+		}
+		b.writePacket(packetSuback<<4, suback)
+		script(b)
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func publish(b *conn, flags byte, id uint16, topic, payload string) {
+	body := appendString(nil, topic)
+	if flags>>1&0x03 > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	b.writePacket(packetPublish<<4|flags, append(body, payload...))
+}
+
+func expectPuback(t *testing.T, b *conn, id uint16) {
+	t.Helper()
+	header, body, err := b.readPacket()
+	if err != nil || header>>4 != packetPuback || len(body) != 2 || binary.BigEndian.Uint16(body) != id {
+		t.Errorf("expected PUBACK %d, got %#x %v: %v", id, header, body, err)
+	}
+}
+
+func testOptions(broker string) Options {
+	return Options{Broker: broker, ClientID: "test", Topics: []string{"truckguard/+/weight"}, QoS: 1, KeepAlive: 10 * time.Second}
+}
+
+func TestSessionDeliversAndAcknowledges(t *testing.T) {
+	broker := fakeBroker(t, []byte{0, 1, 0x01}, func(b *conn) {
+		publish(b, 0x02|0x01, 1, "truckguard/s1/weight", "old")
+		expectPuback(t, b, 1)
+		publish(b, 0x02, 2, "truckguard/s1/weight", "new")
+		expectPuback(t, b, 2)
+		publish(b, 0x00, 0, "truckguard/s2/weight", "qos0")
+		// A PUBREL without its packet id must end the session, not panic.
+		b.writePacket(packetPubrel<<4|0x02, []byte{0x01})
+		b.readPacket()
+	})
+
+	var got []Message
+	err := session(context.Background(), testOptions(broker), func(_ context.Context, msg Message) error {
+		got = append(got, msg)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "PUBREL") {
+		t.Errorf("session ended with %v, want a PUBREL error", err)
+	}
+
+	want := []Message{
+		{Topic: "truckguard/s1/weight", Payload: []byte("old"), Retained: true},
+		{Topic: "truckguard/s1/weight", Payload: []byte("new")},
+		{Topic: "truckguard/s2/weight", Payload: []byte("qos0")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Topic != want[i].Topic || !bytes.Equal(got[i].Payload, want[i].Payload) || got[i].Retained != want[i].Retained {
+			t.Errorf("message %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSessionFailsWhenSubscriptionRefused(t *testing.T) {
+	broker := fakeBroker(t, []byte{0, 1, 0x80}, func(b *conn) { b.readPacket() })
+	err := session(context.Background(), testOptions(broker), func(context.Context, Message) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Errorf("session ended with %v, want a refused subscription", err)
+	}
+}
+
+func TestSessionDoesNotAcknowledgeFailedMessage(t *testing.T) {
+	acked := make(chan bool, 1)
+	broker := fakeBroker(t, []byte{0, 1, 0x01}, func(b *conn) {
+		publish(b, 0x02, 7, "truckguard/s1/weight", "x")
+		header, _, err := b.readPacket()
+		acked <- err == nil && header>>4 == packetPuback
+	})
+	failed := errors.New("stream unavailable")
+	err := session(context.Background(), testOptions(broker), func(context.Context, Message) error { return failed })
+	if !errors.Is(err, failed) {
+		t.Errorf("session ended with %v, want the handler error", err)
+	}
+	if <-acked {
+		t.Error("a message the handler failed was acknowledged")
+	}
+}
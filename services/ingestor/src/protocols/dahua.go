@@ -0,0 +1,75 @@
+package protocols
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/truckguard/ingestor/src/models"
+	"github.com/truckguard/ingestor/src/repository"
+)
+
+var ErrNoDahuaPicture = errors.New("no Picture object found in Dahua event")
+
+// dahuaPictures maps the picture objects of a Dahua ITC upload to roles, in
+// order of preference.
+var dahuaPictures = []struct {
+	Key  string
+	Role string
+}{
+	{"CutoutPic", models.RolePlate},
+	{"NormalPic", models.RoleOverview},
+	{"VehiclePic", models.RoleOverview},
+}
+
+// ParseDahua reads a Dahua ITC "HTTP upload" JSON event. Pictures arrive
+// base64 encoded in the document; they are stored as attachments and their
+// Content removed from the forwarded payload.
+func ParseDahua(body []byte) (*VendorEvent, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	picture, ok := doc["Picture"].(map[string]interface{})
+	if !ok {
+		return nil, ErrNoDahuaPicture
+	}
+
+	event := &VendorEvent{}
+	for _, p := range dahuaPictures {
+		pic, ok := picture[p.Key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		content, _ := pic["Content"].(string)
+		if content == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, errors.New("invalid base64 in Picture." + p.Key)
+		}
+		delete(pic, "Content")
+		event.addUpload(repository.BytesUpload(p.Role, data))
+	}
+
+	if snap, ok := picture["SnapInfo"].(map[string]interface{}); ok {
+		event.DeviceID, _ = snap["DeviceID"].(string)
+		// UTC is whole seconds; UTCMS adds the milliseconds.
+		if sec, ok := snap["UTC"].(float64); ok && sec > 0 {
+			ms, _ := snap["UTCMS"].(float64)
+			t := time.UnixMilli(int64(sec)*1000 + int64(ms))
+			event.CapturedAt = &t
+		}
+	}
+	plate, _ := picture["Plate"].(map[string]interface{})
+	event.Ignore = plate == nil
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = string(payload)
+	return event, nil
+}
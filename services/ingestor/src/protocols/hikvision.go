@@ -0,0 +1,121 @@
+package protocols
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime/multipart"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/truckguard/ingestor/src/models"
+	"github.com/truckguard/ingestor/src/repository"
+)
+
+const maxXMLBytes = 1 << 20
+
+var ErrNoAlertXML = errors.New("no EventNotificationAlert XML found in request")
+
+// hikvisionAlert is the part of an ISAPI EventNotificationAlert we inspect.
+// The full XML is forwarded as the payload.
+type hikvisionAlert struct {
+	XMLName    xml.Name `xml:"EventNotificationAlert"`
+	IPAddress  string   `xml:"ipAddress"`
+	MacAddress string   `xml:"macAddress"`
+	EventType  string   `xml:"eventType"`
+	DateTime   string   `xml:"dateTime"`
+	ANPR       *struct {
+		LicensePlate string `xml:"licensePlate"`
+	} `xml:"ANPR"`
+}
+
+// ParseHikvision reads an ISAPI alarm push. Cameras send either the bare XML
+// or a multipart body with the XML part and one part per picture
+// (licensePlatePicture.jpg, detectionPicture.jpg, ...).
+func ParseHikvision(body []byte, form *multipart.Form) (*VendorEvent, error) {
+	event := &VendorEvent{}
+	xmlData := body
+
+	if form != nil {
+		xmlData = nil
+		for _, values := range form.Value {
+			for _, v := range values {
+				if looksLikeXML([]byte(v)) {
+					xmlData = []byte(v)
+				}
+			}
+		}
+
+		names := make([]string, 0, len(form.File))
+		for name := range form.File {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, fh := range form.File[name] {
+				if isXMLPart(name, fh) {
+					data, err := readPart(fh)
+					if err != nil {
+						return nil, err
+					}
+					xmlData = data
+					continue
+				}
+				event.addUpload(repository.FileUpload(hikvisionRole(name, fh.Filename), fh))
+			}
+		}
+	}
+
+	if !looksLikeXML(xmlData) {
+		return nil, ErrNoAlertXML
+	}
+	var alert hikvisionAlert
+	if err := xml.Unmarshal(xmlData, &alert); err != nil {
+		return nil, err
+	}
+
+	event.Payload = string(xmlData)
+	event.DeviceID = alert.MacAddress
+	if event.DeviceID == "" {
+		event.DeviceID = alert.IPAddress
+	}
+	event.Ignore = !strings.EqualFold(alert.EventType, "ANPR") || alert.ANPR == nil
+	if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(alert.DateTime)); err == nil {
+		event.CapturedAt = &t
+	}
+	return event, nil
+}
+
+// hikvisionRole maps a picture part to an attachment role: the plate crop is
+// licensePlatePicture, everything else is a wider shot of the vehicle.
+func hikvisionRole(field, filename string) string {
+	name := strings.ToLower(field + " " + filename)
+	if strings.Contains(name, "plate") {
+		return models.RolePlate
+	}
+	return models.RoleOverview
+}
+
+func isXMLPart(field string, fh *multipart.FileHeader) bool {
+	if strings.EqualFold(path.Ext(fh.Filename), ".xml") || strings.EqualFold(path.Ext(field), ".xml") {
+		return true
+	}
+	return strings.Contains(fh.Header.Get("Content-Type"), "xml")
+}
+
+func readPart(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxXMLBytes))
+}
+
+func looksLikeXML(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<"))
+}
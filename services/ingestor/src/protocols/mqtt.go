@@ -0,0 +1,169 @@
+package protocols
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/truckguard/ingestor/src/images"
+	"github.com/truckguard/ingestor/src/models"
+	"github.com/truckguard/ingestor/src/mqtt"
+	"github.com/truckguard/ingestor/src/repository"
+	"github.com/truckguard/ingestor/src/streams"
+)
+
+const defaultMQTTTopics = "truckguard/+/camera,truckguard/+/weight"
+
+// mqttMessage is the JSON body devices publish. Payload may be the adapter
+// payload as a string or an object; a message that is not JSON at all is
+// taken as the payload itself.
+type mqttMessage struct {
+	DeviceID    string          `json:"device_id"`
+	SourceName  string          `json:"source_name"`
+	CapturedAt  string          `json:"captured_at"`
+	Payload     json.RawMessage `json:"payload"`
+	Attachments []struct {
+		Role string `json:"role"`
+		Data string `json:"data"`
+	} `json:"attachments"`
+}
+
+// MQTTOptionsFromEnv returns the subscriber settings, or false when
+// MQTT_BROKER is unset and MQTT ingestion is disabled.
+func MQTTOptionsFromEnv() (mqtt.Options, bool) {
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		return mqtt.Options{}, false
+	}
+
+	clientID := os.Getenv("MQTT_CLIENT_ID")
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "truckguard-ingestor-" + host
+	}
+	topics := os.Getenv("MQTT_TOPICS")
+	if topics == "" {
+		topics = defaultMQTTTopics
+	}
+
+	var list []string
+	for _, t := range strings.Split(topics, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			list = append(list, t)
+		}
+	}
+
+	username := os.Getenv("MQTT_USERNAME")
+	if username == "" {
+		log.Printf("MQTT: MQTT_USERNAME is unset; a broker that accepts anonymous clients lets any device publish under any source id")
+	}
+
+	return mqtt.Options{
+		Broker:    broker,
+		ClientID:  clientID,
+		Username:  username,
+		Password:  os.Getenv("MQTT_PASSWORD"),
+		Topics:    list,
+		QoS:       1,
+		KeepAlive: 30 * time.Second,
+	}, true
+}
+
+// HandleMQTT ingests one message published on truckguard/<source_id>/<type>.
+// The source id is trusted as is: the broker's ACL must only let a device
+// publish under its own id (see infra/mosquitto).
+// Malformed messages are logged and acknowledged; storage failures are
+// returned so the broker redelivers. Retained messages are replays of an
+// event already ingested when it was published, so they are skipped.
+func HandleMQTT(_ context.Context, msg mqtt.Message) error {
+	if msg.Retained {
+		log.Printf("MQTT: skipping retained message on %s", msg.Topic)
+		return nil
+	}
+	parts := strings.Split(msg.Topic, "/")
+	if len(parts) != 3 || parts[1] == "" {
+		log.Printf("MQTT: ignoring message on unexpected topic %q", msg.Topic)
+		return nil
+	}
+	sourceID, eventType := parts[1], parts[2]
+
+	var stream string
+	switch eventType {
+	case "camera":
+		stream = streams.CameraRaw
+	case "weight":
+		stream = streams.WeightRaw
+	default:
+		log.Printf("MQTT: ignoring message on unexpected topic %q", msg.Topic)
+		return nil
+	}
+
+	event := &VendorEvent{}
+	sourceName := sourceID
+
+	var m mqttMessage
+	if err := json.Unmarshal(msg.Payload, &m); err != nil || len(m.Payload) == 0 {
+		event.Payload = string(msg.Payload)
+	} else {
+		event.DeviceID = m.DeviceID
+		if t, err := models.ParseCapturedAt(m.CapturedAt); err == nil {
+			event.CapturedAt = t
+		} else {
+			log.Printf("MQTT: ignoring %v on %s", err, msg.Topic)
+		}
+		if m.SourceName != "" {
+			sourceName = m.SourceName
+		}
+		var s string
+		if json.Unmarshal(m.Payload, &s) == nil {
+			event.Payload = s
+		} else {
+			event.Payload = string(m.Payload)
+		}
+		for _, a := range m.Attachments {
+			if !knownRole(a.Role) {
+				log.Printf("MQTT: dropping attachment with unknown role %q on %s", a.Role, msg.Topic)
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(a.Data)
+			if err != nil {
+				log.Printf("MQTT: dropping undecodable %s attachment on %s", a.Role, msg.Topic)
+				continue
+			}
+			event.addUpload(repository.BytesUpload(a.Role, data))
+		}
+	}
+
+	if eventType == "camera" && len(event.Uploads) == 0 {
+		log.Printf("MQTT: dropping camera event without attachments on %s", msg.Topic)
+		return nil
+	}
+
+	_, err := repository.ProcessIncomingEvent(event.Uploads, event.DeviceID, event.Payload, sourceID, sourceName, eventType, stream, event.CapturedAt)
+	if isInvalidUpload(err) {
+		log.Printf("MQTT: dropping event on %s: %v", msg.Topic, err)
+		return nil
+	}
+	return err
+}
+
+func knownRole(role string) bool {
+	for _, r := range models.AttachmentRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func isInvalidUpload(err error) bool {
+	return errors.Is(err, images.ErrTooLarge) ||
+		errors.Is(err, images.ErrUnsupportedType) ||
+		errors.Is(err, images.ErrCorrupt) ||
+		errors.Is(err, images.ErrDimensions)
+}
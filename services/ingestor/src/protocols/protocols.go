@@ -0,0 +1,34 @@
+// Package protocols translates vendor push formats into ingest events.
+package protocols
+
+import (
+	"time"
+
+	"github.com/truckguard/ingestor/src/repository"
+)
+
+// VendorEvent is a vendor notification reduced to what ProcessIncomingEvent
+// needs. Payload keeps the vendor document (minus embedded binaries) so the
+// adapters can apply the camera's field mapping to it.
+type VendorEvent struct {
+	DeviceID string
+	Payload  string
+	Uploads  []repository.Upload
+	// CapturedAt is the camera's own timestamp for the read, if it sent one.
+	CapturedAt *time.Time
+	// Ignore is set for notifications that carry no plate read, such as
+	// heartbeats; they are acknowledged and dropped.
+	Ignore bool
+}
+
+// addUpload keeps the first attachment per role; vendors sometimes send
+// several overview shots and IngestEvent holds one per role.
+func (e *VendorEvent) addUpload(u repository.Upload) bool {
+	for _, existing := range e.Uploads {
+		if existing.Role == u.Role {
+			return false
+		}
+	}
+	e.Uploads = append(e.Uploads, u)
+	return true
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DedupeTTL is how long a device event ID is remembered. It has to outlast
+// the longest outage an edge buffer is expected to ride out.
+var DedupeTTL = dedupeTTLFromEnv()
+
+func dedupeTTLFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("DEDUPE_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+func dedupeKey(sourceID, deviceEventID string) string {
+	return "ingest:dedupe:" + sourceID + ":" + deviceEventID
+}
+
+// ReserveDeviceEvent claims a device event ID for eventID. When the device
+// already delivered the event it returns false and the event ID it was
+// ingested under.
+func ReserveDeviceEvent(sourceID, deviceEventID, eventID string) (bool, string, error) {
+	key := dedupeKey(sourceID, deviceEventID)
+	ok, err := RDB.SetNX(ctx, key, eventID, DedupeTTL).Result()
+	if err != nil || ok {
+		return ok, "", err
+	}
+	existing, err := RDB.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// Expired in between; try once more.
+		ok, err = RDB.SetNX(ctx, key, eventID, DedupeTTL).Result()
+		return ok, "", err
+	}
+	return false, existing, err
+}
+
+// ReleaseDeviceEvent forgets a reservation whose event failed to publish, so
+// the device can retry it.
+func ReleaseDeviceEvent(sourceID, deviceEventID string) {
+	RDB.Del(ctx, dedupeKey(sourceID, deviceEventID))
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Redis hashes of source ID to the unix millisecond time a device was last
+// heard from. Core's device monitor reads them to tell which devices went
+// silent.
+const (
+	LastHeartbeatKey = "devices:last_heartbeat"
+	LastEventKey     = "devices:last_event"
+)
+
+// RecordHeartbeat notes that the device is alive without sending an event.
+func RecordHeartbeat(sourceID string) error {
+	return markSeen(LastHeartbeatKey, sourceID)
+}
+
+// recordActivity notes an accepted event. It is best effort; a failure only
+// delays the device being seen.
+func recordActivity(sourceID string) {
+	if err := markSeen(LastEventKey, sourceID); err != nil {
+		log.Printf("Failed to record activity of %s: %v", sourceID, err)
+	}
+}
+
+func markSeen(key, sourceID string) error {
+	if sourceID == "" {
+		return nil
+	}
+	return RDB.HSet(ctx, key, sourceID, strconv.FormatInt(time.Now().UnixMilli(), 10)).Err()
+}
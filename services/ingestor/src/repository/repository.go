@@ -1,26 +1,50 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"mime/multipart"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/redis/go-redis/v9"
+	"github.com/truckguard/ingestor/src/images"
+	"github.com/truckguard/ingestor/src/metrics"
 	"github.com/truckguard/ingestor/src/models"
+	"github.com/truckguard/ingestor/src/streams"
 )
 
 var (
 	RDB         *redis.Client
 	MinioClient *minio.Client
 	BucketName  string = os.Getenv("BUCKET_NAME")
-	ctx                = context.Background()
+	Retention          = streams.RetentionFromEnv()
+	ImageLimits        = images.LimitsFromEnv()
+	// BackendTimeout bounds each MinIO upload and stream append so a slow
+	// backend sheds load instead of piling up requests.
+	BackendTimeout = backendTimeoutFromEnv()
+	ctx            = context.Background()
 )
 
+// ErrBackendUnavailable wraps MinIO and Redis failures, including timeouts.
+// Callers answer 503 so devices retry later.
+var ErrBackendUnavailable = errors.New("storage backend unavailable")
+
+func backendTimeoutFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("INGEST_BACKEND_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
 func InitRedis(addr string) {
 	RDB = redis.NewClient(&redis.Options{Addr: addr})
 }
@@ -36,40 +60,159 @@ func InitMinio(endpoint, accessKey, secretKey string) {
 	MinioClient = client
 }
 
-func ProcessIncomingEvent(file *multipart.FileHeader, deviceID, payload, sourceID, sourceName, eventType, stream string) (*models.IngestEvent, error) {
-	var imageKey *string
-	if file != nil {
-		objectName := fmt.Sprintf("%s/%s.jpg", time.Now().Format("2006-01-02"), uuid.New().String())
-		src, _ := file.Open()
-		defer src.Close()
-
-		_, err := MinioClient.PutObject(ctx, BucketName, objectName, src, file.Size, minio.PutObjectOptions{
-			ContentType: "image/jpeg",
-			UserMetadata: map[string]string{
-				"x-amz-meta-source-id":   sourceID,
-				"x-amz-meta-source-name": sourceName,
-			},
-		})
-		if err != nil {
-			return nil, err
-		}
-		imageKey = &objectName
+// Upload is one attachment of an incoming event.
+type Upload struct {
+	Role string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+func FileUpload(role string, file *multipart.FileHeader) Upload {
+	return Upload{
+		Role: role,
+		Size: file.Size,
+		Open: func() (io.ReadCloser, error) { return file.Open() },
+	}
+}
+
+// BytesUpload wraps an attachment decoded from a vendor payload.
+func BytesUpload(role string, data []byte) Upload {
+	return Upload{
+		Role: role,
+		Size: int64(len(data)),
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
 	}
+}
 
+func ProcessIncomingEvent(uploads []Upload, deviceID, payload, sourceID, sourceName, eventType, stream string, capturedAt *time.Time) (*models.IngestEvent, error) {
 	event := models.IngestEvent{
 		Type:       eventType,
 		SourceID:   sourceID,
 		SourceName: sourceName,
 		DeviceID:   deviceID,
-		ImageKey:   imageKey,
 		Payload:    payload,
-		At:         time.Now(),
+		CapturedAt: capturedAt,
 	}
+	err := PublishEvent(uploads, &event, stream)
+	return &event, err
+}
+
+// PublishEvent stores the uploads and appends event to stream. EventID and At
+// are filled in when unset; the caller sets everything else.
+func PublishEvent(uploads []Upload, event *models.IngestEvent, stream string) error {
+	var attachments []models.Attachment
+	for _, u := range uploads {
+		att, err := storeAttachment(u, event.SourceID, event.SourceName)
+		if err != nil {
+			removeAttachments(attachments)
+			return err
+		}
+		attachments = append(attachments, *att)
+	}
+
+	if event.EventID == "" {
+		event.EventID = uuid.New().String()
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	event.ImageKey = primaryImageKey(attachments)
+	event.Attachments = attachments
 
-	_, err := RDB.XAdd(ctx, &redis.XAddArgs{
+	args := &redis.XAddArgs{
 		Stream: stream,
 		Values: map[string]interface{}{"data": event.ToJSON()},
-	}).Result()
+	}
+	Retention.Apply(args)
 
-	return &event, err
+	xctx, cancel := context.WithTimeout(ctx, BackendTimeout)
+	defer cancel()
+	if err := RDB.XAdd(xctx, args).Err(); err != nil {
+		// The append may still have landed after a timeout, so the
+		// attachments are left for the retention job.
+		return fmt.Errorf("%w: %v", ErrBackendUnavailable, err)
+	}
+	metrics.Accepted.Add(1)
+	recordActivity(event.SourceID)
+	return nil
+}
+
+// primaryImageKey picks the still that recognition should use, preferring the
+// plate crop.
+func primaryImageKey(attachments []models.Attachment) *string {
+	for _, role := range []string{models.RolePlate, models.RoleImage, models.RoleOverview} {
+		for i := range attachments {
+			if attachments[i].Role == role {
+				return &attachments[i].Key
+			}
+		}
+	}
+	return nil
+}
+
+// storeAttachment validates an upload by content and stores it in MinIO,
+// tagged with its role.
+func storeAttachment(u Upload, sourceID, sourceName string) (*models.Attachment, error) {
+	maxBytes := ImageLimits.MaxBytes
+	if u.Role == models.RoleClip {
+		maxBytes = ImageLimits.ClipMaxBytes
+	}
+	if u.Size > maxBytes {
+		return nil, fmt.Errorf("%s: %w", u.Role, images.ErrTooLarge)
+	}
+
+	src, err := u.Open()
+	if err != nil {
+		return nil, err
+	}
+	var img *images.Image
+	if u.Role == models.RoleClip {
+		img, err = images.ReadClip(src, maxBytes)
+	} else {
+		img, err = images.Read(src, ImageLimits)
+	}
+	src.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u.Role, err)
+	}
+
+	metadata := map[string]string{
+		"x-amz-meta-source-id":   sourceID,
+		"x-amz-meta-source-name": sourceName,
+	}
+	if img.Width > 0 {
+		metadata["x-amz-meta-width"] = strconv.Itoa(img.Width)
+		metadata["x-amz-meta-height"] = strconv.Itoa(img.Height)
+	}
+
+	objectName := fmt.Sprintf("%s/%s.%s", time.Now().Format("2006-01-02"), uuid.New().String(), img.Format.Extension)
+	pctx, cancel := context.WithTimeout(ctx, BackendTimeout)
+	defer cancel()
+	_, err = MinioClient.PutObject(pctx, BucketName, objectName, bytes.NewReader(img.Data), int64(len(img.Data)), minio.PutObjectOptions{
+		ContentType:  img.Format.ContentType,
+		UserMetadata: metadata,
+		UserTags:     map[string]string{"role": u.Role},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackendUnavailable, err)
+	}
+
+	return &models.Attachment{
+		Role:        u.Role,
+		Key:         objectName,
+		ContentType: img.Format.ContentType,
+		Size:        int64(len(img.Data)),
+		Width:       img.Width,
+		Height:      img.Height,
+	}, nil
+}
+
+// removeAttachments cleans up objects already stored for an event that failed
+// part way through.
+func removeAttachments(attachments []models.Attachment) {
+	for _, a := range attachments {
+		if err := MinioClient.RemoveObject(ctx, BucketName, a.Key, minio.RemoveObjectOptions{}); err != nil {
+			log.Printf("Failed to remove %s: %v", a.Key, err)
+		}
+	}
 }
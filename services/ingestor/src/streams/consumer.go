@@ -0,0 +1,204 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Handler processes one stream message. Returning an error leaves the
+// message pending so it is retried after ClaimIdle.
+type Handler func(ctx context.Context, msg redis.XMessage) error
+
+// Consumer reads a stream as a member of a consumer group. Messages are
+// acknowledged only after the handler succeeds, so a crash or restart resumes
+// where the group left off instead of replaying or skipping entries.
+// Zero MaxDeliveries, Batch, Block and ClaimIdle take the defaults below.
+type Consumer struct {
+	RDB    *redis.Client
+	Stream string
+	Group  string
+	Name   string
+
+	// DLQ receives messages that failed MaxDeliveries times. Leave empty, or
+	// set MaxDeliveries below zero, to retry forever.
+	DLQ           string
+	MaxDeliveries int64
+
+	Batch int64
+	Block time.Duration
+	// ClaimIdle is how long a message may stay unacknowledged, e.g. by a
+	// crashed consumer, before XAUTOCLAIM hands it to this one.
+	ClaimIdle time.Duration
+}
+
+const (
+	defaultConsumerMaxDeliveries = 5
+	defaultConsumerBatch         = 10
+	defaultConsumerBlock         = 5 * time.Second
+	defaultConsumerClaimIdle     = time.Minute
+)
+
+// EnsureGroup creates the stream and group if needed. A new group starts at
+// the beginning of the stream so nothing already retained is lost.
+func (c *Consumer) EnsureGroup(ctx context.Context) error {
+	err := c.RDB.XGroupCreateMkStream(ctx, c.Stream, c.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// Run consumes until ctx is cancelled. It first makes one pass over the
+// messages this consumer left pending before a restart, then reads new ones,
+// periodically claiming messages that stayed pending, its own failures
+// included, for another attempt.
+func (c *Consumer) Run(ctx context.Context, handle Handler) error {
+	if c.MaxDeliveries == 0 {
+		c.MaxDeliveries = defaultConsumerMaxDeliveries
+	}
+	if c.Batch <= 0 {
+		c.Batch = defaultConsumerBatch
+	}
+	if c.Block <= 0 {
+		c.Block = defaultConsumerBlock
+	}
+	if c.ClaimIdle <= 0 {
+		c.ClaimIdle = defaultConsumerClaimIdle
+	}
+	if err := c.EnsureGroup(ctx); err != nil {
+		return err
+	}
+
+	// "0" and later IDs page through our pending entries; ">" reads new ones.
+	id := "0"
+	var lastClaim time.Time
+
+	for ctx.Err() == nil {
+		if id == ">" && time.Since(lastClaim) >= c.ClaimIdle {
+			c.reclaim(ctx, handle)
+			lastClaim = time.Now()
+		}
+
+		res, err := c.RDB.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.Group,
+			Consumer: c.Name,
+			Streams:  []string{c.Stream, id},
+			Count:    c.Batch,
+			Block:    c.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Failed to read %s as %s/%s: %v", c.Stream, c.Group, c.Name, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		var last string
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				c.process(ctx, handle, msg)
+				last = msg.ID
+			}
+		}
+		// Page past what was just handled, so a message that failed again
+		// is left to reclaim instead of being read back forever.
+		if id != ">" {
+			if last == "" {
+				id = ">"
+			} else {
+				id = last
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// reclaim takes over messages idle for at least ClaimIdle.
+func (c *Consumer) reclaim(ctx context.Context, handle Handler) {
+	start := "0-0"
+	for {
+		msgs, next, err := c.RDB.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.Stream,
+			Group:    c.Group,
+			Consumer: c.Name,
+			MinIdle:  c.ClaimIdle,
+			Start:    start,
+			Count:    c.Batch,
+		}).Result()
+		if err != nil {
+			log.Printf("Failed to claim stuck messages on %s: %v", c.Stream, err)
+			return
+		}
+		for _, msg := range msgs {
+			c.process(ctx, handle, msg)
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, handle Handler, msg redis.XMessage) {
+	// Entries trimmed away while pending come back without values.
+	if len(msg.Values) == 0 {
+		c.ack(ctx, msg.ID)
+		return
+	}
+	if err := handle(ctx, msg); err != nil {
+		c.fail(ctx, msg, err)
+		return
+	}
+	c.ack(ctx, msg.ID)
+}
+
+func (c *Consumer) ack(ctx context.Context, id string) {
+	if err := c.RDB.XAck(ctx, c.Stream, c.Group, id).Err(); err != nil {
+		log.Printf("Failed to ack %s on %s: %v", id, c.Stream, err)
+	}
+}
+
+// fail dead-letters a message once it has been delivered MaxDeliveries times.
+// Earlier failures stay pending for reclaim to retry. The dead letter keeps
+// the payload as it was and records where it came from in dlq_ fields.
+func (c *Consumer) fail(ctx context.Context, msg redis.XMessage, cause error) {
+	log.Printf("Failed to process %s from %s: %v", msg.ID, c.Stream, cause)
+	if c.DLQ == "" || c.MaxDeliveries <= 0 {
+		return
+	}
+
+	pending, err := c.RDB.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.Stream,
+		Group:  c.Group,
+		Start:  msg.ID,
+		End:    msg.ID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 || pending[0].RetryCount < c.MaxDeliveries {
+		return
+	}
+
+	values := make(map[string]interface{}, len(msg.Values)+3)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["error"] = cause.Error()
+	values["source_stream"] = c.Stream
+	values["dlq_source_message_id"] = msg.ID
+
+	if err := c.RDB.XAdd(ctx, &redis.XAddArgs{Stream: c.DLQ, Values: values}).Err(); err != nil {
+		log.Printf("Failed to dead-letter %s to %s: %v", msg.ID, c.DLQ, err)
+		return
+	}
+	c.ack(ctx, msg.ID)
+}
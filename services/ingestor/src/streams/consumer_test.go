@@ -0,0 +1,177 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the Redis at TEST_REDIS_ADDR and returns stream names
+// unique to the test, deleted afterwards.
+func testRedis(t *testing.T) (*redis.Client, string) {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+	prefix := fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		rdb.Del(context.Background(), prefix+":stream", prefix+":dlq")
+		rdb.Close()
+	})
+	return rdb, prefix
+}
+
+func add(t *testing.T, rdb *redis.Client, stream, name string) string {
+	t.Helper()
+	id, err := rdb.XAdd(context.Background(), &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"name": name}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// runUntil runs the consumer until done reports true or the test times out.
+func runUntil(t *testing.T, c *Consumer, handle Handler, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	finished := make(chan struct{})
+	go func() {
+		c.Run(ctx, handle)
+		close(finished)
+	}()
+	for !done() {
+		if ctx.Err() != nil {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-finished
+}
+
+func TestConsumerBacklogFailureDoesNotBlockNewMessages(t *testing.T) {
+	rdb, prefix := testRedis(t)
+	ctx := context.Background()
+	c := &Consumer{RDB: rdb, Stream: prefix + ":stream", Group: "workers", Name: "w1", Block: 50 * time.Millisecond, ClaimIdle: time.Hour}
+	if err := c.EnsureGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash leaves two messages pending with w1.
+	bad := add(t, rdb, c.Stream, "bad")
+	add(t, rdb, c.Stream, "backlog")
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: c.Group, Consumer: c.Name, Streams: []string{c.Stream, ">"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	add(t, rdb, c.Stream, "new")
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	runUntil(t, c, func(_ context.Context, msg redis.XMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		name := msg.Values["name"].(string)
+		calls[name]++
+		if name == "bad" {
+			return errors.New("cannot process")
+		}
+		return nil
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["new"] > 0
+	})
+
+	if calls["bad"] != 1 || calls["backlog"] != 1 {
+		t.Errorf("backlog handled %v, want each message once", calls)
+	}
+	pending, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: c.Stream, Group: c.Group, Start: "-", End: "+", Count: 10}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != bad {
+		t.Errorf("pending = %+v, want only the failed message", pending)
+	}
+}
+
+func TestConsumerDeadLettersAfterMaxDeliveries(t *testing.T) {
+	rdb, prefix := testRedis(t)
+	ctx := context.Background()
+	c := &Consumer{
+		RDB: rdb, Stream: prefix + ":stream", Group: "workers", Name: "w1",
+		DLQ: prefix + ":dlq", MaxDeliveries: 3,
+		Block: 20 * time.Millisecond, ClaimIdle: 20 * time.Millisecond,
+	}
+	if err := c.EnsureGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: c.Stream, Values: map[string]interface{}{"name": "poison", "source_id": "cam-1"}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	attempts := 0
+	runUntil(t, c, func(context.Context, redis.XMessage) error {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		return errors.New("cannot process")
+	}, func() bool {
+		n, _ := rdb.XLen(ctx, c.DLQ).Result()
+		return n > 0
+	})
+
+	if attempts != 3 {
+		t.Errorf("handled %d times, want 3", attempts)
+	}
+	dead, err := rdb.XRange(ctx, c.DLQ, "-", "+").Result()
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("DLQ = %v, %v", dead, err)
+	}
+	if dead[0].Values["dlq_source_message_id"] != id || dead[0].Values["source_id"] != "cam-1" ||
+		dead[0].Values["error"] != "cannot process" || dead[0].Values["name"] != "poison" {
+		t.Errorf("dead letter = %v", dead[0].Values)
+	}
+	if n, _ := rdb.XPending(ctx, c.Stream, c.Group).Result(); n.Count != 0 {
+		t.Errorf("%d messages still pending after dead-lettering", n.Count)
+	}
+}
+
+func TestConsumerAcknowledgesHandledMessages(t *testing.T) {
+	rdb, prefix := testRedis(t)
+	ctx := context.Background()
+	c := &Consumer{RDB: rdb, Stream: prefix + ":stream", Group: "workers", Name: "w1", Block: 20 * time.Millisecond}
+	for i := 0; i < 25; i++ {
+		add(t, rdb, c.Stream, fmt.Sprint(i))
+	}
+
+	var mu sync.Mutex
+	seen := 0
+	runUntil(t, c, func(context.Context, redis.XMessage) error {
+		mu.Lock()
+		seen++
+		mu.Unlock()
+		return nil
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen == 25
+	})
+
+	if n, _ := rdb.XPending(ctx, c.Stream, c.Group).Result(); n.Count != 0 {
+		t.Errorf("%d messages still pending", n.Count)
+	}
+}
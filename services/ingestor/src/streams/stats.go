@@ -0,0 +1,76 @@
+package streams
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type ConsumerStats struct {
+	Name    string `json:"name"`
+	Pending int64  `json:"pending"`
+	IdleMs  int64  `json:"idle_ms"`
+}
+
+type GroupStats struct {
+	Name            string `json:"name"`
+	Pending         int64  `json:"pending"`
+	LastDeliveredID string `json:"last_delivered_id"`
+	// Lag is the number of entries not yet delivered to the group; -1 when
+	// Redis cannot tell (e.g. after entries were trimmed or deleted).
+	Lag       int64           `json:"lag"`
+	Consumers []ConsumerStats `json:"consumers"`
+}
+
+type StreamStats struct {
+	Stream  string       `json:"stream"`
+	Length  int64        `json:"length"`
+	FirstID string       `json:"first_id"`
+	LastID  string       `json:"last_id"`
+	Groups  []GroupStats `json:"groups"`
+}
+
+// Stats reports length, pending entries and lag for a stream and its groups.
+// A stream that does not exist yet reports as empty.
+func Stats(ctx context.Context, rdb *redis.Client, stream string) (StreamStats, error) {
+	stats := StreamStats{Stream: stream, Groups: []GroupStats{}}
+
+	info, err := rdb.XInfoStream(ctx, stream).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return stats, nil
+		}
+		return stats, err
+	}
+	stats.Length = info.Length
+	stats.FirstID = info.FirstEntry.ID
+	stats.LastID = info.LastEntry.ID
+
+	groups, err := rdb.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return stats, err
+	}
+	for _, g := range groups {
+		gs := GroupStats{
+			Name:            g.Name,
+			Pending:         g.Pending,
+			LastDeliveredID: g.LastDeliveredID,
+			Lag:             g.Lag,
+			Consumers:       []ConsumerStats{},
+		}
+		consumers, err := rdb.XInfoConsumers(ctx, stream, g.Name).Result()
+		if err != nil {
+			return stats, err
+		}
+		for _, c := range consumers {
+			gs.Consumers = append(gs.Consumers, ConsumerStats{
+				Name:    c.Name,
+				Pending: c.Pending,
+				IdleMs:  c.Idle.Milliseconds(),
+			})
+		}
+		stats.Groups = append(stats.Groups, gs)
+	}
+	return stats, nil
+}
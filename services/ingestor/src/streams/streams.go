@@ -0,0 +1,93 @@
+package streams
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	CameraRaw = "camera:raw"
+	WeightRaw = "weight:raw"
+	CameraDLQ = "camera:dlq"
+	WeightDLQ = "weight:dlq"
+
+	defaultMaxLen = 100000
+)
+
+// Managed lists the streams whose retention the ingestor enforces.
+var Managed = []string{CameraRaw, WeightRaw, CameraDLQ, WeightDLQ}
+
+// Retention is the trim policy for the managed streams. MaxAge (MINID) wins
+// over MaxLen (MAXLEN) because Redis accepts only one of them per call.
+type Retention struct {
+	MaxLen int64
+	MaxAge time.Duration
+}
+
+// RetentionFromEnv reads STREAM_MAXLEN (entries) and STREAM_MAX_AGE
+// (a Go duration such as "72h").
+func RetentionFromEnv() Retention {
+	r := Retention{MaxLen: defaultMaxLen}
+	if v := os.Getenv("STREAM_MAXLEN"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			r.MaxLen = n
+		} else {
+			log.Printf("Invalid STREAM_MAXLEN %q, using %d", v, r.MaxLen)
+		}
+	}
+	if v := os.Getenv("STREAM_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			r.MaxAge = d
+		} else {
+			log.Printf("Invalid STREAM_MAX_AGE %q, ignoring", v)
+		}
+	}
+	return r
+}
+
+// MinID returns the stream ID of the oldest entry the policy keeps.
+func (r Retention) MinID(now time.Time) string {
+	return strconv.FormatInt(now.Add(-r.MaxAge).UnixMilli(), 10)
+}
+
+// Apply sets the trim policy on an XADD so the stream is bounded as it grows.
+func (r Retention) Apply(args *redis.XAddArgs) {
+	args.Approx = true
+	if r.MaxAge > 0 {
+		args.MinID = r.MinID(time.Now())
+		return
+	}
+	args.MaxLen = r.MaxLen
+}
+
+// Trim applies the policy outside of XADD, so quiet streams and DLQs shrink too.
+func (r Retention) Trim(ctx context.Context, rdb *redis.Client, stream string) error {
+	if r.MaxAge > 0 {
+		return rdb.XTrimMinIDApprox(ctx, stream, r.MinID(time.Now()), 0).Err()
+	}
+	return rdb.XTrimMaxLenApprox(ctx, stream, r.MaxLen, 0).Err()
+}
+
+// RunRetention trims every managed stream on each tick.
+func RunRetention(ctx context.Context, rdb *redis.Client, r Retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, stream := range Managed {
+			if err := r.Trim(ctx, rdb, stream); err != nil {
+				log.Printf("Failed to trim stream %s: %v", stream, err)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 import time
 import logging
 from redis import Redis
+from redis.exceptions import ResponseError
 from src.config import cfg
 from src.clients.core_client import CoreClient
 from src.logic.payload_parser import PayloadParser
@@ -16,23 +17,36 @@ def main():
     core = CoreClient()
     parser = PayloadParser()
     processor = EventProcessor(core, parser)
+    processor.config_cache.listen(redis, cfg.CONFIG_CHANNEL, "scale")
 
+    try:
+        redis.xgroup_create(cfg.STREAM_RAW, cfg.CONSUMER_GROUP, id="0", mkstream=True)
+    except ResponseError as e:
+        if "BUSYGROUP" not in str(e):
+            raise
+
+    # Finish our own pending entries from a previous run first, then read new ones.
     last_id = "0"
     while True:
         try:
-            streams = redis.xread({cfg.STREAM_RAW: last_id}, count=1, block=5000)
+            streams = redis.xreadgroup(
+                cfg.CONSUMER_GROUP, cfg.CONSUMER_NAME, {cfg.STREAM_RAW: last_id}, count=1, block=5000
+            )
             if not streams:
                 continue
 
             for _, messages in streams:
+                if not messages and last_id == "0":
+                    last_id = ">"
                 for msg_id, data in messages:
-                    try:
-                        processor.process(data["data"]) 
-                    except Exception as e:
-                        logger.error(f"Failed to process message {msg_id}: {e}")
-                        redis.xadd(cfg.STREAM_DLQ, {"data": data["data"], "error": str(e)})
-                    
-                    last_id = msg_id
+                    if data:
+                        try:
+                            processor.process(data["data"], msg_id)
+                        except Exception as e:
+                            logger.error(f"Failed to process message {msg_id}: {e}")
+                            redis.xadd(cfg.STREAM_DLQ, {"data": data["data"], "error": str(e)})
+
+                    redis.xack(cfg.STREAM_RAW, cfg.CONSUMER_GROUP, msg_id)
 
         except Exception as e:
             logger.critical(f"Redis connection error: {e}")
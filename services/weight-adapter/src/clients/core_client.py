@@ -1,5 +1,6 @@
 import requests
 from src.config import cfg
+from src.logic.config_cache import NOT_MODIFIED
 from tenacity import retry, stop_after_attempt, wait_exponential
 
 class CoreClient:
@@ -8,18 +9,27 @@ class CoreClient:
             "X-API-Key": cfg.WORKER_API_KEY, 
             "Content-Type": "application/json"
         }
-    def get_scale_config(self, source_id: str) -> dict | None:
+
+    def get_scale_config(self, source_id: str, etag: str | None = None) -> tuple:
+        """Returns (config, etag), (NOT_MODIFIED, etag) when etag still
+        matches, or (None, None) when core does not know the scale."""
         url = f"{cfg.CORE_URL}/scales/by-id/{source_id}"
-        try:
-            resp = requests.get(url, headers=self.headers, timeout=5)
-            if resp.status_code == 200:
-                return resp.json()
-        except Exception:
-            pass
-        return None
+        headers = dict(self.headers)
+        if etag:
+            headers["If-None-Match"] = etag
+        resp = requests.get(url, headers=headers, timeout=5)
+        if resp.status_code == 304:
+            return NOT_MODIFIED, etag
+        if resp.status_code == 404:
+            return None, None
+        resp.raise_for_status()
+        return resp.json(), resp.headers.get("ETag")
 
     @retry(stop=stop_after_attempt(5), wait=wait_exponential(multiplier=1, min=2, max=10))
-    def send_weight_event(self, event_data: dict):
+    def send_weight_event(self, event_data: dict, idempotency_key: str | None = None):
         url = f"{cfg.CORE_URL}/events/weight" 
-        resp = requests.post(url, json=event_data, headers=self.headers, timeout=5) 
+        headers = dict(self.headers)
+        if idempotency_key:
+            headers["Idempotency-Key"] = idempotency_key
+        resp = requests.post(url, json=event_data, headers=headers, timeout=5)
         resp.raise_for_status()
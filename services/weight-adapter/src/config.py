@@ -1,4 +1,5 @@
 import os
+import socket
 from dataclasses import dataclass
 
 @dataclass(frozen=True)
@@ -9,6 +10,9 @@ class Config:
 
     STREAM_RAW: str = "weight:raw"
     STREAM_DLQ: str = "weight:dlq"
-    CACHE_TTL: int = 60
+    CONSUMER_GROUP: str = os.getenv("CONSUMER_GROUP", "weight-adapter")
+    CONSUMER_NAME: str = os.getenv("CONSUMER_NAME", socket.gethostname())
+    CONFIG_CHANNEL: str = "core:config"
+    CACHE_TTL: int = int(os.getenv("CONFIG_CACHE_TTL", "60"))
 
 cfg = Config()
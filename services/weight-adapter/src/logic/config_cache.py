@@ -0,0 +1,74 @@
+import json
+import logging
+import threading
+import time
+
+logger = logging.getLogger("weight-adapter")
+
+NOT_MODIFIED = object()
+
+
+class ConfigCache:
+    """Device configs fetched from core.
+
+    Entries are dropped when core announces a change on the config channel.
+    Entries older than ttl seconds are revalidated with a conditional GET, so a
+    missed announcement only delays a change.
+    """
+
+    def __init__(self, fetch, ttl: int):
+        # fetch(source_id, etag) returns (config, etag), (NOT_MODIFIED, etag)
+        # or (None, None) for an unknown device, and raises when core fails.
+        self.fetch = fetch
+        self.ttl = ttl
+        self.entries = {}
+        self.lock = threading.Lock()
+
+    def get(self, source_id: str):
+        with self.lock:
+            entry = self.entries.get(source_id)
+        if entry and time.monotonic() - entry["fetched_at"] < self.ttl:
+            return entry["config"]
+
+        try:
+            config, etag = self.fetch(source_id, entry["etag"] if entry else None)
+        except Exception as e:
+            # Keep serving what we had while core is unreachable.
+            logger.error(f"Error fetching config for {source_id}: {e}")
+            return entry["config"] if entry else None
+        if config is NOT_MODIFIED:
+            config = entry["config"]
+        with self.lock:
+            if config:
+                self.entries[source_id] = {"config": config, "etag": etag, "fetched_at": time.monotonic()}
+            else:
+                self.entries.pop(source_id, None)
+        return config
+
+    def invalidate(self, source_id: str | None = None):
+        with self.lock:
+            if source_id is None:
+                self.entries.clear()
+            else:
+                self.entries.pop(source_id, None)
+
+    def listen(self, redis, channel: str, device_type: str):
+        """Drop entries on change announcements; runs in a daemon thread."""
+
+        def run():
+            while True:
+                try:
+                    pubsub = redis.pubsub(ignore_subscribe_messages=True)
+                    pubsub.subscribe(channel)
+                    # Changes may have been missed while unsubscribed.
+                    self.invalidate()
+                    for message in pubsub.listen():
+                        change = json.loads(message["data"])
+                        if change.get("device_type") == device_type:
+                            logger.info(f"Config changed for {change.get('source_id')}, version {change.get('version')}")
+                            self.invalidate(change.get("source_id"))
+                except Exception as e:
+                    logger.error(f"Config change subscription failed: {e}")
+                    time.sleep(5)
+
+        threading.Thread(target=run, name="config-changes", daemon=True).start()
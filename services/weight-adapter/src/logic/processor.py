@@ -1,5 +1,7 @@
 import json
 import logging
+from src.config import cfg
+from src.logic.config_cache import ConfigCache
 
 logger = logging.getLogger("weight-adapter")
 logging.basicConfig(level=logging.INFO)
@@ -8,18 +10,15 @@ class EventProcessor:
     def __init__(self, core_client, parser):
         self.core = core_client
         self.parser = parser
-        self.config_cache = {}
+        self.config_cache = ConfigCache(core_client.get_scale_config, cfg.CACHE_TTL)
 
     def _get_cached_config(self, source_id: str):
-        if source_id in self.config_cache:
-            return self.config_cache[source_id]
-        config = self.core.get_scale_config(source_id)
-        if config:
-            self.config_cache[source_id] = config
-        return config
-
-    def process(self, raw_data_str: str):
+        return self.config_cache.get(source_id)
+
+    def process(self, raw_data_str: str, msg_id: str | None = None):
         data = json.loads(raw_data_str)
+        # The ingestor's event_id survives DLQ replays; the stream ID does not.
+        idempotency_key = data.get("event_id") or msg_id
         source_id = data.get("source_id")
 
         config = self._get_cached_config(source_id)
@@ -41,11 +40,14 @@ class EventProcessor:
                 "scale_source_id": source_id,
                 "scale_id": f"{config.get("ID")}",
                 "weight": weight,
-                "timestamp": data.get("at"),
+                "timestamp": data.get("captured_at") or data.get("at"),
+                "captured_at": data.get("captured_at"),
+                "received_at": data.get("at"),
+                "buffered": bool(data.get("device_event_id")),
                 "raw_payload": data.get("payload"),
             }
             try:
-                self.core.send_weight_event(final_event)
+                self.core.send_weight_event(final_event, idempotency_key)
                 logger.info(f"Processed weight for {source_id}: {weight} kg {final_event}")
             except Exception as e:
                 logger.error(f"Failed to send weight event to Core: {e}")
@@ -0,0 +1,26 @@
+import json
+import unittest
+from pathlib import Path
+
+from src.logic.payload_parser import PayloadParser
+
+# Shared with core's payloadparser tests so both sides extract the same values.
+PARITY_CASES = Path(__file__).resolve().parents[2] / "core/src/payloadparser/testdata/parity.json"
+
+
+class PayloadParserParityTest(unittest.TestCase):
+    def test_parity_with_core(self):
+        for case in json.loads(PARITY_CASES.read_text()):
+            with self.subTest(case["name"]):
+                value = PayloadParser.extract_field(case["payload"], case["format"], case["mapping"], case["field"])
+                self.assertEqual(value, case["value"])
+                if "kg" in case:
+                    kg = PayloadParser.extract_value(case["payload"], case["format"], case["mapping"])
+                    self.assertAlmostEqual(kg, case["kg"])
+
+    def test_extract_value_swallows_malformed_payload(self):
+        self.assertIsNone(PayloadParser.extract_value("<Scale>", "xml", {"weight": "/Scale/Reading"}))
+
+
+if __name__ == "__main__":
+    unittest.main()